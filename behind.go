@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+)
+
+// versionsBehind measures how far the installed version trails latest among versions, the builds
+// compatible with the pack. When every version number involved parses as semver, patch bumps are
+// ignored and the distance counts newer major.minor lines; otherwise it counts newer builds on the
+// pack's channel. ok is false when the installed version isn't among versions.
+func versionsBehind(versions []Version, installedID string, latest *Version, channel string) (n int, unit string, ok bool) {
+	var installed *Version
+	for i := range versions {
+		if versions[i].ID == installedID {
+			installed = &versions[i]
+			break
+		}
+	}
+	if installed == nil {
+		return 0, "", false
+	}
+	var newer []Version
+	for _, v := range versions {
+		if v.DatePublished.After(installed.DatePublished) && !v.DatePublished.After(latest.DatePublished) && ChannelAllows(channel, v.VersionType) {
+			newer = append(newer, v)
+		}
+	}
+
+	base, semverOK := parseSemver(installed.VersionNumber)
+	lines := make(map[[2]int]bool) // newer major.minor lines
+	for _, v := range newer {
+		sv, ok := parseSemver(v.VersionNumber)
+		if !ok || !semverOK {
+			semverOK = false
+			break
+		}
+		if line := minorLine(sv); line != minorLine(base) && compareSemver(sv, base) > 0 {
+			lines[line] = true
+		}
+	}
+	if semverOK {
+		return len(lines), "minor version", true
+	}
+	return len(newer), "version", true
+}
+
+// minorLine is v's major and minor number, missing parts counting as 0
+func minorLine(v semver) [2]int {
+	var line [2]int
+	for i := 0; i < 2 && i < len(v.core); i++ {
+		line[i] = v.core[i]
+	}
+	return line
+}
+
+// describeBehind formats a versionsBehind result, e.g. "2 minor versions behind"
+func describeBehind(n int, unit string) string {
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s behind", n, unit)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVersionsBehind(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	ver := func(id, number, typ string, d int) Version {
+		return Version{ID: id, VersionNumber: number, VersionType: typ, DatePublished: day(d)}
+	}
+	semverBuilds := []Version{
+		ver("a", "1.2.0", "release", 1),
+		ver("b", "1.2.5", "release", 2),
+		ver("c", "1.3.0", "release", 3),
+		ver("d", "1.4.0-beta.1", "beta", 4),
+		ver("e", "1.4.0", "release", 5),
+		ver("f", "2.0.0", "release", 6),
+	}
+	mixedBuilds := []Version{
+		ver("a", "1.0.0", "release", 1),
+		ver("b", "build 17", "release", 2),
+		ver("c", "1.1.0", "beta", 3),
+		ver("d", "1.2.0", "release", 4),
+	}
+	tests := []struct {
+		name      string
+		versions  []Version
+		installed string
+		latest    int // index into versions
+		channel   string
+		wantN     int
+		wantUnit  string
+		wantOK    bool
+	}{
+		{"minor lines, patch bumps ignored", semverBuilds, "a", 5, "release", 3, "minor version", true},
+		{"patch behind only", semverBuilds, "a", 1, "release", 0, "minor version", true},
+		{"up to date", semverBuilds, "f", 5, "release", 0, "minor version", true},
+		{"beta channel counts the same lines", semverBuilds, "b", 4, "beta", 2, "minor version", true},
+		{"latest caps the count", semverBuilds, "a", 2, "release", 1, "minor version", true},
+		{"unparsable number counts builds on the channel", mixedBuilds, "a", 3, "release", 2, "version", true},
+		{"unparsable with beta channel", mixedBuilds, "a", 3, "beta", 3, "version", true},
+		{"installed not among versions", semverBuilds, "gone", 5, "release", 0, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, unit, ok := versionsBehind(tt.versions, tt.installed, &tt.versions[tt.latest], tt.channel)
+			if n != tt.wantN || unit != tt.wantUnit || ok != tt.wantOK {
+				t.Errorf("versionsBehind = %d, %q, %v; want %d, %q, %v", n, unit, ok, tt.wantN, tt.wantUnit, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMinorLine(t *testing.T) {
+	tests := []struct {
+		version string
+		want    [2]int
+	}{
+		{"1.20.4", [2]int{1, 20}},
+		{"2.0", [2]int{2, 0}},
+		{"3", [2]int{3, 0}},
+		{"0.5.1-beta.2", [2]int{0, 5}},
+	}
+	for _, tt := range tests {
+		v, ok := parseSemver(tt.version)
+		if !ok {
+			t.Fatalf("parseSemver(%q) failed", tt.version)
+		}
+		if got := minorLine(v); got != tt.want {
+			t.Errorf("minorLine(%s) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeBehind(t *testing.T) {
+	tests := []struct {
+		n    int
+		unit string
+		want string
+	}{
+		{1, "minor version", "1 minor version behind"},
+		{2, "minor version", "2 minor versions behind"},
+		{0, "version", "0 versions behind"},
+		{5, "version", "5 versions behind"},
+	}
+	for _, tt := range tests {
+		if got := describeBehind(tt.n, tt.unit); got != tt.want {
+			t.Errorf("describeBehind(%d, %q) = %q, want %q", tt.n, tt.unit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL = 10 * time.Minute
+	cacheStatsFile  = "stats.json"
+)
+
+// cacheEntry is one cached API response on disk
+type cacheEntry struct {
+	URL          string          `json:"url"`
+	FetchedAt    time.Time       `json:"fetched_at"`
+	ETag         string          `json:"etag,omitempty"`          // validators sent back once the entry is stale
+	LastModified string          `json:"last_modified,omitempty"` // so a 304 can reuse Body without a full download
+	Body         json.RawMessage `json:"body,omitempty"`
+	Text         string          `json:"text,omitempty"` // the body instead when it isn't JSON, such as a YAML config
+}
+
+// body returns the cached response body, whichever field holds it
+func (e *cacheEntry) body() []byte {
+	if e.Body == nil {
+		return []byte(e.Text)
+	}
+	return e.Body
+}
+
+// CacheStats counts lookups since the cache was last purged
+type CacheStats struct {
+	Hits        int       `json:"hits"`
+	Misses      int       `json:"misses"`
+	Revalidated int       `json:"revalidated"` // stale entries the server confirmed unchanged (304)
+	Since       time.Time `json:"since"`
+}
+
+// pendingStats accumulates this run's lookups until flushCacheStats writes them out
+var (
+	pendingStats   CacheStats
+	pendingStatsMu sync.Mutex
+)
+
+func countLookup(hit bool) {
+	countRun(func(s *RunStats) {
+		if hit {
+			s.CacheHits++
+		} else {
+			s.CacheMisses++
+		}
+	})
+	pendingStatsMu.Lock()
+	defer pendingStatsMu.Unlock()
+	if hit {
+		pendingStats.Hits++
+	} else {
+		pendingStats.Misses++
+	}
+}
+
+func countRevalidated() {
+	countRun(func(s *RunStats) { s.Revalidated++ })
+	pendingStatsMu.Lock()
+	defer pendingStatsMu.Unlock()
+	pendingStats.Revalidated++
+}
+
+// defaultCacheDir returns the per-user cache location, falling back to a local directory
+func defaultCacheDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "modpilot")
+	}
+	return ".modpilot-cache"
+}
+
+func cacheEntryPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cachedGet returns the body of a successful GET to url, reusing a cached copy younger than cacheTTL.
+// An older copy is revalidated with If-None-Match/If-Modified-Since and reused if the server answers 304.
+func cachedGet(url string) ([]byte, error) {
+	return cachedGetTTL(url, cacheTTL)
+}
+
+// cachedGetTTL is cachedGet with its own freshness window; 0 skips the cache. Requests sent with
+// --token skip it too: the cache is keyed by URL alone, so what a token can see (private and
+// unlisted projects) would be left in plain files and served to later runs without one.
+func cachedGetTTL(url string, ttl time.Duration) ([]byte, error) {
+	return cachedGetAs(url, ttl, json.Valid)
+}
+
+// cachedGetAs is cachedGetTTL for a response that need not be JSON: a body is only cached once
+// valid accepts it, so an error page served with 200 isn't reused
+func cachedGetAs(url string, ttl time.Duration, valid func([]byte) bool) ([]byte, error) {
+	if sendsToken(url) {
+		ttl = 0
+	}
+	var stale *cacheEntry
+	header := make(http.Header)
+	if ttl > 0 {
+		if data, err := os.ReadFile(cacheEntryPath(url)); err == nil {
+			var entry cacheEntry
+			if json.Unmarshal(data, &entry) == nil && entry.URL == url {
+				if time.Since(entry.FetchedAt) < ttl {
+					countLookup(true)
+					return entry.body(), nil
+				}
+				if entry.ETag != "" || entry.LastModified != "" {
+					stale = &entry
+				}
+			}
+		}
+	}
+	if stale != nil {
+		if stale.ETag != "" {
+			header.Set("If-None-Match", stale.ETag)
+		}
+		if stale.LastModified != "" {
+			header.Set("If-Modified-Since", stale.LastModified)
+		}
+	}
+
+	resp, err := apiGet(url, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		countRevalidated()
+		stale.FetchedAt = time.Now()
+		if err := writeCacheEntry(*stale); err != nil && verbose {
+			fmt.Printf("Warning: could not write cache entry: %v\n", err)
+		}
+		return stale.body(), nil
+	}
+	if ttl > 0 {
+		countLookup(false)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 && valid(body) {
+		entry := cacheEntry{URL: url, FetchedAt: time.Now(), ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		if json.Valid(body) {
+			entry.Body = body
+		} else {
+			entry.Text = string(body)
+		}
+		if err := writeCacheEntry(entry); err != nil && verbose {
+			fmt.Printf("Warning: could not write cache entry: %v\n", err)
+		}
+	}
+	return body, nil
+}
+
+func writeCacheEntry(entry cacheEntry) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cacheEntryPath(entry.URL), data, 0644)
+}
+
+// loadCacheStats reads the stats file, starting fresh if it's missing or unreadable
+func loadCacheStats() CacheStats {
+	var stats CacheStats
+	if data, err := os.ReadFile(filepath.Join(cacheDir, cacheStatsFile)); err == nil {
+		json.Unmarshal(data, &stats)
+	}
+	if stats.Since.IsZero() {
+		stats.Since = time.Now()
+	}
+	return stats
+}
+
+func saveCacheStats(stats CacheStats) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cacheDir, cacheStatsFile), data, 0644)
+}
+
+// flushCacheStats adds this run's hits and misses to the stats file
+func flushCacheStats() {
+	pendingStatsMu.Lock()
+	defer pendingStatsMu.Unlock()
+	if pendingStats == (CacheStats{}) {
+		return
+	}
+	stats := loadCacheStats()
+	stats.Hits += pendingStats.Hits
+	stats.Misses += pendingStats.Misses
+	stats.Revalidated += pendingStats.Revalidated
+	if err := saveCacheStats(stats); err != nil && verbose {
+		fmt.Printf("Warning: could not save cache stats: %v\n", err)
+	}
+	pendingStats = CacheStats{}
+}
+
+// cacheEntries lists the entry files in the cache directory
+func cacheEntries() ([]os.DirEntry, error) {
+	files, err := os.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []os.DirEntry
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".json") && f.Name() != cacheStatsFile {
+			entries = append(entries, f)
+		}
+	}
+	return entries, nil
+}
+
+// cacheExpired reports whether the cache entry at path was fetched ttl or longer ago, going by the
+// time it records rather than the file's, which copying or touching the cache changes. Unreadable
+// entries count as expired.
+func cacheExpired(path string, ttl time.Duration) bool {
+	var entry cacheEntry
+	data, err := os.ReadFile(path)
+	return err != nil || json.Unmarshal(data, &entry) != nil || time.Since(entry.FetchedAt) >= ttl
+}
+
+// cleanCache removes entries older than ttl (all of them when ttl <= 0) and returns how many it deleted.
+// With dry set it only counts them.
+func cleanCache(ttl time.Duration, dry bool) (int, error) {
+	entries, err := cacheEntries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, f := range entries {
+		p := filepath.Join(cacheDir, f.Name())
+		if ttl > 0 && !cacheExpired(p, ttl) {
+			continue
+		}
+		if !dry {
+			if err := os.Remove(p); err != nil {
+				return removed, err
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
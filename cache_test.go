@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCachedGetRevalidates(t *testing.T) {
+	const etag, lastModified, body = `"v1"`, "Wed, 01 May 2024 12:00:00 GMT", `[{"id":"abc"}]`
+	requests := 0
+	srv := newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			if got := r.Header.Get("If-None-Match"); got != etag {
+				t.Errorf("revalidation sent If-None-Match %q, want %q", got, etag)
+			}
+			if got := r.Header.Get("If-Modified-Since"); got != lastModified {
+				t.Errorf("revalidation sent If-Modified-Since %q, want %q", got, lastModified)
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(body))
+	}))
+	url := srv.URL + "/v2/project/mod/version"
+	const ttl = time.Hour
+
+	get := func() {
+		t.Helper()
+		got, err := cachedGetTTL(url, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != body {
+			t.Fatalf("cachedGetTTL returned %s, want %s", got, body)
+		}
+	}
+	get()
+
+	// Age the entry past its TTL
+	path := cacheEntryPath(url)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.FetchedAt = time.Now().Add(-2 * ttl)
+	if err := writeCacheEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	revalidated := pendingStats.Revalidated
+	get() // stale: revalidated with a 304 and served from the cache
+	if requests != 2 {
+		t.Fatalf("the stale entry made %d request(s) in all, want 2", requests)
+	}
+	if pendingStats.Revalidated != revalidated+1 {
+		t.Errorf("the 304 was not counted as a revalidation")
+	}
+	get() // fresh again for another TTL: no request
+	if requests != 2 {
+		t.Errorf("the revalidated entry made another request within its new TTL")
+	}
+}
+
+func TestCacheExpiredUsesFetchedAt(t *testing.T) {
+	newTestAPI(t, http.NotFoundHandler())
+	const ttl = time.Hour
+	fresh := cacheEntry{URL: "https://example.com/fresh", FetchedAt: time.Now(), Body: json.RawMessage(`{}`)}
+	stale := cacheEntry{URL: "https://example.com/stale", FetchedAt: time.Now().Add(-2 * ttl), Body: json.RawMessage(`{}`)}
+	for _, e := range []cacheEntry{fresh, stale} {
+		if err := writeCacheEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Touching the files (as copying the cache does) must not change which entries are expired
+	old := time.Now().Add(-10 * ttl)
+	if err := os.Chtimes(cacheEntryPath(fresh.URL), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(cacheEntryPath(stale.URL), time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if cacheExpired(cacheEntryPath(fresh.URL), ttl) {
+		t.Error("an entry fetched just now is expired")
+	}
+	if !cacheExpired(cacheEntryPath(stale.URL), ttl) {
+		t.Error("an entry fetched two TTLs ago is not expired")
+	}
+
+	corrupt := cacheEntryPath("https://example.com/corrupt")
+	writeFile(t, corrupt, "not json")
+	if !cacheExpired(corrupt, ttl) {
+		t.Error("an unreadable entry is not expired")
+	}
+}
+
+func TestCachedGetSkipsCacheWithToken(t *testing.T) {
+	requests := 0
+	srv := newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"id":"private"}`))
+	}))
+	old := modrinthToken
+	modrinthToken = "mrp_secret"
+	t.Cleanup(func() { modrinthToken = old })
+
+	url := srv.URL + "/v2/project/private"
+	for i := 0; i < 2; i++ {
+		if _, err := cachedGetTTL(url, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("%d request(s) for two authenticated lookups, want 2", requests)
+	}
+	if _, err := os.Stat(cacheEntryPath(url)); !os.IsNotExist(err) {
+		t.Errorf("an authenticated response was cached on disk: %v", err)
+	}
+}
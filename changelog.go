@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// changelogUpdate is one mod moved to a newer version by update, for --changelog-summary
+type changelogUpdate struct {
+	Slug   string
+	FromID string
+	To     *Version
+}
+
+// versionsBetween returns slug's versions published after fromID and up to to, newest first,
+// so a jump over several releases shows every changelog in between. Only builds for loader and
+// one of mcVersions are kept, as LatestCompatible picks them, which leaves out ports for other
+// loaders and game versions published alongside.
+func versionsBetween(slug, fromID string, to *Version, mcVersions []string, loader string) ([]Version, error) {
+	versions, err := FetchAllVersions(slug)
+	if err != nil {
+		return nil, err
+	}
+	var from *Version
+	for i := range versions {
+		if versions[i].ID == fromID {
+			from = &versions[i]
+			break
+		}
+	}
+	if from == nil {
+		// Deleted from the listing, but usually still fetchable by ID
+		if from, err = FetchVersion(fromID); err != nil {
+			return []Version{*to}, nil
+		}
+	}
+
+	var between []Version
+	for _, v := range versions {
+		if v.ID == from.ID || !v.DatePublished.After(from.DatePublished) || v.DatePublished.After(to.DatePublished) {
+			continue
+		}
+		forPack := slices.Contains(v.Loaders, loader) && slices.ContainsFunc(v.GameVersions, func(gv string) bool { return slices.Contains(mcVersions, gv) })
+		if v.ID == to.ID || forPack {
+			between = append(between, v)
+		}
+	}
+	if !slices.ContainsFunc(between, func(v Version) bool { return v.ID == to.ID }) {
+		between = append(between, *to)
+	}
+	sort.SliceStable(between, func(i, j int) bool { return between[i].DatePublished.After(between[j].DatePublished) })
+	return between, nil
+}
+
+// writeChangelogSummary prints the consolidated "what's new" report for updates, one section
+// per mod listing every version it moved past for the pack's mcVersions and loader
+func writeChangelogSummary(w io.Writer, updates []changelogUpdate, mcVersions []string, loader string) {
+	if len(updates) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nWhat's new in this update:")
+	for _, u := range updates {
+		from := u.FromID
+		if v, err := FetchVersion(u.FromID); err == nil {
+			from = v.VersionNumber
+		}
+		fmt.Fprintf(w, "\n%s (%s -> %s)\n", u.Slug, from, u.To.VersionNumber)
+		versions, err := versionsBetween(u.Slug, u.FromID, u.To, mcVersions, loader)
+		if err != nil {
+			fmt.Fprintf(w, "  (could not fetch changelogs: %v)\n", err)
+			continue
+		}
+		for _, v := range versions {
+			fmt.Fprintf(w, "  %s (%s)\n", v.VersionNumber, v.DatePublished.Format("2006-01-02"))
+			changelog := strings.TrimSpace(v.Changelog)
+			if changelog == "" {
+				changelog = "(no changelog provided)"
+			}
+			for _, l := range strings.Split(changelog, "\n") {
+				fmt.Fprintf(w, "    %s\n", strings.TrimRight(l, "\r"))
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestVersionsBetweenFiltersToPack(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	ver := func(id string, d int, mc, loader string) Version {
+		return Version{ID: id, DatePublished: day(d), GameVersions: []string{mc}, Loaders: []string{loader}}
+	}
+	versions := []Version{
+		ver("to", 9, "1.21.1", "fabric"),
+		ver("other-mc", 8, "1.20.1", "fabric"),
+		ver("other-loader", 7, "1.21.1", "neoforge"),
+		ver("mid", 5, "1.21.1", "fabric"),
+		ver("from", 2, "1.21.1", "fabric"),
+		ver("older", 1, "1.21.1", "fabric"),
+	}
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/mod/version" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(versions)
+	}))
+
+	got, err := versionsBetween("mod", "from", &versions[0], []string{"1.21.1"}, "fabric")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, v := range got {
+		ids = append(ids, v.ID)
+	}
+	if want := []string{"to", "mid"}; !slices.Equal(ids, want) {
+		t.Errorf("versionsBetween = %v, want %v", ids, want)
+	}
+}
@@ -1,43 +1,307 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // ModpackConfig defines settings for a single modpack
 type ModpackConfig struct {
-	MCVersion string   `json:"mc_version"`
-	Loader    string   `json:"loader"`
-	Mods      []string `json:"mods"`
+	MCVersion   string            `json:"mc_version"`
+	Loader      string            `json:"loader"`
+	Channel     string            `json:"channel,omitempty"`      // release, beta or alpha; empty accepts any
+	JavaVersion int               `json:"java_version,omitempty"` // Java major version the pack needs, e.g. 17; metadata for provisioning
+	Inherits    []string          `json:"inherits,omitempty"`     // names of shared mod groups merged into Mods
+	Mods        []ModEntry        `json:"mods"`
+	Pins        map[string]string `json:"pins,omitempty"`     // slug -> version ID to stay on instead of the latest
+	Channels    map[string]string `json:"channels,omitempty"` // slug -> channel for that mod, overriding Channel
+	Frozen      []string          `json:"frozen,omitempty"`   // slugs that update and check-updates leave alone
+	Archived    bool              `json:"archived,omitempty"` // hidden from list-packs and fleet-wide stats until unarchived
+}
+
+// ChannelFor returns the release channel slug is resolved on: its own from Channels, else the pack's
+func (p ModpackConfig) ChannelFor(slug string) string {
+	if channel, ok := p.Channels[slug]; ok {
+		return channel
+	}
+	return p.Channel
+}
+
+// channelNote labels a mod that has its own channel, e.g. " [beta channel]", for status lines
+func (p ModpackConfig) channelNote(slug string) string {
+	if channel, ok := p.Channels[slug]; ok {
+		return " [" + ternary(channel == "", "any", channel) + " channel]"
+	}
+	return ""
+}
+
+// ModEntry is one mod in a pack's list. It is written as a bare slug string unless it carries a note.
+type ModEntry struct {
+	Slug string `json:"slug"`
+	Note string `json:"note,omitempty"` // free-form reminder of why the mod is in the pack
+}
+
+// UnmarshalJSON accepts either "slug" or {"slug": ..., "note": ...}
+func (e *ModEntry) UnmarshalJSON(data []byte) error {
+	var slug string
+	if err := json.Unmarshal(data, &slug); err == nil {
+		*e = ModEntry{Slug: slug}
+		return nil
+	}
+	type plain ModEntry // drops the methods so this doesn't recurse
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("mod entry must be a slug string or an object with a slug: %w", err)
+	}
+	if p.Slug == "" {
+		return fmt.Errorf("mod entry %s is missing 'slug'", data)
+	}
+	*e = ModEntry(p)
+	return nil
+}
+
+// MarshalJSON writes entries without a note as a plain slug so existing configs round-trip unchanged
+func (e ModEntry) MarshalJSON() ([]byte, error) {
+	if e.Note == "" {
+		return json.Marshal(e.Slug)
+	}
+	type plain ModEntry
+	return json.Marshal(plain(e))
+}
+
+// Slugs returns the slugs of the pack's own mods, in order
+func (p ModpackConfig) Slugs() []string {
+	slugs := make([]string, len(p.Mods))
+	for i, e := range p.Mods {
+		slugs[i] = e.Slug
+	}
+	return slugs
+}
+
+// Entry returns the index of slug in the pack's own mods, or -1
+func (p ModpackConfig) Entry(slug string) int {
+	return slices.IndexFunc(p.Mods, func(e ModEntry) bool { return e.Slug == slug })
 }
 
 // Config is the top-level structure for config.json
 type Config struct {
-	DefaultMCVersion string                   `json:"default_mc_version,omitempty"`
-	DefaultLoader    string                   `json:"default_loader,omitempty"`
-	Modpacks         map[string]ModpackConfig `json:"modpacks"`
+	DefaultMCVersion string                       `json:"default_mc_version,omitempty"`
+	DefaultLoader    string                       `json:"default_loader,omitempty"`
+	ActivePack       string                       `json:"active_pack,omitempty"`      // used when a command's pack argument is omitted
+	Shared           map[string][]string          `json:"shared,omitempty"`           // group name -> slugs, referenced by a pack's inherits
+	Include          []string                     `json:"include,omitempty"`          // extra JSON or YAML files whose modpacks are merged in, relative to this file
+	SortMods         bool                         `json:"sort_mods,omitempty"`        // sort and dedupe mod lists on save for minimal diffs
+	NotifyWebhook    string                       `json:"notify_webhook,omitempty"`   // Discord/Slack-compatible webhook for check-updates --notify
+	DownloadMirror   string                       `json:"download_mirror,omitempty"`  // base URL mirroring cdn.modrinth.com's paths, tried first for downloads
+	DownloadHeaders  map[string]map[string]string `json:"download_headers,omitempty"` // host -> extra headers (e.g. an API key) for downloads from private hosts
+	CompactState     bool                         `json:"compact_state,omitempty"`    // write state.json on one line, for machine-managed states
+	ConflictLists    []string                     `json:"conflict_lists,omitempty"`   // known-conflicts files (paths or URLs) that doctor and lint check packs against
+	Conflicts        []KnownConflict              `json:"conflicts,omitempty"`        // the config's own known conflicts, added to conflict_lists
+	Modpacks         map[string]ModpackConfig     `json:"modpacks"`
+
+	packSources map[string]string // pack name -> include entry it was loaded from; absent for packs in the main file
+	path        string            // where LoadConfig read the config from; include entries resolve against it
+	includeData map[string][]byte // include entry -> its packs as last loaded or saved, so SaveConfig skips unchanged files
+}
+
+// packListing is one element of list-packs --json: the pack's config exactly as config.json
+// spells it, plus its name and a few derived fields
+type packListing struct {
+	Name     string `json:"name"`
+	Active   bool   `json:"active"`
+	ModCount int    `json:"mod_count"`        // including mods from inherited groups
+	Source   string `json:"source,omitempty"` // include file the pack is defined in
+	ModpackConfig
 }
 
-// ModState stores the last known version ID and filename for a mod
+// includeFile is the shape of a file listed in a config's include array
+type includeFile struct {
+	Modpacks map[string]ModpackConfig `json:"modpacks"`
+}
+
+// isYAMLInclude reports whether an include entry is a YAML file, going by its extension
+func isYAMLInclude(inc string) bool {
+	switch strings.ToLower(filepath.Ext(inc)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// decodeInclude parses an include file as JSON or, for a .yaml/.yml entry, YAML. YAML is converted
+// to JSON first so both take the same field names and mod entry forms as config.json.
+func decodeInclude(inc string, data []byte) (includeFile, error) {
+	var f includeFile
+	if isYAMLInclude(inc) {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return f, fmt.Errorf("%s: %w", inc, err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return f, fmt.Errorf("%s: %w", inc, err)
+		}
+		if err := json.Unmarshal(data, &f); err != nil {
+			return f, fmt.Errorf("%s: %w", inc, err)
+		}
+		return f, nil
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, jsonError(inc, data, err)
+	}
+	return f, nil
+}
+
+// encodeInclude renders an include file's packs the way decodeInclude reads them back
+func encodeInclude(inc string, packs map[string]ModpackConfig) ([]byte, error) {
+	if packs == nil {
+		packs = make(map[string]ModpackConfig)
+	}
+	data, err := json.MarshalIndent(includeFile{Modpacks: packs}, "", "  ")
+	if err != nil || !isYAMLInclude(inc) {
+		return data, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ModState stores the last known version ID, filename and file hash for a mod
 type ModState struct {
-	VersionID string `json:"version_id"`
-	Filename  string `json:"filename"`
+	VersionID     string   `json:"version_id"`
+	VersionNumber string   `json:"version_number,omitempty"` // the author's number for VersionID, for display only
+	Filename      string   `json:"filename"`
+	SHA512        string   `json:"sha512,omitempty"`      // hex digest of the downloaded file, as published by Modrinth
+	SkipEnv       string   `json:"skipped_env,omitempty"` // update --env left this mod out as unsupported in that environment
+	RequiredBy    []string `json:"required_by,omitempty"` // mods whose versions require this one; without it in the pack's mods, it was installed only as a dependency
 }
 
 // State maps modpack names to maps of mod slugs to their state
 type State map[string]map[string]ModState // packName -> slug -> ModState
 
+// stdinPath as a --config or --state path reads that file from standard input
+const stdinPath = "-"
+
+// remoteConfigTTL caps how long a config fetched from a URL is reused, so edits to a centrally
+// hosted definition reach every machine within a minute
+const remoteConfigTTL = time.Minute
+
+// isRemote reports whether a --config path is an http(s) URL, which is read-only
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// utf8BOM is the byte order mark some Windows editors put at the start of a UTF-8 file
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// readInput reads path, standard input when path is stdinPath, or a URL for a remote config,
+// dropping a leading UTF-8 BOM, which encoding/json would reject
+func readInput(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case path == stdinPath:
+		data, err = io.ReadAll(os.Stdin)
+	case isRemote(path):
+		if data, err = cachedGetAs(path, min(cacheTTL, remoteConfigTTL), func(b []byte) bool { return parses(path, b) }); err != nil {
+			return nil, fmt.Errorf("failed to fetch config: %w", err)
+		}
+	default:
+		data, err = os.ReadFile(path)
+	}
+	return bytes.TrimPrefix(data, utf8BOM), err
+}
+
+// parses reports whether data is a config file in the format path names: YAML for a .yaml/.yml
+// include, JSON otherwise
+func parses(path string, data []byte) bool {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if isYAMLInclude(path) {
+		var doc any
+		return yaml.Unmarshal(data, &doc) == nil
+	}
+	return json.Valid(data)
+}
+
+// jsonError adds where a syntax or type error in a hand-edited JSON file is, as name:line:column,
+// followed by the offending line with a caret under the column
+func jsonError(name string, data []byte, err error) error {
+	if name == stdinPath {
+		name = "stdin"
+	}
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	// Offset counts the bytes read up to and including the offending one
+	pos := int(min(max(offset-1, 0), int64(len(data))))
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	end := bytes.IndexByte(data[pos:], '\n')
+	if end < 0 {
+		end = len(data)
+	} else {
+		end += pos
+	}
+	line := bytes.Count(data[:pos], []byte("\n")) + 1
+	before := []rune(string(data[start:pos]))
+	text := []rune(strings.TrimRight(string(data[start:end]), "\r"))
+	col := len(before) + 1
+	// Long lines are trimmed to a window around the column
+	const window = 60
+	if len(before) > window {
+		cut := len(before) - window
+		before, text = before[cut:], text[cut:]
+	}
+	if len(text) > len(before)+window {
+		text = text[:len(before)+window]
+	}
+	caret := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, string(before))
+	return fmt.Errorf("%s:%d:%d: %w\n    %s\n    %s^", name, line, col, err, string(text), caret)
+}
+
 // LoadConfig reads and parses the config file
 func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	data, err := readInput(path)
 	if err != nil {
 		return nil, err
 	}
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+		return nil, jsonError(path, data, err)
 	}
 
 	// --- Validation --- 
@@ -46,6 +310,31 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.Modpacks = make(map[string]ModpackConfig)
 	}
 
+	// Merge packs from included files, remembering where each came from so SaveConfig can write it back
+	cfg.path = path
+	cfg.packSources = make(map[string]string)
+	cfg.includeData = make(map[string][]byte)
+	for _, inc := range cfg.Include {
+		incData, err := readInput(includePath(path, inc))
+		if err != nil {
+			return nil, fmt.Errorf("config include %q: %w", inc, err)
+		}
+		f, err := decodeInclude(inc, incData)
+		if err != nil {
+			return nil, fmt.Errorf("config include: %w", err)
+		}
+		if cfg.includeData[inc], err = encodeInclude(inc, f.Modpacks); err != nil {
+			return nil, fmt.Errorf("config include %q: %w", inc, err)
+		}
+		for name, packCfg := range f.Modpacks {
+			if _, dup := cfg.Modpacks[name]; dup {
+				return nil, fmt.Errorf("config validation failed: modpack %q is defined in both %s and %s", name, ternary(cfg.packSources[name] != "", cfg.packSources[name], path), inc)
+			}
+			cfg.Modpacks[name] = packCfg
+			cfg.packSources[name] = inc
+		}
+	}
+
 	for name, packCfg := range cfg.Modpacks {
 		if packCfg.MCVersion == "" {
 			return nil, fmt.Errorf("config validation failed: modpack %q is missing 'mc_version'", name)
@@ -53,25 +342,195 @@ func LoadConfig(path string) (*Config, error) {
 		if packCfg.Loader == "" {
 			return nil, fmt.Errorf("config validation failed: modpack %q is missing 'loader'", name)
 		}
+		if _, ok := channelRank[packCfg.Channel]; packCfg.Channel != "" && !ok {
+			return nil, fmt.Errorf("config validation failed: modpack %q has unknown 'channel' %q (want release, beta or alpha)", name, packCfg.Channel)
+		}
+		if packCfg.JavaVersion < 0 {
+			return nil, fmt.Errorf("config validation failed: modpack %q has invalid 'java_version' %d", name, packCfg.JavaVersion)
+		}
+		for slug, channel := range packCfg.Channels {
+			if _, ok := channelRank[channel]; channel != "" && !ok {
+				return nil, fmt.Errorf("config validation failed: modpack %q has unknown channel %q for %s (want release, beta or alpha)", name, channel, slug)
+			}
+		}
+		for _, group := range packCfg.Inherits {
+			if _, ok := cfg.Shared[group]; !ok {
+				return nil, fmt.Errorf("config validation failed: modpack %q inherits unknown shared group %q", name, group)
+			}
+		}
 		// Note: We don't validate if the version/loader combo is *correct*, just that they exist.
 	}
+	if cfg.DownloadMirror != "" {
+		if _, err := parseMirror(cfg.DownloadMirror); err != nil {
+			return nil, fmt.Errorf("config validation failed: %w", err)
+		}
+	}
+	for i, c := range cfg.Conflicts {
+		if len(c.Mods) < 2 {
+			return nil, fmt.Errorf("config validation failed: conflicts entry %d names %d mod(s); a conflict needs at least two", i+1, len(c.Mods))
+		}
+	}
+	if err := checkDownloadHeaders(cfg.DownloadHeaders); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	if cfg.ActivePack != "" {
+		if _, ok := cfg.Modpacks[cfg.ActivePack]; !ok {
+			return nil, fmt.Errorf("config validation failed: active_pack %q is not a defined modpack", cfg.ActivePack)
+		}
+	}
 	// --- End Validation ---
 
 	return &cfg, nil
 }
 
-// SaveConfig writes the config structure back to the file
+// EffectiveMods returns the pack's own mods followed by those of its inherited groups, without duplicates
+func (c *Config) EffectiveMods(pack ModpackConfig) []string {
+	seen := make(map[string]bool)
+	var mods []string
+	for _, slug := range pack.Slugs() {
+		if !seen[slug] {
+			seen[slug] = true
+			mods = append(mods, slug)
+		}
+	}
+	for _, group := range pack.Inherits {
+		for _, slug := range c.Shared[group] {
+			if !seen[slug] {
+				seen[slug] = true
+				mods = append(mods, slug)
+			}
+		}
+	}
+	return mods
+}
+
+// InheritedFrom returns the shared group that contributes slug to the pack, or "" if it isn't inherited
+func (c *Config) InheritedFrom(pack ModpackConfig, slug string) string {
+	for _, group := range pack.Inherits {
+		for _, m := range c.Shared[group] {
+			if m == slug {
+				return group
+			}
+		}
+	}
+	return ""
+}
+
+// SaveConfig writes the config structure back to the file.
+// Packs loaded from an include file are written back to that file instead, found relative to the
+// config as it was loaded (not path, which --output may point elsewhere), and only when they changed.
 func SaveConfig(path string, cfg *Config) error {
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	if cfg.SortMods {
+		cfg = sortedConfig(cfg)
+	}
+	mainCfg := *cfg
+	mainCfg.Modpacks = make(map[string]ModpackConfig)
+	included := make(map[string]map[string]ModpackConfig)
+	for _, inc := range cfg.Include {
+		included[inc] = make(map[string]ModpackConfig)
+	}
+	for name, packCfg := range cfg.Modpacks {
+		src, ok := cfg.packSources[name]
+		if !ok {
+			mainCfg.Modpacks[name] = packCfg
+			continue
+		}
+		if included[src] == nil {
+			included[src] = make(map[string]ModpackConfig)
+		}
+		included[src][name] = packCfg
+	}
+
+	base := cfg.path
+	if base == "" {
+		base = path
+	}
+	if cfg.includeData == nil {
+		cfg.includeData = make(map[string][]byte)
+	}
+	for _, inc := range sortedKeys(included) {
+		data, err := encodeInclude(inc, included[inc])
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(data, cfg.includeData[inc]) {
+			continue
+		}
+		incPath := includePath(base, inc)
+		if isRemote(incPath) {
+			return fmt.Errorf("config include %q was fetched from %s and is read-only", inc, incPath)
+		}
+		if err := os.WriteFile(incPath, data, 0644); err != nil {
+			return err
+		}
+		cfg.includeData[inc] = data
+	}
+	data, err := json.MarshalIndent(&mainCfg, "", "  ")
 	if err != nil {
 		return err
 	}
 	return os.WriteFile(path, data, 0644)
 }
 
+// sortedConfig returns a copy of cfg with every mod list, shared group and inherits list sorted and deduplicated.
+// Maps need no help: encoding/json already writes their keys in sorted order.
+func sortedConfig(cfg *Config) *Config {
+	out := *cfg
+	out.Modpacks = make(map[string]ModpackConfig, len(cfg.Modpacks))
+	for name, packCfg := range cfg.Modpacks {
+		packCfg.Mods = sortedEntries(packCfg.Mods)
+		packCfg.Inherits = sortedUnique(packCfg.Inherits)
+		packCfg.Frozen = sortedUnique(packCfg.Frozen)
+		out.Modpacks[name] = packCfg
+	}
+	if cfg.Shared != nil {
+		out.Shared = make(map[string][]string, len(cfg.Shared))
+		for group, mods := range cfg.Shared {
+			out.Shared[group] = sortedUnique(mods)
+		}
+	}
+	return &out
+}
+
+// sortedUnique returns a sorted copy of list without duplicates, preserving nil vs empty
+func sortedUnique(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	out := slices.Clone(list)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// sortedEntries is sortedUnique for mod lists; of duplicate slugs the first entry (and its note) wins
+func sortedEntries(list []ModEntry) []ModEntry {
+	if list == nil {
+		return nil
+	}
+	out := slices.Clone(list)
+	slices.SortStableFunc(out, func(a, b ModEntry) int { return strings.Compare(a.Slug, b.Slug) })
+	return slices.CompactFunc(out, func(a, b ModEntry) bool { return a.Slug == b.Slug })
+}
+
+// includePath resolves an include entry relative to the directory of the main config file
+func includePath(configPath, inc string) string {
+	if isRemote(configPath) {
+		// Relative to the config's URL, like a link
+		base, err := url.Parse(configPath)
+		if ref, refErr := url.Parse(inc); err == nil && refErr == nil {
+			return base.ResolveReference(ref).String()
+		}
+		return inc
+	}
+	if filepath.IsAbs(inc) {
+		return inc
+	}
+	return filepath.Join(filepath.Dir(configPath), inc)
+}
+
 // LoadState reads and parses the state file
 func LoadState(path string) (State, error) {
-	data, err := os.ReadFile(path)
+	data, err := readInput(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// If state file doesn't exist, return an empty state map
@@ -79,6 +538,9 @@ func LoadState(path string) (State, error) {
 		}
 		return nil, err
 	}
+	if path == stdinPath && len(bytes.TrimSpace(data)) == 0 {
+		return make(State), nil
+	}
 	var state State
 	if err := json.Unmarshal(data, &state); err != nil {
 		// Attempt to load old format (map[string]map[string]string) for backward compatibility
@@ -95,14 +557,23 @@ func LoadState(path string) (State, error) {
 			return newState, nil
 		}
 		// If neither new nor old format works, return the original error
-		return nil, fmt.Errorf("failed to unmarshal state file %s: %w", path, err)
+		return nil, fmt.Errorf("failed to parse state file: %w", jsonError(path, data, err))
 	}
 	return state, nil
 }
 
+// compactState (--compact-state or the config's compact_state) writes state.json without indentation
+var compactState bool
+
 // SaveState writes the state structure back to the file
 func SaveState(path string, state State) error {
-	data, err := json.MarshalIndent(state, "", "  ")
+	var data []byte
+	var err error
+	if compactState {
+		data, err = json.Marshal(state)
+	} else {
+		data, err = json.MarshalIndent(state, "", "  ")
+	}
 	if err != nil {
 		return err
 	}
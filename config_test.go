@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncludeFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.json"), `{"include": ["packs/a.json", "packs/b.yaml"], "modpacks": {"main": {"mc_version": "1.21.1", "loader": "fabric", "mods": ["sodium"]}}}`)
+	os.Mkdir(filepath.Join(dir, "packs"), 0755)
+	aJSON := `{"modpacks": {"a": {"mc_version": "1.20.1", "loader": "forge", "mods": ["jei"]}}}`
+	writeFile(t, filepath.Join(dir, "packs", "a.json"), aJSON)
+	bYAML := "# server pack\nmodpacks:\n  b:\n    mc_version: \"1.20\"\n    loader: fabric\n    mods:\n      - lithium\n      - slug: ferrite-core\n        note: memory\n"
+	writeFile(t, filepath.Join(dir, "packs", "b.yaml"), bYAML)
+
+	cfg, err := LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := cfg.Modpacks["b"]
+	if b.MCVersion != "1.20" || b.Loader != "fabric" || len(b.Mods) != 2 || b.Mods[1].Slug != "ferrite-core" || b.Mods[1].Note != "memory" {
+		t.Fatalf("YAML include loaded as %+v", b)
+	}
+	if cfg.packSources["a"] != "packs/a.json" || cfg.packSources["b"] != "packs/b.yaml" {
+		t.Fatalf("pack sources = %v", cfg.packSources)
+	}
+
+	// Saving elsewhere, as --output does, neither rewrites the includes nor creates copies next to
+	// the output; a changed pack goes back to the include it came from
+	out := filepath.Join(t.TempDir(), "out.json")
+	a := cfg.Modpacks["a"]
+	a.Mods = append(a.Mods, ModEntry{Slug: "appleskin"})
+	cfg.Modpacks["a"] = a
+	if err := SaveConfig(out, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(out), "packs")); !os.IsNotExist(err) {
+		t.Errorf("saving to %s wrote include files next to it", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "packs", "b.yaml")); string(data) != bYAML {
+		t.Errorf("unchanged YAML include was rewritten:\n%s", data)
+	}
+	reloaded, err := LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Modpacks["a"].Slugs(); len(got) != 2 || got[1] != "appleskin" {
+		t.Errorf("changed pack saved as %v, want [jei appleskin] in packs/a.json", got)
+	}
+
+	// A changed YAML pack is written back as YAML that loads the same
+	b.Mods = b.Mods[:1]
+	reloaded.Modpacks["b"] = b
+	if err := SaveConfig(filepath.Join(dir, "config.json"), reloaded); err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.Modpacks["b"]; got.MCVersion != "1.20" || len(got.Mods) != 1 || got.Mods[0].Slug != "lithium" {
+		t.Errorf("YAML include saved and reloaded as %+v", got)
+	}
+}
+
+func TestIncludeDuplicatePack(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.json"), `{"include": ["a.yml"], "modpacks": {"a": {"mc_version": "1.21.1", "loader": "fabric"}}}`)
+	writeFile(t, filepath.Join(dir, "a.yml"), "modpacks:\n  a:\n    mc_version: 1.20.1\n    loader: forge\n")
+	if _, err := LoadConfig(filepath.Join(dir, "config.json")); err == nil {
+		t.Error("a pack defined in both config.json and a.yml loaded without error")
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// checkGolden compares got with testdata/name, or rewrites it under -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (rerun with -update if the change is intended):\n%s", path, got)
+	}
+}
+
+// saveTwice saves the same data twice through save and returns both results
+func saveTwice(t *testing.T, save func(path string) error) (first, second []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out.json")
+	for _, out := range []*[]byte{&first, &second} {
+		if err := save(path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*out = data
+	}
+	return first, second
+}
+
+func TestSaveConfigGolden(t *testing.T) {
+	for _, tt := range []struct {
+		golden   string
+		sortMods bool
+	}{
+		{"config.unsorted.golden", false},
+		{"config.sorted.golden", true},
+	} {
+		t.Run(tt.golden, func(t *testing.T) {
+			cfg, err := LoadConfig(filepath.Join("testdata", "config.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.SortMods = tt.sortMods
+			first, second := saveTwice(t, func(path string) error { return SaveConfig(path, cfg) })
+			if !bytes.Equal(first, second) {
+				t.Fatalf("saving the same config twice gave different bytes:\n%s\n---\n%s", first, second)
+			}
+			checkGolden(t, tt.golden, first)
+		})
+	}
+}
+
+func TestSaveStateGolden(t *testing.T) {
+	defer func(old bool) { compactState = old }(compactState)
+	for _, tt := range []struct {
+		golden  string
+		compact bool
+	}{
+		{"state.golden", false},
+		{"state.compact.golden", true},
+	} {
+		t.Run(tt.golden, func(t *testing.T) {
+			state, err := LoadState(filepath.Join("testdata", "state.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compactState = tt.compact
+			first, second := saveTwice(t, func(path string) error { return SaveState(path, state) })
+			if !bytes.Equal(first, second) {
+				t.Fatalf("saving the same state twice gave different bytes:\n%s\n---\n%s", first, second)
+			}
+			checkGolden(t, tt.golden, first)
+		})
+	}
+}
+
+func TestJSONError(t *testing.T) {
+	long := `{"modpacks": {"` + strings.Repeat("a", 80) + `": {"mc_version": 1.21, "loader": "fabric", "mods": [], "notes": "` + strings.Repeat("b", 80) + `"}}}`
+	tests := []struct {
+		name string
+		data string
+		want string // the error from its position on: name:line:column, the line and the caret
+	}{
+		{
+			name: "syntax error on line 1",
+			data: `{"modpacks": {,}}`,
+			want: "config.json:1:15: invalid character ',' looking for beginning of object key string\n" +
+				"    {\"modpacks\": {,}}\n" +
+				"                  ^",
+		},
+		{
+			name: "syntax error on line N",
+			data: "{\n  \"modpacks\": {\n    \"a\": {\"mc_version\" \"1.21\"}\n  }\n}\n",
+			want: "config.json:3:24: invalid character '\"' after object key\n" +
+				"        \"a\": {\"mc_version\" \"1.21\"}\n" +
+				"                           ^",
+		},
+		{
+			name: "type error, placed at the end of the value",
+			data: "{\n  \"modpacks\": {\"a\": {\"mc_version\": 1.21}}\n}",
+			want: "config.json:2:39: json: cannot unmarshal number into Go struct field Config.modpacks.a.mc_version of type string\n" +
+				"      \"modpacks\": {\"a\": {\"mc_version\": 1.21}}\n" +
+				"                                          ^",
+		},
+		{
+			name: "line longer than the window",
+			data: long,
+			want: "config.json:1:117: json: cannot unmarshal number into Go struct field Config.modpacks." + strings.Repeat("a", 80) + ".mc_version of type string\n" +
+				"    " + long[56:56+120] + "\n" +
+				"    " + strings.Repeat(" ", 60) + "^",
+		},
+		{
+			name: "tabs",
+			data: "{\n\t\"modpacks\": {\n\t\t\"a\": {\"mods\": [,]}\n\t}\n}",
+			want: "config.json:3:18: invalid character ',' looking for beginning of value\n" +
+				"    \t\t\"a\": {\"mods\": [,]}\n" +
+				"    \t\t               ^",
+		},
+		{
+			name: "CRLF",
+			data: "{\r\n  \"modpacks\": {\r\n    \"a\": {\"mods\": [,]}\r\n  }\r\n}\r\n",
+			want: "config.json:3:20: invalid character ',' looking for beginning of value\n" +
+				"        \"a\": {\"mods\": [,]}\n" +
+				"                       ^",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.data)
+			var cfg Config
+			err := jsonError("config.json", data, json.Unmarshal(data, &cfg))
+			if got := err.Error(); got != tt.want {
+				t.Errorf("jsonError =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigBOM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, path, "\ufeff"+`{"modpacks": {"a": {"mc_version": "1.21.1", "loader": "fabric", "mods": ["sodium"]}}}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig of a config starting with a BOM: %v", err)
+	}
+	if got := cfg.Modpacks["a"].MCVersion; got != "1.21.1" {
+		t.Errorf("mc_version = %q, want 1.21.1", got)
+	}
+
+	// Positions in errors are counted from after the BOM
+	writeFile(t, path, "\ufeff"+`{"modpacks": {,}}`)
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "config.json:1:15:") {
+		t.Errorf("LoadConfig error = %v, want it at 1:15", err)
+	}
+}
+
+func TestRemoteYAMLIncludeCached(t *testing.T) {
+	requests := make(map[string]int)
+	srv := newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/config.json":
+			w.Write([]byte(`{"include": ["pack.yaml"]}`))
+		case "/pack.yaml":
+			w.Write([]byte("modpacks:\n  b:\n    mc_version: \"1.20.1\"\n    loader: fabric\n    mods: [lithium]\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	cacheTTL = defaultCacheTTL // restored by newTestAPI
+	for i := 0; i < 2; i++ {
+		cfg, err := LoadConfig(srv.URL + "/config.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cfg.Modpacks["b"]; !ok {
+			t.Fatalf("pack from the YAML include missing: %+v", cfg.Modpacks)
+		}
+	}
+	if requests["/config.json"] != 1 || requests["/pack.yaml"] != 1 {
+		t.Errorf("requests %v, want the config and its YAML include fetched once each", requests)
+	}
+}
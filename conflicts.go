@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// KnownConflict is an entry of a known-incompatibilities list: mods that break when installed
+// together, such as two that provide the same feature, beyond what their versions declare
+// themselves. Two or more of Mods in one pack is a conflict.
+type KnownConflict struct {
+	Mods   []string `json:"mods"`
+	Reason string   `json:"reason"`
+}
+
+// conflictList is the format of a conflicts.json file
+type conflictList struct {
+	Conflicts []KnownConflict `json:"conflicts"`
+}
+
+// loadConflicts returns the known conflicts from every conflict_lists entry followed by the config's
+// own conflicts. Lists are JSON files or http(s) URLs, relative paths resolved like include.
+func loadConflicts(cfg *Config) ([]KnownConflict, error) {
+	var all []KnownConflict
+	for _, src := range cfg.ConflictLists {
+		var data []byte
+		var err error
+		if isRemote(src) {
+			data, err = cachedGet(src)
+		} else {
+			p := includePath(cfgFile, src)
+			if isRemote(p) {
+				data, err = cachedGet(p)
+			} else {
+				data, err = os.ReadFile(p)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("conflict list %q: %w", src, err)
+		}
+		var list conflictList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("conflict list %q: %w", src, err)
+		}
+		all = append(all, list.Conflicts...)
+	}
+	return append(all, cfg.Conflicts...), nil
+}
+
+// conflictHit is a known conflict found in a pack: the conflicting mods it has
+type conflictHit struct {
+	Mods   []string
+	Reason string
+}
+
+// packConflicts returns the conflicts with at least two of their mods among mods, in list order
+func packConflicts(conflicts []KnownConflict, mods []string) []conflictHit {
+	var hits []conflictHit
+	for _, c := range conflicts {
+		var present []string
+		for _, slug := range c.Mods {
+			if slices.Contains(mods, slug) && !slices.Contains(present, slug) {
+				present = append(present, slug)
+			}
+		}
+		if len(present) >= 2 {
+			hits = append(hits, conflictHit{Mods: present, Reason: c.Reason})
+		}
+	}
+	return hits
+}
+
+// installedMods is what a pack installs: its listed mods and the dependencies update added for them
+func installedMods(cfg *Config, state State, packName string) []string {
+	listed := cfg.EffectiveMods(cfg.Modpacks[packName])
+	return append(slices.Clone(listed), autoDeps(state[packName], listed)...)
+}
+
+// conflictIssues reports the known conflicts in every pack for doctor. They need a person to pick
+// which mod to keep, so none is fixable.
+func conflictIssues(cfg *Config, state State) []doctorIssue {
+	conflicts, err := loadConflicts(cfg)
+	if err != nil {
+		return []doctorIssue{{Problem: "could not load known conflicts", Remedy: err.Error()}}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	var issues []doctorIssue
+	for _, name := range sortedPackNames(cfg) {
+		for _, hit := range packConflicts(conflicts, installedMods(cfg, state, name)) {
+			issues = append(issues, doctorIssue{
+				Pack:    name,
+				Problem: fmt.Sprintf("known conflict between %s: %s", strings.Join(hit.Mods, ", "), hit.Reason),
+				Remedy:  "remove all but one of them from the pack",
+			})
+		}
+	}
+	return issues
+}
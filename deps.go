@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+// depResolver follows the required dependencies of the versions update resolves. Dependencies
+// the pack doesn't list are handed back to be installed like any other mod, and each dependency's
+// dependents are recorded in state as required_by.
+type depResolver struct {
+	slugByID   map[string]string
+	requiredBy map[string][]string // dependency slug -> slugs whose versions require it
+	read       map[string]bool     // slugs whose dependencies were read this run
+}
+
+func newDepResolver() *depResolver {
+	return &depResolver{slugByID: make(map[string]string), requiredBy: make(map[string][]string), read: make(map[string]bool)}
+}
+
+// add records which mods ver, slug's version, requires and returns those not yet among mods, for
+// the caller to queue. Dependencies whose project can't be looked up are reported to w and left out.
+func (r *depResolver) add(w io.Writer, slug string, ver *Version, mods []string) []string {
+	r.read[slug] = true
+	var queue []string
+	for _, dep := range ver.Dependencies {
+		if dep.DependencyType != "required" {
+			continue
+		}
+		projectID := dep.ProjectID
+		if projectID == "" && dep.VersionID != "" {
+			v, err := FetchVersion(dep.VersionID)
+			if err != nil {
+				fmt.Fprintf(w, "  ⚠ Could not look up required version %s: %v\n", dep.VersionID, err)
+				continue
+			}
+			projectID = v.ProjectID
+		}
+		if projectID == "" {
+			continue
+		}
+		depSlug, ok := r.slugByID[projectID]
+		if !ok {
+			proj, err := FetchProject(projectID)
+			if err != nil {
+				fmt.Fprintf(w, "  ⚠ Could not look up required dependency %s: %v\n", projectID, err)
+				continue
+			}
+			depSlug = proj.Slug
+			r.slugByID[projectID] = depSlug
+		}
+		if depSlug == slug || slices.Contains(r.requiredBy[depSlug], slug) {
+			continue
+		}
+		r.requiredBy[depSlug] = append(r.requiredBy[depSlug], slug)
+		if !slices.Contains(mods, depSlug) && !slices.Contains(queue, depSlug) {
+			queue = append(queue, depSlug)
+		}
+	}
+	return queue
+}
+
+// apply writes required_by into packState and drops installed dependencies nothing requires any
+// more. Dependents whose dependencies weren't read this run (a failed lookup, say) keep their
+// existing entries. listed is the pack's own mods; it reports whether packState changed.
+func (r *depResolver) apply(w io.Writer, packState map[string]ModState, listed []string) bool {
+	changed := false
+	for _, slug := range sortedKeys(packState) {
+		ms := packState[slug]
+		want := slices.Clone(r.requiredBy[slug])
+		for _, dependent := range ms.RequiredBy {
+			if _, ok := packState[dependent]; ok && !r.read[dependent] && !slices.Contains(want, dependent) {
+				want = append(want, dependent)
+			}
+		}
+		want = sortedUnique(want)
+		if len(want) == 0 && len(ms.RequiredBy) > 0 && !slices.Contains(listed, slug) {
+			fmt.Fprintf(w, "  − %s was only installed as a dependency and nothing requires it any more; dropped from state (sync or --prune removes its file)\n", slug)
+			delete(packState, slug)
+			changed = true
+			continue
+		}
+		if !slices.Equal(ms.RequiredBy, want) {
+			ms.RequiredBy = want
+			packState[slug] = ms
+			changed = true
+		}
+	}
+	return changed
+}
+
+// autoDeps returns the mods in packState that were installed only as dependencies: required by
+// another mod, but not among listed
+func autoDeps(packState map[string]ModState, listed []string) []string {
+	var deps []string
+	for _, slug := range sortedKeys(packState) {
+		if len(packState[slug].RequiredBy) > 0 && !slices.Contains(listed, slug) {
+			deps = append(deps, slug)
+		}
+	}
+	return deps
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// How output names a Modrinth version, set by --version-display. Throughout the output "version"
+// is the author's version number (0.5.1) and "version ID" Modrinth's stable ID (AANobbMI); state,
+// pins and every comparison use IDs no matter how they are shown.
+const (
+	displayNumber = "number" // 0.5.1, the default
+	displayID     = "id"     // AANobbMI
+	displayBoth   = "both"   // 0.5.1 (AANobbMI)
+)
+
+var versionDisplay = displayNumber
+
+// checkVersionDisplay validates --version-display
+func checkVersionDisplay(mode string) error {
+	switch mode {
+	case displayNumber, displayID, displayBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown --version-display %q (want number, id or both)", mode)
+}
+
+// showVersion formats a version for output according to --version-display. Without a number
+// (an ID Modrinth no longer knows) it falls back to the ID.
+func showVersion(number, id string) string {
+	switch {
+	case number == "" || versionDisplay == displayID:
+		return id
+	case versionDisplay == displayBoth && id != "":
+		return fmt.Sprintf("%s (%s)", number, id)
+	}
+	return number
+}
+
+// showVer is showVersion for a fetched version
+func showVer(v *Version) string {
+	return showVersion(v.VersionNumber, v.ID)
+}
+
+// versionNumbers remembers the numbers showVersionID looked up this run, "" for IDs that failed,
+// so a version shown on several lines costs one lookup
+var (
+	versionNumbers   = make(map[string]string)
+	versionNumbersMu sync.Mutex
+)
+
+// showVersionID formats a version known only by its ID, such as a pin, looking its number up
+// (once per run) when the display policy shows one
+func showVersionID(id string) string {
+	if id == "" || versionDisplay == displayID {
+		return id
+	}
+	versionNumbersMu.Lock()
+	number, ok := versionNumbers[id]
+	versionNumbersMu.Unlock()
+	if !ok {
+		if v, err := FetchVersion(id); err == nil {
+			number = v.VersionNumber
+		}
+		versionNumbersMu.Lock()
+		versionNumbers[id] = number
+		versionNumbersMu.Unlock()
+	}
+	return showVersion(number, id)
+}
+
+// showInstalled formats the version a state entry records, using the number saved with it when
+// there is one. Entries written before state kept numbers are looked up by ID.
+func showInstalled(ms ModState) string {
+	if ms.VersionNumber != "" {
+		return showVersion(ms.VersionNumber, ms.VersionID)
+	}
+	return showVersionID(ms.VersionID)
+}
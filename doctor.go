@@ -0,0 +1,303 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// loaderAliases maps spellings seen in hand-written configs to the loader names Modrinth uses
+var loaderAliases = map[string]string{
+	"fabricmc":       "fabric",
+	"quiltmc":        "quilt",
+	"minecraftforge": "forge",
+	"neo":            "neoforge",
+	"neoforged":      "neoforge",
+	"neo-forge":      "neoforge",
+}
+
+// canonicalLoader lowercases loader and resolves known aliases
+func canonicalLoader(loader string) string {
+	l := strings.ToLower(strings.TrimSpace(loader))
+	if alias, ok := loaderAliases[l]; ok {
+		return alias
+	}
+	return l
+}
+
+// doctorIssue is one problem found by diagnose. fix is nil when it needs manual attention;
+// destructive fixes throw information away and only run with --yes.
+type doctorIssue struct {
+	Pack        string
+	Slug        string
+	Problem     string
+	Remedy      string // what fix does, or what to do by hand
+	fix         func() error
+	destructive bool
+	editsConfig bool // fix changes the config rather than the state
+}
+
+// diagnose checks the config and state for problems. Fixes close over cfg and state,
+// so the caller saves both after running them.
+func diagnose(cfg *Config, state State) []doctorIssue {
+	var issues []doctorIssue
+
+	for _, name := range sortedPackNames(cfg) {
+		packCfg := cfg.Modpacks[name]
+		if want := canonicalLoader(packCfg.Loader); want != packCfg.Loader {
+			issues = append(issues, doctorIssue{
+				Pack:    name,
+				Problem: fmt.Sprintf("loader %q is not a Modrinth loader name", packCfg.Loader),
+				Remedy:  fmt.Sprintf("set loader to %q", want),
+				fix: func() error {
+					p := cfg.Modpacks[name]
+					p.Loader = want
+					cfg.Modpacks[name] = p
+					return nil
+				},
+				editsConfig: true,
+			})
+		}
+
+		if warning, err := LegacyMCWarning(packCfg.MCVersion); err == nil && warning != "" {
+			issues = append(issues, doctorIssue{Pack: name, Problem: "mc_version is a legacy release", Remedy: warning})
+		}
+
+		mods := cfg.EffectiveMods(packCfg)
+		dir := filepath.Join(modsDir, name)
+		for _, slug := range sortedKeys(state[name]) {
+			ms := state[name][slug]
+			if !slices.Contains(mods, slug) && len(ms.RequiredBy) == 0 {
+				issues = append(issues, doctorIssue{
+					Pack:        name,
+					Slug:        slug,
+					Problem:     "in state but no longer in the pack",
+					Remedy:      "remove the state entry",
+					fix:         func() error { delete(state[name], slug); return nil },
+					destructive: true,
+				})
+				continue
+			}
+			if ms.SkipEnv != "" {
+				continue // deliberately not installed
+			}
+			if strings.TrimSpace(ms.VersionID) == "" && ms.Filename != "" {
+				// Left by old-format migrations or hand edits; the file itself says which version it is
+				path := filepath.Join(dir, ms.Filename)
+				issue := doctorIssue{
+					Pack:    name,
+					Slug:    slug,
+					Problem: "state entry has no version_id",
+					Remedy:  fmt.Sprintf("identify %s by its hash and record its version", ms.Filename),
+					fix: func() error {
+						ver, sum, err := identifyFile(path, slug)
+						if err != nil {
+							return err
+						}
+						ms.VersionID, ms.SHA512 = ver.ID, sum
+						state[name][slug] = ms
+						return nil
+					},
+				}
+				if _, err := os.Stat(path); err != nil {
+					issue.Remedy = fmt.Sprintf("file %s is missing too; run 'modpilot update %s' to redownload it", path, name)
+					issue.fix = nil
+				}
+				issues = append(issues, issue)
+				continue
+			}
+			if ms.VersionID == "" || ms.Filename == "" {
+				issues = append(issues, doctorIssue{
+					Pack:    name,
+					Slug:    slug,
+					Problem: "state entry has no version_id or filename",
+					Remedy:  fmt.Sprintf("run 'modpilot update %s' to redownload it", name),
+				})
+				continue
+			}
+			path := filepath.Join(dir, ms.Filename)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				issues = append(issues, doctorIssue{
+					Pack:    name,
+					Slug:    slug,
+					Problem: fmt.Sprintf("file %s is missing", path),
+					Remedy:  fmt.Sprintf("restore version %s (rename a matching jar or redownload it)", showInstalled(ms)),
+					fix: func() error {
+						// A copy saved under another name only needs renaming
+						if found, err := repairFilename(dir, ms); err != nil || found != "" {
+							return err
+						}
+						return redownloadState(state, name, slug, dir)
+					},
+				})
+			} else if err != nil {
+				issues = append(issues, doctorIssue{Pack: name, Slug: slug, Problem: fmt.Sprintf("cannot check %s: %v", path, err), Remedy: "check the file's permissions"})
+			} else if ms.SHA512 != "" {
+				if sum, err := fileSHA512(path); err == nil && sum != ms.SHA512 {
+					issues = append(issues, doctorIssue{
+						Pack:    name,
+						Slug:    slug,
+						Problem: fmt.Sprintf("file %s does not match its recorded hash", path),
+						Remedy:  fmt.Sprintf("run 'modpilot update %s' to replace it", name),
+					})
+				}
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(state) {
+		if _, ok := cfg.Modpacks[name]; !ok {
+			issues = append(issues, doctorIssue{
+				Pack:        name,
+				Problem:     "in state but not defined in the config",
+				Remedy:      "remove the pack's state",
+				fix:         func() error { delete(state, name); return nil },
+				destructive: true,
+			})
+		}
+	}
+	return issues
+}
+
+// deepIssues opens every jar in each pack's mods directory as a zip archive and flags those whose
+// central directory can't be read, whatever state says about them. Jars state records can be
+// redownloaded by --fix; the rest need removing by hand.
+func deepIssues(cfg *Config, state State) []doctorIssue {
+	var issues []doctorIssue
+	for _, name := range sortedPackNames(cfg) {
+		dir := filepath.Join(modsDir, name)
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue // nothing installed yet; a missing file is reported by diagnose
+		}
+		owners := make(map[string]string) // filename -> slug
+		for slug, ms := range state[name] {
+			if ms.Filename != "" {
+				owners[ms.Filename] = slug
+			}
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(strings.ToLower(f.Name()), ".jar") {
+				continue
+			}
+			path := filepath.Join(dir, f.Name())
+			zr, err := zip.OpenReader(path)
+			if err == nil {
+				zr.Close()
+				continue
+			}
+			issue := doctorIssue{
+				Pack:    name,
+				Problem: fmt.Sprintf("%s is not a valid jar: %v", path, err),
+				Remedy:  "delete it or replace it by hand; state doesn't say which mod it is",
+			}
+			if slug, ok := owners[f.Name()]; ok && state[name][slug].VersionID != "" {
+				issue.Slug = slug
+				issue.Remedy = fmt.Sprintf("redownload version %s", showInstalled(state[name][slug]))
+				issue.fix = func() error { return redownloadState(state, name, slug, dir) }
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// sortedKeys returns the keys of m in order, for stable output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// abandonedAge is how far a mod's newest build may trail the newest Minecraft release before
+// doctor --abandoned looks for a successor
+const abandonedAge = 365 * 24 * time.Hour
+
+// abandonedIssues flags mods whose newest build, for any MC version or loader, trails the newest
+// Minecraft release by more than abandonedAge, and searches Modrinth by the project's title for
+// recently updated projects that may have replaced it. The result is advisory; nothing is fixed.
+func abandonedIssues(cfg *Config) []doctorIssue {
+	tags, err := FetchGameVersions()
+	if err != nil {
+		return []doctorIssue{{Problem: "could not check for abandoned mods", Remedy: err.Error()}}
+	}
+	var newest *GameVersion
+	for i := range tags {
+		if tags[i].VersionType == "release" && (newest == nil || tags[i].Date.After(newest.Date)) {
+			newest = &tags[i]
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+
+	verdicts := make(map[string]*doctorIssue) // by slug, so mods shared between packs are looked up once
+	var issues []doctorIssue
+	for _, name := range listedPackNames(cfg, false) {
+		for _, slug := range cfg.EffectiveMods(cfg.Modpacks[name]) {
+			v, checked := verdicts[slug]
+			if !checked {
+				v = abandonedVerdict(slug, newest)
+				verdicts[slug] = v
+			}
+			if v != nil {
+				issue := *v
+				issue.Pack = name
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// abandonedVerdict returns the issue for one slug, or nil if it has recent builds or can't be checked
+func abandonedVerdict(slug string, newest *GameVersion) *doctorIssue {
+	versions, err := FetchAllVersions(slug)
+	if err != nil || len(versions) == 0 {
+		return nil // reported by update/check-updates already
+	}
+	var last time.Time
+	for _, v := range versions {
+		if v.DatePublished.After(last) {
+			last = v.DatePublished
+		}
+	}
+	if newest.Date.Sub(last) < abandonedAge {
+		return nil
+	}
+
+	issue := &doctorIssue{
+		Slug:    slug,
+		Problem: fmt.Sprintf("looks abandoned: the newest build is from %s, long before Minecraft %s", last.Format("2006-01-02"), newest.Version),
+		Remedy:  "no recently updated project with a similar name was found; check the mod's page for a successor",
+	}
+	proj, err := FetchProject(slug)
+	if err != nil {
+		return issue
+	}
+	hits, err := SearchProjects(proj.Title, 5)
+	if err != nil {
+		return issue
+	}
+	var successors []string
+	for _, h := range hits {
+		if h.ProjectID == proj.ID || h.Slug == slug {
+			continue
+		}
+		if h.DateModified.After(last) && newest.Date.Sub(h.DateModified) < abandonedAge {
+			successors = append(successors, fmt.Sprintf("%q (%s)", h.Title, h.Slug))
+		}
+	}
+	if len(successors) > 0 {
+		issue.Remedy = "may have a successor with recent builds: " + strings.Join(successors, ", ")
+	}
+	return issue
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// depGraph is the required-dependency graph of a pack, keyed by slug
+type depGraph struct {
+	Nodes   []string            // slugs in the order they were reached
+	InPack  map[string]bool     // nodes the pack lists itself; others are pulled in as dependencies
+	Missing map[string]string   // nodes that couldn't be resolved -> reason
+	Edges   map[string][]string // dependent -> required dependencies
+}
+
+// buildDepGraph resolves each of the pack's mods (its pin, or the latest compatible version) and follows
+// their required dependencies, including ones the pack doesn't list. Each project is visited once, so
+// cycles end the walk instead of looping, and lookups that fail become Missing nodes rather than errors.
+func buildDepGraph(cfg *Config, packCfg ModpackConfig) *depGraph {
+	g := &depGraph{InPack: make(map[string]bool), Missing: make(map[string]string), Edges: make(map[string][]string)}
+	seen := make(map[string]bool)
+	slugByID := make(map[string]string)
+
+	var visit func(slug string)
+	visit = func(slug string) {
+		if seen[slug] {
+			return
+		}
+		seen[slug] = true
+		g.Nodes = append(g.Nodes, slug)
+
+		var ver *Version
+		var err error
+		if pinID, pinned := packCfg.Pins[slug]; pinned {
+			ver, err = FetchVersion(pinID)
+		} else {
+			ver, err = FetchLatestVersionForChannel(slug, packCfg.MCVersion, packCfg.Loader, packCfg.ChannelFor(slug))
+		}
+		if err != nil {
+			g.Missing[slug] = err.Error()
+			return
+		}
+		for _, dep := range ver.Dependencies {
+			if dep.DependencyType != "required" || dep.ProjectID == "" {
+				continue
+			}
+			depSlug, ok := slugByID[dep.ProjectID]
+			if !ok {
+				if proj, err := FetchProject(dep.ProjectID); err == nil {
+					depSlug = proj.Slug
+				} else {
+					depSlug = dep.ProjectID
+					g.Missing[depSlug] = fmt.Sprintf("project lookup failed: %v", err)
+					seen[depSlug] = true
+					g.Nodes = append(g.Nodes, depSlug)
+				}
+				slugByID[dep.ProjectID] = depSlug
+			}
+			g.Edges[slug] = append(g.Edges[slug], depSlug)
+			visit(depSlug)
+		}
+	}
+
+	mods := cfg.EffectiveMods(packCfg)
+	for _, slug := range mods {
+		g.InPack[slug] = true
+	}
+	for _, slug := range mods {
+		visit(slug)
+	}
+	return g
+}
+
+// WriteDOT renders the graph for Graphviz. Dependencies the pack doesn't list are dashed,
+// unresolved ones red.
+func (g *depGraph) WriteDOT(w io.Writer, name string) {
+	fmt.Fprintf(w, "digraph %q {\n  rankdir=LR;\n", name)
+	for _, slug := range g.Nodes {
+		var attrs []string
+		if !g.InPack[slug] {
+			attrs = append(attrs, "style=dashed")
+		}
+		if _, missing := g.Missing[slug]; missing {
+			attrs = append(attrs, "color=red")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(w, "  %q [%s];\n", slug, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(w, "  %q;\n", slug)
+		}
+	}
+	for _, from := range g.Nodes {
+		for _, to := range g.Edges[from] {
+			fmt.Fprintf(w, "  %q -> %q;\n", from, to)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// WriteMermaid renders the graph as a Mermaid flowchart. Slugs are used as labels only,
+// since Mermaid node IDs can't contain every character a slug can.
+func (g *depGraph) WriteMermaid(w io.Writer) {
+	ids := make(map[string]string, len(g.Nodes))
+	fmt.Fprintln(w, "graph LR")
+	for i, slug := range g.Nodes {
+		ids[slug] = fmt.Sprintf("n%d", i)
+		label := slug
+		if !g.InPack[slug] {
+			label += " (dependency)"
+		}
+		if _, missing := g.Missing[slug]; missing {
+			label += " (unresolved)"
+		}
+		fmt.Fprintf(w, "  %s[%q]\n", ids[slug], label)
+	}
+	for _, from := range g.Nodes {
+		for _, to := range g.Edges[from] {
+			fmt.Fprintf(w, "  %s --> %s\n", ids[from], ids[to])
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// fileSHA512 returns the hex-encoded SHA-512 digest of the file at path
+func fileSHA512(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha512.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findByHash returns the name of a jar in dir whose SHA-512 is sum, or "" if none matches
+func findByHash(dir, sum string) (string, error) {
+	if sum == "" {
+		return "", nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	var jars []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".jar") {
+			jars = append(jars, filepath.Join(dir, e.Name()))
+		}
+	}
+	sums := hashFiles(jars)
+	for _, p := range jars {
+		if sums[p] == sum {
+			return filepath.Base(p), nil
+		}
+	}
+	return "", nil
+}
+
+// repairFilename looks for the recorded file under another name (e.g. one derived from the download URL)
+// and renames it to ms.Filename. It returns the name the file was found under, or "" if nothing matched.
+// Entries saved before state kept hashes are matched by the hash Modrinth publishes for the file.
+func repairFilename(dir string, ms ModState) (string, error) {
+	sum := ms.SHA512
+	if sum == "" && ms.VersionID != "" {
+		ver, err := FetchVersion(ms.VersionID)
+		if err != nil {
+			return "", err
+		}
+		if i := slices.IndexFunc(ver.Files, func(f VersionFile) bool { return f.Filename == ms.Filename }); i >= 0 {
+			sum = ver.Files[i].Hashes.SHA512
+		} else if file, err := ver.PrimaryFile(); err == nil {
+			sum = file.Hashes.SHA512
+		}
+	}
+	found, err := findByHash(dir, sum)
+	if err != nil || found == "" || found == ms.Filename {
+		return "", err
+	}
+	if dryRun {
+		return found, nil
+	}
+	if err := os.Rename(filepath.Join(dir, found), filepath.Join(dir, ms.Filename)); err != nil {
+		return "", fmt.Errorf("failed to rename %s to %s: %w", found, ms.Filename, err)
+	}
+	return found, nil
+}
+
+// identifyFile looks the file at path up on Modrinth by its SHA-512 and returns the version it
+// belongs to, checking that version is one of slug's so a stray jar can't be recorded for the wrong mod
+func identifyFile(path, slug string) (*Version, string, error) {
+	sum, err := fileSHA512(path)
+	if err != nil {
+		return nil, "", err
+	}
+	ver, err := FetchVersionByHash(sum)
+	var status *StatusError
+	if errors.As(err, &status) && status.StatusCode == http.StatusNotFound {
+		// Likely built locally; the jar's own metadata at least says what it is
+		if info, jerr := InspectJar(path); jerr == nil {
+			return nil, "", fmt.Errorf("no Modrinth version has the hash of %s (the jar says it is %s, probably a local build)", filepath.Base(path), info)
+		}
+		return nil, "", fmt.Errorf("no Modrinth version has the hash of %s", filepath.Base(path))
+	} else if err != nil {
+		return nil, "", err
+	}
+	proj, err := FetchProject(slug)
+	if err != nil {
+		return nil, "", err
+	}
+	if ver.ProjectID != proj.ID {
+		return nil, "", fmt.Errorf("%s belongs to project %s, not %s", filepath.Base(path), ver.ProjectID, slug)
+	}
+	return ver, sum, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// importedJar is one jar found by import-dir and what could be learned about it
+type importedJar struct {
+	File    string
+	SHA512  string
+	Slug    string   // the Modrinth project, "" if unidentified
+	Version *Version // the Modrinth version with this file's hash; nil if Modrinth doesn't know it
+	Jar     *JarInfo // the jar's own metadata, read when Modrinth doesn't know the hash
+	Err     error    // why the file couldn't be identified
+}
+
+// identifyJars hashes every jar in dir and looks each up on Modrinth by its SHA-512. Files Modrinth
+// doesn't know fall back to their loader metadata, whose mod ID is kept as the slug when a Modrinth
+// project of that name exists.
+func identifyJars(dir string) ([]importedJar, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".jar") {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	sums := hashFiles(paths)
+
+	jars := make([]importedJar, 0, len(paths))
+	for _, path := range paths {
+		j := importedJar{File: filepath.Base(path), SHA512: sums[path]}
+		if j.SHA512 == "" {
+			j.Err = fmt.Errorf("could not read the file")
+			jars = append(jars, j)
+			continue
+		}
+		ver, err := FetchVersionByHash(j.SHA512)
+		var status *StatusError
+		switch {
+		case err == nil:
+			proj, err := FetchProject(ver.ProjectID)
+			if err != nil {
+				j.Err = fmt.Errorf("project %s lookup failed: %w", ver.ProjectID, err)
+				break
+			}
+			j.Slug, j.Version = proj.Slug, ver
+		case errors.As(err, &status) && status.StatusCode == http.StatusNotFound:
+			info, jerr := InspectJar(path)
+			if jerr != nil {
+				j.Err = fmt.Errorf("not on Modrinth and has no loader metadata")
+				break
+			}
+			j.Jar = info
+			if info.ModID == "" {
+				j.Err = fmt.Errorf("not on Modrinth, and the jar's loader metadata names no mod ID")
+			} else if proj, err := FetchProject(info.ModID); err == nil {
+				j.Slug = proj.Slug
+			} else {
+				j.Err = fmt.Errorf("not on Modrinth (the jar says it is %s, and no project is named %q)", info, info.ModID)
+			}
+		default:
+			j.Err = err
+		}
+		jars = append(jars, j)
+	}
+	return jars, nil
+}
+
+// inferTarget picks the loader and MC version that the most identified jars were built for, ties
+// going to the newer MC release and then the loader name. Jars that don't fit the pick are named in
+// warnings, so a folder mixing loaders or game versions doesn't go unnoticed.
+func inferTarget(jars []importedJar) (mcVersion, loader string, warnings []string) {
+	loaderCount := make(map[string]int)
+	mcCount := make(map[string]int)
+	for _, j := range jars {
+		switch {
+		case j.Version != nil:
+			for _, l := range j.Version.Loaders {
+				loaderCount[l]++
+			}
+			for _, v := range j.Version.GameVersions {
+				mcCount[v]++
+			}
+		case j.Jar != nil:
+			loaderCount[j.Jar.Loader]++
+		}
+	}
+	loader = mostCommon(loaderCount, func(a, b string) bool { return a < b })
+	mcVersion = mostCommon(mcCount, func(a, b string) bool {
+		sa, okA := parseSemver(a)
+		sb, okB := parseSemver(b)
+		if okA && okB {
+			return compareSemver(sa, sb) > 0
+		}
+		return a > b
+	})
+
+	var otherLoader, otherMC []string
+	for _, j := range jars {
+		switch {
+		case j.Version != nil:
+			if loader != "" && !slices.Contains(j.Version.Loaders, loader) {
+				otherLoader = append(otherLoader, fmt.Sprintf("%s (%s)", j.File, strings.Join(j.Version.Loaders, "/")))
+			}
+			if mcVersion != "" && !slices.Contains(j.Version.GameVersions, mcVersion) {
+				otherMC = append(otherMC, j.File)
+			}
+		case j.Jar != nil && loader != "" && j.Jar.Loader != loader:
+			otherLoader = append(otherLoader, fmt.Sprintf("%s (%s)", j.File, j.Jar.Loader))
+		}
+	}
+	if len(otherLoader) > 0 {
+		warnings = append(warnings, fmt.Sprintf("mixed loaders: %d jar(s) aren't built for %s: %s", len(otherLoader), loader, strings.Join(otherLoader, ", ")))
+	}
+	if len(otherMC) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d jar(s) aren't built for MC %s: %s", len(otherMC), mcVersion, strings.Join(otherMC, ", ")))
+	}
+	return mcVersion, loader, warnings
+}
+
+// mostCommon returns the key with the highest count, breaking ties with first, or "" for an empty map
+func mostCommon(counts map[string]int, first func(a, b string) bool) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, k int) bool {
+		if counts[keys[i]] != counts[keys[k]] {
+			return counts[keys[i]] > counts[keys[k]]
+		}
+		return first(keys[i], keys[k])
+	})
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
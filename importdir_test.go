@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestIdentifyJarsWithoutModID(t *testing.T) {
+	var projectLookups int
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/project/") {
+			projectLookups++
+		}
+		http.NotFound(w, r) // no jar is on Modrinth
+	}))
+	dir := t.TempDir()
+	writeJar(t, dir, map[string]string{"fabric.mod.json": `{"schemaVersion": 1, "version": "1.0.0"}`})
+
+	jars, err := identifyJars(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jars) != 1 || jars[0].Err == nil || !strings.Contains(jars[0].Err.Error(), "names no mod ID") {
+		t.Fatalf("identifyJars = %+v, want the jar reported as having no mod ID", jars)
+	}
+	if projectLookups != 0 {
+		t.Errorf("%d project lookup(s) for a jar without a mod ID", projectLookups)
+	}
+}
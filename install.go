@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Policies for --filename-collision-policy, applied when a download's filename is already taken
+// by a different file
+const (
+	collisionOverwrite  = "overwrite"
+	collisionPrefixSlug = "prefix-slug" // save as <slug>-<filename> instead
+	collisionError      = "error"
+)
+
+// targetFilename picks the name slug's file is saved under in dir. An existing file there is a
+// collision unless it is the mod's own current file (own) or already has the expected content.
+func targetFilename(dir, name, slug, own, wantSHA512 string) (string, error) {
+	taken := func(n string) bool {
+		if n == own {
+			return false
+		}
+		if _, err := os.Stat(filepath.Join(dir, n)); err != nil {
+			return false
+		}
+		sum, err := fileSHA512(filepath.Join(dir, n))
+		return wantSHA512 == "" || err != nil || sum != wantSHA512
+	}
+	if !taken(name) {
+		return name, nil
+	}
+	switch onCollision {
+	case collisionOverwrite:
+		return name, nil
+	case collisionPrefixSlug:
+		prefixed := slug + "-" + name
+		if taken(prefixed) {
+			return "", fmt.Errorf("%s and %s already exist in %s as different files", name, prefixed, dir)
+		}
+		return prefixed, nil
+	}
+	return "", fmt.Errorf("%s already exists in %s as a different file (see --filename-collision-policy)", name, dir)
+}
+
+// presentByHash returns the name under which file already sits in dir with its published SHA-512,
+// checking both the API filename and the prefix-slug collision name, or "" if neither matches
+func presentByHash(dir, slug string, file *VersionFile) string {
+	if file.Hashes.SHA512 == "" {
+		return ""
+	}
+	for _, name := range []string{file.Filename, slug + "-" + file.Filename} {
+		if sum, err := fileSHA512(filepath.Join(dir, name)); err == nil && sum == file.Hashes.SHA512 {
+			return name
+		}
+	}
+	return ""
+}
+
+// reuploaded reports whether ms, already on file's version, was saved under a filename that version
+// no longer publishes, as when an author reuploads a build under a corrected name. The prefix-slug
+// collision name counts as the current one.
+func reuploaded(ms ModState, slug string, file *VersionFile) bool {
+	return ms.Filename != "" && ms.Filename != file.Filename && ms.Filename != slug+"-"+file.Filename
+}
+
+// installVersion downloads ver's primary file for slug into dir, under its API filename unless that
+// collides (own is the mod's current file, which may be replaced), checks it against Modrinth's
+// SHA-512 and returns the state entry describing it, with the hash of the file actually written
+func installVersion(ver *Version, dir, slug, own string) (ModState, error) {
+	file, err := ver.PrimaryFile()
+	if err != nil {
+		return ModState{}, err
+	}
+	// Already correct on disk, whatever the state says
+	if name := presentByHash(dir, slug, file); name != "" {
+		return ModState{VersionID: ver.ID, VersionNumber: ver.VersionNumber, Filename: name, SHA512: file.Hashes.SHA512}, nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return ModState{}, err
+	}
+	name, err := targetFilename(dir, file.Filename, slug, own, file.Hashes.SHA512)
+	if err != nil {
+		return ModState{}, err
+	}
+	_, sum, err := DownloadFile(file.URL, dir, name, file.Hashes.SHA512)
+	if err != nil {
+		return ModState{}, err
+	}
+	return ModState{VersionID: ver.ID, VersionNumber: ver.VersionNumber, Filename: name, SHA512: sum}, nil
+}
+
+// redownloadState fetches the exact version recorded in state for slug and saves it into dir
+func redownloadState(state State, packName, slug, dir string) error {
+	own := state[packName][slug]
+	ver, err := FetchVersion(own.VersionID)
+	if err != nil {
+		return err
+	}
+	ms, err := installVersion(ver, dir, slug, own.Filename)
+	if err != nil {
+		return err
+	}
+	ms.RequiredBy = own.RequiredBy
+	state[packName][slug] = ms
+	return nil
+}
+
+// resolvedTarget is the version update picked for a mod, looked up ahead of the mod's checks
+type resolvedTarget struct {
+	Ver       *Version
+	MatchedMC string // the in-range MC version the build is for, under --mc-version-range
+	Err       error
+}
+
+// resolveTargets runs resolve for each of slugs on up to networkWorkers goroutines, so update's
+// checks don't wait on Modrinth one mod at a time
+func resolveTargets(slugs []string, resolve func(slug string) (*Version, string, error)) map[string]resolvedTarget {
+	targets := make(map[string]resolvedTarget, len(slugs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, networkWorkers())
+	for _, slug := range slugs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var t resolvedTarget
+			t.Ver, t.MatchedMC, t.Err = resolve(slug)
+			mu.Lock()
+			targets[slug] = t
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return targets
+}
+
+// downloadJob is a download update approved during a mod's checks, run with the others once every
+// mod has been checked
+type downloadJob struct {
+	Slug     string
+	Ver      *Version
+	File     *VersionFile
+	Filename string   // name to save under, from targetFilename
+	Old      ModState // the mod's state before the run
+	OldPath  string   // the mod's current file, replaced once the download succeeds
+	Action   string
+	SHA512   string // the written file's hash, set by runDownloads once it succeeds
+}
+
+// downloadSize is the total size Modrinth reports for the jobs' files
+func downloadSize(jobs []downloadJob) int64 {
+	var total int64
+	for _, job := range jobs {
+		total += job.File.Size
+	}
+	return total
+}
+
+// runDownloads downloads every job's file into dir on up to networkWorkers goroutines. Each job's
+// lines reach w as one block when it finishes (or live under --stream); errs[i] is job i's result,
+// and a successful job's SHA512 is set to its file's.
+// Each finished download reports the time left, at this run's speed so far, and the run's speed is
+// recorded in history for later estimates.
+func runDownloads(w io.Writer, dir string, jobs []downloadJob, history *throughputHistory) []error {
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, networkWorkers())
+	var mu sync.Mutex
+	total, done, finished := downloadSize(jobs), int64(0), 0
+	var downloaded int64 // bytes of the successful downloads, which measure the speed
+	start := time.Now()
+	defer startProgress(len(jobs), total)()
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if runCtx.Err() != nil {
+				errs[i] = errInterrupted // not started before the interrupt
+				return
+			}
+			out := newOutputBlock(w)
+			defer out.Flush()
+			fmt.Fprintf(out, "  %s: downloading %s...\n", job.Slug, job.Filename)
+			_, sum, err := DownloadFile(job.File.URL, dir, job.Filename, job.File.Hashes.SHA512)
+			mu.Lock()
+			finished++
+			// A failed file counts as done so the estimate covers only what is still to come
+			done += job.File.Size
+			rate := history.BytesPerSec
+			if err == nil {
+				downloaded += job.File.Size
+			}
+			if downloaded > 0 {
+				rate = float64(downloaded) / time.Since(start).Seconds()
+			}
+			left := ""
+			if eta, ok := estimateDuration(total-done, rate); ok && finished < len(jobs) {
+				left = fmt.Sprintf(", %s left", roughDuration(eta))
+			}
+			progress := fmt.Sprintf("(%d of %d%s)", finished, len(jobs), left)
+			mu.Unlock()
+			if err != nil {
+				fmt.Fprintf(out, "    ✗ %s: download failed: %v %s\n", job.Slug, err, progress)
+				errs[i] = err
+				return
+			}
+			jobs[i].SHA512 = sum
+			fmt.Fprintf(out, "    ✓ %s: downloaded %s %s\n", job.Slug, job.Filename, progress)
+		}()
+	}
+	wg.Wait()
+	if err := history.record(downloaded, time.Since(start)); err != nil && verbose {
+		fmt.Fprintf(w, "Warning: could not save download speed: %v\n", err)
+	}
+	return errs
+}
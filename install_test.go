@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestReuploaded(t *testing.T) {
+	file := &VersionFile{Filename: "sodium-fabric-0.6.0+mc1.21.1.jar"}
+	tests := []struct {
+		name     string
+		filename string // recorded in state for the same version
+		want     bool
+	}{
+		{"renamed file", "sodium-fabric-mc1.21.1-0.6.0.jar", true},
+		{"same name", "sodium-fabric-0.6.0+mc1.21.1.jar", false},
+		{"prefix-slug name", "sodium-sodium-fabric-0.6.0+mc1.21.1.jar", false},
+		{"prefix-slug of the old name", "sodium-sodium-fabric-mc1.21.1-0.6.0.jar", true},
+		{"no file recorded", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := ModState{VersionID: "AANobbMI", Filename: tt.filename}
+			if got := reuploaded(ms, "sodium", file); got != tt.want {
+				t.Errorf("reuploaded with %q recorded = %v, want %v", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JarInfo is what a mod jar says about itself in its loader metadata
+type JarInfo struct {
+	Loader  string // fabric, quilt, forge or neoforge
+	ModID   string
+	Version string
+}
+
+func (j JarInfo) String() string {
+	return fmt.Sprintf("%s mod %s %s", j.Loader, j.ModID, j.Version)
+}
+
+// InspectJar reads the mod id and version from the loader metadata inside the jar at path,
+// without asking Modrinth. Fabric and Quilt jars are tried before Forge-style mods.toml.
+func InspectJar(path string) (*JarInfo, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	if data, err := readZipFile(&zr.Reader, "fabric.mod.json"); err == nil {
+		var meta struct {
+			ID      string `json:"id"`
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("bad fabric.mod.json in %s: %w", path, err)
+		}
+		return &JarInfo{Loader: "fabric", ModID: meta.ID, Version: meta.Version}, nil
+	}
+	if data, err := readZipFile(&zr.Reader, "quilt.mod.json"); err == nil {
+		var meta struct {
+			QuiltLoader struct {
+				ID      string `json:"id"`
+				Version string `json:"version"`
+			} `json:"quilt_loader"`
+		}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("bad quilt.mod.json in %s: %w", path, err)
+		}
+		return &JarInfo{Loader: "quilt", ModID: meta.QuiltLoader.ID, Version: meta.QuiltLoader.Version}, nil
+	}
+	for _, toml := range []struct{ name, loader string }{
+		{"META-INF/neoforge.mods.toml", "neoforge"},
+		{"META-INF/mods.toml", "forge"},
+	} {
+		data, err := readZipFile(&zr.Reader, toml.name)
+		if err != nil {
+			continue
+		}
+		info := parseModsTOML(string(data))
+		info.Loader = toml.loader
+		// Forge build scripts usually fill the version in from the jar's manifest
+		if strings.HasPrefix(info.Version, "${") {
+			info.Version = ""
+			if manifest, err := readZipFile(&zr.Reader, "META-INF/MANIFEST.MF"); err == nil {
+				info.Version = manifestValue(string(manifest), "Implementation-Version")
+			}
+		}
+		return &info, nil
+	}
+	return nil, fmt.Errorf("%s has no fabric.mod.json, quilt.mod.json or mods.toml", path)
+}
+
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// parseModsTOML picks modId and version out of the first [[mods]] table. It only understands
+// the simple key = "value" lines these files use, which avoids a TOML dependency.
+func parseModsTOML(data string) JarInfo {
+	var info JarInfo
+	inMods := false
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") {
+			if inMods {
+				break // only the first mod in the jar
+			}
+			inMods = line == "[[mods]]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inMods || !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if q := value[:min(1, len(value))]; q == `"` || q == "'" {
+			if end := strings.Index(value[1:], q); end >= 0 {
+				value = value[1 : end+1]
+			}
+		} else if i := strings.Index(value, "#"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		switch strings.TrimSpace(key) {
+		case "modId":
+			info.ModID = value
+		case "version":
+			info.Version = value
+		}
+	}
+	return info
+}
+
+// manifestValue returns the value of key in a jar manifest, or ""
+func manifestValue(manifest, key string) string {
+	for _, line := range strings.Split(manifest, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimRight(line, "\r"), ":"); ok && k == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeJar writes a jar holding files (name -> content) into dir and returns its path
+func writeJar(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "mod.jar")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInspectJar(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    JarInfo
+		wantErr bool
+	}{
+		{
+			name:  "fabric",
+			files: map[string]string{"fabric.mod.json": `{"schemaVersion": 1, "id": "sodium", "version": "0.6.0+mc1.21.1"}`},
+			want:  JarInfo{Loader: "fabric", ModID: "sodium", Version: "0.6.0+mc1.21.1"},
+		},
+		{
+			name:  "quilt",
+			files: map[string]string{"quilt.mod.json": `{"quilt_loader": {"id": "qsl", "version": "7.0.0"}}`},
+			want:  JarInfo{Loader: "quilt", ModID: "qsl", Version: "7.0.0"},
+		},
+		{
+			name: "forge mods.toml",
+			files: map[string]string{"META-INF/mods.toml": `modLoader = "javafml"
+[[mods]]
+modId = "jei" # the id
+version = '19.8.2'
+[[mods]]
+modId = "second"
+`},
+			want: JarInfo{Loader: "forge", ModID: "jei", Version: "19.8.2"},
+		},
+		{
+			name: "neoforge version from the manifest",
+			files: map[string]string{
+				"META-INF/neoforge.mods.toml": "[[mods]]\nmodId=\"create\"\nversion=\"${file.jarVersion}\"\n",
+				"META-INF/MANIFEST.MF":        "Manifest-Version: 1.0\r\nImplementation-Version: 6.0.1\r\n",
+			},
+			want: JarInfo{Loader: "neoforge", ModID: "create", Version: "6.0.1"},
+		},
+		{
+			name:    "bad fabric.mod.json",
+			files:   map[string]string{"fabric.mod.json": `{"id": `},
+			wantErr: true,
+		},
+		{
+			name:    "no metadata",
+			files:   map[string]string{"com/example/Mod.class": "\xca\xfe\xba\xbe"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := InspectJar(writeJar(t, t.TempDir(), tt.files))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("InspectJar = %+v, want an error", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *info != tt.want {
+				t.Errorf("InspectJar = %+v, want %+v", *info, tt.want)
+			}
+		})
+	}
+
+	if _, err := InspectJar(filepath.Join(t.TempDir(), "missing.jar")); err == nil {
+		t.Error("InspectJar of a missing file succeeded")
+	}
+}
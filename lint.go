@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+)
+
+// sideMismatch is a mod that doesn't belong in the environment a pack is linted for
+type sideMismatch struct {
+	Slug       string
+	ClientSide string
+	ServerSide string
+}
+
+// sideMismatches looks up every mod of packName and returns those Modrinth marks as unsupported
+// on side: client-only mods for a server, server-only mods for a client. Mods whose project
+// can't be fetched are reported in failed rather than guessed at.
+func sideMismatches(cfg *Config, packName, side string) (mismatches []sideMismatch, failed map[string]error, err error) {
+	if side != "client" && side != "server" {
+		return nil, nil, fmt.Errorf("unknown --side %q (want client or server)", side)
+	}
+	packCfg, ok := cfg.Modpacks[packName]
+	if !ok {
+		return nil, nil, fmt.Errorf("modpack %q not found", packName)
+	}
+	failed = make(map[string]error)
+	for _, slug := range cfg.EffectiveMods(packCfg) {
+		proj, err := FetchProject(slug)
+		if err != nil {
+			failed[slug] = err
+			continue
+		}
+		if !proj.SupportsEnv(side) {
+			mismatches = append(mismatches, sideMismatch{Slug: slug, ClientSide: proj.ClientSide, ServerSide: proj.ServerSide})
+		}
+	}
+	return mismatches, failed, nil
+}
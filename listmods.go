@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// modRow is one line of list-mods, with whatever --sort needs filled in
+type modRow struct {
+	Slug    string
+	From    string   // pack the mod is inherited from, if any
+	Deps    []string // for a mod installed only as a dependency, the mods requiring it
+	Note    string
+	Version string // installed version number, or its ID when Modrinth can't say
+	Size    int64  // bytes on disk, -1 for no file
+	Status  string
+}
+
+// Mod statuses in the order --sort status lists them, those needing attention first.
+// outdated, up to date and check failed only appear with --check.
+var modStatusOrder = []string{"outdated", "missing", "not installed", "check failed", "skipped", "frozen", "installed", "up to date"}
+
+// modRows gathers the rows for list-mods sorted by key (name, version, size, status or config).
+// Version numbers are fetched only for the version sort and live status only with check.
+func modRows(cfg *Config, packName string, packState map[string]ModState, key string, check bool) ([]modRow, error) {
+	switch key {
+	case "name", "version", "size", "status", "config":
+	default:
+		return nil, fmt.Errorf("unknown --sort %q (want name, version, size, status or config)", key)
+	}
+	packCfg := cfg.Modpacks[packName]
+	dir := filepath.Join(modsDir, packName)
+	var rows []modRow
+	mods := cfg.EffectiveMods(packCfg)
+	for _, slug := range append(mods, autoDeps(packState, mods)...) {
+		row := modRow{Slug: slug, Size: -1}
+		ms, inState := packState[slug]
+		if i := packCfg.Entry(slug); i >= 0 {
+			row.Note = packCfg.Mods[i].Note
+		} else if row.From = cfg.InheritedFrom(packCfg, slug); row.From == "" {
+			row.Deps = ms.RequiredBy
+		}
+		if inState && ms.Filename != "" {
+			if info, err := os.Stat(filepath.Join(dir, ms.Filename)); err == nil {
+				row.Size = info.Size()
+			}
+		}
+		row.Version = ms.VersionID
+		if key == "version" && ms.VersionID != "" {
+			if v, err := FetchVersion(ms.VersionID); err == nil {
+				row.Version = v.VersionNumber
+			}
+		}
+		switch {
+		case !inState:
+			row.Status = "not installed"
+		case ms.SkipEnv != "":
+			row.Status = "skipped"
+		case row.Size < 0:
+			row.Status = "missing"
+		case slices.Contains(packCfg.Frozen, slug):
+			row.Status = "frozen"
+		case check:
+			row.Status = "up to date"
+			if ver, err := resolveTarget(slug, packCfg); err != nil {
+				row.Status = "check failed"
+			} else if ver.ID != ms.VersionID {
+				row.Status = "outdated"
+			}
+		default:
+			row.Status = "installed"
+		}
+		rows = append(rows, row)
+	}
+
+	compare := map[string]func(a, b modRow) int{
+		"name":    func(a, b modRow) int { return strings.Compare(a.Slug, b.Slug) },
+		"version": func(a, b modRow) int { return compareVersionNumbers(a.Version, b.Version) },
+		"size":    func(a, b modRow) int { return cmpInt64(a.Size, b.Size) },
+		"status": func(a, b modRow) int {
+			return cmpInt(slices.Index(modStatusOrder, a.Status), slices.Index(modStatusOrder, b.Status))
+		},
+	}[key]
+	if compare != nil {
+		// Ties keep alphabetical order
+		sort.SliceStable(rows, func(i, j int) bool {
+			if c := compare(rows[i], rows[j]); c != 0 {
+				return c < 0
+			}
+			return rows[i].Slug < rows[j].Slug
+		})
+	}
+	return rows, nil
+}
+
+// compareVersionNumbers orders version numbers by semver where both parse, else as strings.
+// Empty (not installed) sorts first.
+func compareVersionNumbers(a, b string) int {
+	if va, ok := parseSemver(a); ok {
+		if vb, ok := parseSemver(b); ok {
+			return compareSemver(va, vb)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// humanSize formats n bytes for listings, e.g. "1.4 MiB"
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// wrongLoaderShare is the share of a pack's mods that may have builds only for other loaders
+// before update suspects the pack's loader setting rather than the mods
+const wrongLoaderShare = 0.5
+
+// otherLoaderMods returns those of slugs that have builds for one of mcVersions, just not for
+// loader, and how many of them each other loader has builds for. Mods lacking builds for the MC
+// version altogether aren't counted, since a wrong loader isn't what's wrong with them.
+func otherLoaderMods(slugs, mcVersions []string, loader string) (mods []string, loaders map[string]int) {
+	loaders = make(map[string]int)
+	for _, slug := range slugs {
+		versions, err := FetchAllVersions(slug)
+		if err != nil {
+			continue
+		}
+		found := make(map[string]bool)
+		for _, v := range versions {
+			if !slices.ContainsFunc(v.GameVersions, func(mc string) bool { return slices.Contains(mcVersions, mc) }) {
+				continue
+			}
+			for _, l := range v.Loaders {
+				found[l] = true
+			}
+		}
+		if len(found) == 0 || found[loader] {
+			continue
+		}
+		mods = append(mods, slug)
+		for l := range found {
+			loaders[l]++
+		}
+	}
+	return mods, loaders
+}
+
+// warnWrongLoader follows an update in which the mods in incompatible found no compatible build.
+// It reports how many of the total mods checked only have builds for other loaders, and when that
+// is more than wrongLoaderShare of the pack, warns that the pack's loader is probably wrong.
+func warnWrongLoader(w io.Writer, packName string, incompatible []string, total int, mcVersions []string, loader string) {
+	if len(incompatible) == 0 || total == 0 {
+		return
+	}
+	mods, loaders := otherLoaderMods(incompatible, mcVersions, loader)
+	if len(mods) == 0 {
+		return
+	}
+	share := float64(len(mods)) / float64(total)
+	fmt.Fprintf(w, "%d of %d mod(s) (%.0f%%) have builds for MC %s, but not for %s: %s\n", len(mods), total, share*100, strings.Join(mcVersions, "/"), loader, strings.Join(mods, ", "))
+	if share <= wrongLoaderShare {
+		return
+	}
+	others := make([]string, 0, len(loaders))
+	for l := range loaders {
+		others = append(others, l)
+	}
+	sort.Slice(others, func(i, j int) bool {
+		if loaders[others[i]] != loaders[others[j]] {
+			return loaders[others[i]] > loaders[others[j]]
+		}
+		return others[i] < others[j]
+	})
+	fmt.Fprintf(w, "!!! WARNING: most of %s's mods aren't built for %s; is its loader setting wrong?\n", packName, loader)
+	fmt.Fprintf(w, "!!! %d of those %d mod(s) are built for %s; 'modpilot migrate-loader %s %s' shows what switching would keep.\n", loaders[others[0]], len(mods), others[0], packName, others[0])
+}
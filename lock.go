@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// LockFile is the exact resolution update --write-lock records for a pack: every installed mod's
+// version, file and hash. It has no timestamps and lists mods by slug, so an update that changes
+// nothing rewrites it byte for byte and CI can commit it only when it differs.
+type LockFile struct {
+	Pack      string      `json:"pack"`
+	MCVersion string      `json:"mc_version"`
+	Loader    string      `json:"loader"`
+	Mods      []LockedMod `json:"mods"`
+}
+
+// LockedMod is one mod's pinned file in a LockFile
+type LockedMod struct {
+	Slug          string   `json:"slug"`
+	VersionID     string   `json:"version_id"`
+	VersionNumber string   `json:"version_number,omitempty"`
+	Filename      string   `json:"filename"`
+	URL           string   `json:"url"`
+	SHA512        string   `json:"sha512"`
+	RequiredBy    []string `json:"required_by,omitempty"` // set for dependencies update installed on its own
+}
+
+// buildLock records the installed mods of packName the pack still has (its own and the
+// dependencies installed for them), looking up each version for its download URL. Leftover state
+// entries for mods the config dropped are left out. A mod whose file can't be found in its version
+// fails the lock, which would otherwise be incomplete.
+func buildLock(cfg *Config, state State, packName, mcVersion, loader string) (*LockFile, error) {
+	lock := &LockFile{Pack: packName, MCVersion: mcVersion, Loader: loader, Mods: []LockedMod{}}
+	packState := state[packName]
+	for _, slug := range sortedUnique(installedMods(cfg, state, packName)) {
+		ms, ok := packState[slug]
+		if !ok || ms.SkipEnv != "" || ms.VersionID == "" {
+			continue
+		}
+		ver, err := FetchVersion(ms.VersionID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", slug, err)
+		}
+		i := slices.IndexFunc(ver.Files, func(f VersionFile) bool {
+			return (ms.SHA512 != "" && f.Hashes.SHA512 == ms.SHA512) || f.Filename == ms.Filename
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("%s: version %s has no file %s", slug, showInstalled(ms), ms.Filename)
+		}
+		file := ver.Files[i]
+		lock.Mods = append(lock.Mods, LockedMod{Slug: slug, VersionID: ms.VersionID, VersionNumber: ver.VersionNumber, Filename: ms.Filename, URL: file.URL, SHA512: file.Hashes.SHA512, RequiredBy: ms.RequiredBy})
+	}
+	return lock, nil
+}
+
+// Write saves the lock to path atomically: it is written to a temporary file in the same directory
+// and renamed over path, so a reader (or a commit) never sees half a lock.
+func (l *LockFile) Write(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place, so a
+// crash or full disk never leaves a truncated file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -2,11 +2,23 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"maps"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -15,6 +27,7 @@ const (
 	defaultConfig = "config.json"
 	defaultState  = "state.json"
 	defaultMods   = "mods"
+	defaultQPS    = 4.0 // stays under Modrinth's 300 requests/minute
 )
 
 var (
@@ -23,26 +36,186 @@ var (
 	modsDir       string
 	autoYes       bool
 	mcVersionFlag string // override MC version
+	mcRange       string // accept any MC release in this range
+	maxBehind     int // tolerated distance from the latest version, -1 for none
 	loaderFlag    string // override loader
 	verbose       bool // enable verbose logging
+	dryRun        bool // report changes instead of applying them
+	qps           float64 // request rate limit shared by all API calls
+	probeLoaders  bool // explain "no compatible version" errors
+	cacheDir      string // API response cache location
+	cacheTTL      time.Duration // freshness window for cached responses
+	onCollision   string // what to do when a download's filename is taken by another file
+	outputFile    string // where config saves go instead of cfgFile
+	packDir       string // self-contained pack folder holding config, state and mods
+	concurrency   string // --concurrency as given: auto or a number
+	workerLimit   int // parsed --concurrency, 0 for auto
+	useStagingAPI bool // talk to staging-api.modrinth.com instead of production
+	noRedirects   bool // --no-follow-redirects; applied to followRedirects
+
+	listDetailed   bool   // list-packs: column view with counts
+	modNote        string // add-mod: note stored with the added mods
+	partialEdit    bool   // remove-mod: save the valid slugs even if others fail
+	listCheck      bool   // list-packs: include online outdated counts
+	listAll        bool   // list-packs: include archived packs
+	packsJSON      bool   // list-packs: JSON output
+	modsSort       string // list-mods: name, version, size, status or config
+	sortReverse    bool   // list-mods: reverse the sort
+	modsCheck      bool   // list-mods: live update status from Modrinth
+	compareChannel string // check-updates: extra channel to report on
+	notifyMode     string // check-updates: where to announce found updates
+	usePackClear   bool   // use-pack: unset the active pack
+	deleteFile     bool   // remove-mod: delete the recorded jar too
+	reorderSort    string // reorder-mods: sort mode instead of explicit order
+	fastCheck      bool   // update: skip hashing existing files
+	prune          bool   // update: remove stale jars afterwards, like sync
+	updateEnv      string // update: client, server or both
+	reportPath     string // update: where to write the run summary
+	lockPath       string // update: where to write the lockfile of the resolved versions
+	forceOverride  bool   // update: accept overrides that differ from the pack
+	noDeps         bool   // update: don't install required dependencies the pack doesn't list
+	resolveOnly    bool   // update: stop after version resolution
+	planJSON       bool   // update: print the resolved plan as JSON
+	useStaging     bool   // update: download into a staging directory first
+	interactive    bool   // update: per-mod action prompt
+	askChannels    bool   // update: choose each mod's release channel before updating
+	retryFailed    bool   // update-all: only rerun the packs that failed last time
+	addValidate    bool   // add-mod: check the mods fit the pack and the resulting pack for conflicts before saving
+	addForce       bool   // add-mod: skip the compatibility check and save whatever the checks find
+	summaryOnly    bool   // update: hide per-mod status lines
+	planConfirm    bool   // update: one confirmation for the resolved plan
+	planOut        string // update: save the resolved plan here instead of applying it
+	fromPlan       string // update: apply a plan saved with --plan-out
+	changelogSum   bool   // update: print the changelogs of every version updated past
+	syncExclude    []string // sync: globs of jars to never remove
+	syncDedupe     bool     // sync: only remove other versions of tracked mods
+	statsJSON      bool   // stats: JSON output
+	statsOffline   bool   // stats: local fields only
+	doctorFix      bool   // doctor: apply automatic fixes
+	checkAbandoned bool   // doctor: advisory search for successors of stale mods
+	doctorDeep     bool   // doctor: open every jar to check it is a readable zip
+	lintSide       string // lint: environment the pack is meant for (client or server)
+	searchLimit    int    // search: how many results to show
+	searchInstall  bool   // search: add the chosen result to a pack and download it
+	searchPick     int    // search: result number to install without asking
+	searchPack     string // search: pack --install adds to
+	versionJSON    bool   // version: JSON output
+	graphFormat    string // graph: dot or mermaid
+	mrpackPath     string // export-mrpack: where to write the pack
+	overridesDir   string // export-mrpack: folder copied under overrides/
+	loaderVersion  string // export-mrpack: loader version for the index
+	packVersion    string // export-mrpack: the pack's own version
+	serveAddr      string // serve: listen address
+	serveToken     string // serve: bearer token for mutating endpoints
+	allowRemote    bool   // serve: permit non-loopback addresses
+	resume         bool   // reinstall: continue an interrupted run instead of starting over
+	migrateFetch   bool   // migrate-loader: download the new builds after switching
 )
 
+// sharedReader, when set, is the stdin reader update prompts with. update-all sets it so every
+// pack reads from one buffer, and input buffered ahead for the next pack's prompts isn't lost.
+var sharedReader *bufio.Reader
+
 func main() {
 	root := &cobra.Command{
 		Use:     "modpilot",
 		Aliases: []string{"modpm", "mp"},
 		Short:   "modpilot — a Modrinth modpack manager",
 		Long:    "Define modpack “stacks” in config.json, then list, add, remove, or update mods via the CLI.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if packDir != "" {
+				// Explicit --config/--state/--mods-dir still win over the pack folder's defaults
+				flags := cmd.Flags()
+				if !flags.Changed("config") {
+					cfgFile = filepath.Join(packDir, defaultConfig)
+				}
+				if !flags.Changed("state") {
+					stateFile = filepath.Join(packDir, defaultState)
+				}
+				if !flags.Changed("mods-dir") {
+					modsDir = filepath.Join(packDir, defaultMods)
+				}
+			}
+			if cfgFile == stdinPath && stateFile == stdinPath {
+				return fmt.Errorf("--config - and --state - can't both read stdin")
+			}
+			if isRemote(stateFile) {
+				return fmt.Errorf("--state must be a local file; only --config can be a URL")
+			}
+			switch onCollision {
+			case collisionOverwrite, collisionPrefixSlug, collisionError:
+			default:
+				return fmt.Errorf("unknown --filename-collision-policy %q (want overwrite, prefix-slug or error)", onCollision)
+			}
+			var err error
+			if workerLimit, err = parseConcurrency(concurrency); err != nil {
+				return err
+			}
+			setRateLimit(qps)
+			if useStagingAPI {
+				apiBase = stagingAPI
+			}
+			if modrinthToken == "" {
+				modrinthToken = os.Getenv("MODRINTH_TOKEN")
+			}
+			followRedirects = !noRedirects
+			if err := checkVersionDisplay(versionDisplay); err != nil {
+				return err
+			}
+			if err := checkStatsMode(runStatsMode); err != nil {
+				return err
+			}
+			if downloadMirror != "" {
+				if _, err := parseMirror(downloadMirror); err != nil {
+					return err
+				}
+				if skipHash {
+					return errSkipHashMirror
+				}
+			}
+			return nil
+		},
 	}
 
+	root.Version = currentBuild().String()
+	root.SetVersionTemplate("{{.Version}}\n")
+
 	// Global flags
-	root.PersistentFlags().StringVarP(&cfgFile, "config", "c", defaultConfig, "path to config.json")
-	root.PersistentFlags().StringVarP(&stateFile, "state", "s", defaultState, "path to state.json")
+	root.PersistentFlags().StringVarP(&cfgFile, "config", "c", defaultConfig, "path to config.json (- reads it from stdin, an http(s) URL fetches it read-only)")
+	root.PersistentFlags().StringVarP(&stateFile, "state", "s", defaultState, "path to state.json (- reads it from stdin, for commands that don't save it)")
+	root.PersistentFlags().StringVar(&outputFile, "output", "", "save config changes to this file instead of --config (required with --config -)")
 	root.PersistentFlags().StringVarP(&modsDir, "mods-dir", "m", defaultMods, "where to drop downloaded JARs")
+	root.PersistentFlags().StringVar(&packDir, "pack-dir", "", "keep config.json, state.json and mods/ together in this directory")
 	root.PersistentFlags().BoolVarP(&autoYes, "yes", "y", false, "auto-confirm updates")
 	root.PersistentFlags().StringVarP(&mcVersionFlag, "mc-version", "g", "", "override Minecraft version (e.g. 1.18.2)")
 	root.PersistentFlags().StringVarP(&loaderFlag, "loader", "l", "", "override mod loader (fabric|forge|…)")
+	root.PersistentFlags().StringVar(&mcRange, "mc-version-range", "", "for update/check-updates, accept builds for any MC release in a range such as \"1.20.1 - 1.20.4\", preferring the highest")
+	root.PersistentFlags().IntVar(&maxBehind, "max-versions-behind", -1, "for update/check-updates, leave mods alone until they fall more than this many minor versions (or builds, for non-semver numbers) behind; patch bumps never count")
 	root.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose logging")
+	root.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print what mutating commands would change without writing or downloading anything")
+	root.PersistentFlags().BoolVar(&probeLoaders, "probe-loaders", false, "when no compatible version exists, report which loaders/MC versions the mod does support")
+	root.PersistentFlags().StringVar(&cacheDir, "cache-dir", defaultCacheDir(), "where to cache Modrinth API responses")
+	root.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", defaultCacheTTL, "how long cached API responses stay fresh (0 disables the cache)")
+	root.PersistentFlags().BoolVar(&preferVersionNumber, "prefer-version-number", false, "break ties between versions published at the same time by their version number")
+	root.PersistentFlags().StringVar(&onCollision, "filename-collision-policy", collisionPrefixSlug, "when a download's filename belongs to a different file: overwrite, prefix-slug or error")
+	root.PersistentFlags().DurationVar(&apiTimeout, "api-timeout", apiTimeout, "maximum time for one attempt at a Modrinth API request (0 = no limit)")
+	root.PersistentFlags().StringVar(&modrinthToken, "token", "", "Modrinth personal access token for private or unlisted projects (default $MODRINTH_TOKEN)")
+	root.PersistentFlags().IntVar(&maxRetries, "max-retries", maxRetries, "retries for a request that hits a network error, a 5xx or a 429, with exponential backoff (0 = no retries)")
+	root.PersistentFlags().StringVar(&downloadMirror, "mirror", "", "base URL of a mirror of Modrinth's CDN to download from first (overrides the config's download_mirror)")
+	root.PersistentFlags().StringVar(&versionDisplay, "version-display", versionDisplay, "how output names versions: number (the author's version number), id (Modrinth's version ID) or both")
+	root.PersistentFlags().BoolVar(&streamOutput, "stream", false, "print the output of work running in parallel as it happens, interleaved, instead of one block per unit of work")
+	root.PersistentFlags().IntVar(&maxRedirects, "max-redirects", maxRedirects, "most redirects a download may follow before failing")
+	root.PersistentFlags().BoolVar(&noRedirects, "no-follow-redirects", false, "fail a download that redirects instead of following it, e.g. to catch URLs bouncing off the CDN")
+	root.PersistentFlags().BoolVar(&traceRedirects, "trace", false, "log every redirect hop of a download to stderr")
+	root.PersistentFlags().StringVar(&runStatsMode, "stats", "", "when the command ends, print its API requests, retries, cache hits/misses and bytes downloaded to stderr (text, or json)")
+	root.PersistentFlags().Lookup("stats").NoOptDefVal = runStatsText
+	root.PersistentFlags().BoolVar(&quiet, "quiet", false, "don't show the download progress line (it is only shown when stderr is a terminal)")
+	root.PersistentFlags().BoolVar(&skipHash, "skip-hash", false, "keep downloads whose SHA-512 doesn't match Modrinth's instead of failing them (warns); not allowed with a download mirror")
+	root.PersistentFlags().DurationVar(&downloadIdleTimeout, "download-timeout", downloadIdleTimeout, "abort a download after this long without receiving data (0 = no limit)")
+	root.PersistentFlags().BoolVar(&useStagingAPI, "modrinth-staging", false, "use Modrinth's staging API (staging-api.modrinth.com) and its CDN instead of production, for testing integrations")
+	root.PersistentFlags().Float64Var(&qps, "qps", defaultQPS, "maximum Modrinth requests per second (0 = unlimited)")
+	root.PersistentFlags().StringVar(&concurrency, "concurrency", "auto", "how many jobs run in parallel: auto (CPU count for hashing, 8 for Modrinth lookups and downloads) or a number")
+	root.PersistentFlags().BoolVar(&compactState, "compact-state", false, "write state.json without indentation, to keep large machine-managed states small (also the config's compact_state)")
 
 	// list-packs
 	listPacks := &cobra.Command{
@@ -50,27 +223,87 @@ func main() {
 		Aliases: []string{"lp"},
 		Short:   "List all defined modpacks and their settings",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := LoadConfig(cfgFile)
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			names := listedPackNames(cfg, listAll)
+			if packsJSON {
+				listings := make([]packListing, 0, len(names))
+				for _, name := range names {
+					packCfg := cfg.Modpacks[name]
+					listings = append(listings, packListing{Name: name, Active: name == cfg.ActivePack, ModCount: len(cfg.EffectiveMods(packCfg)), Source: cfg.packSources[name], ModpackConfig: packCfg})
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(listings)
+			}
+			if hidden := len(cfg.Modpacks) - len(names); hidden > 0 {
+				defer fmt.Printf("(%d archived pack(s) hidden; --all shows them)\n", hidden)
+			}
+
+			if !listDetailed {
+				fmt.Println("Modpacks:")
+				for _, name := range names {
+					packCfg := cfg.Modpacks[name]
+					fmt.Printf(" • %s (MC: %s, Loader: %s%s)%s%s\n", name, packCfg.MCVersion, packCfg.Loader, ternary(packCfg.JavaVersion > 0, ", Java: "+javaLabel(packCfg.JavaVersion), ""), ternary(name == cfg.ActivePack, " [active]", ""), ternary(packCfg.Archived, " [archived]", ""))
+				}
+				return nil
+			}
+
+			state, err := LoadState(stateFile)
 			if err != nil {
 				return err
 			}
-			fmt.Println("Modpacks:")
-			for name, packCfg := range cfg.Modpacks {
-				fmt.Printf(" • %s (MC: %s, Loader: %s)\n", name, packCfg.MCVersion, packCfg.Loader)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			header := "NAME\tMC\tLOADER\tJAVA\tMODS\tIN STATE"
+			if listCheck {
+				header += "\tOUTDATED"
+			}
+			fmt.Fprintln(tw, header)
+			for _, name := range names {
+				packCfg := cfg.Modpacks[name]
+				mods := cfg.EffectiveMods(packCfg)
+				tracked := 0
+				for _, slug := range mods {
+					if _, ok := state[name][slug]; ok {
+						tracked++
+					}
+				}
+				row := fmt.Sprintf("%s%s%s\t%s\t%s\t%s\t%d\t%d", name, ternary(name == cfg.ActivePack, " *", ""), ternary(packCfg.Archived, " (archived)", ""), packCfg.MCVersion, packCfg.Loader, javaLabel(packCfg.JavaVersion), len(mods), tracked)
+				if listCheck {
+					outdated, failed := countOutdated(os.Stdout, mods, packCfg, state[name])
+					row += fmt.Sprintf("\t%d%s", outdated, ternary(failed > 0, fmt.Sprintf(" (%d unchecked)", failed), ""))
+				}
+				fmt.Fprintln(tw, row)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if cfg.ActivePack != "" {
+				fmt.Println("\n* active pack")
 			}
 			return nil
 		},
 	}
 
+	listPacks.Flags().BoolVar(&listDetailed, "detailed", false, "show mod and state counts in aligned columns")
+	listPacks.Flags().BoolVar(&listCheck, "check", false, "with --detailed, also query Modrinth for outdated counts")
+	listPacks.Flags().BoolVar(&listAll, "all", false, "include archived packs")
+	listPacks.Flags().BoolVar(&packsJSON, "json", false, "print every pack's name and full config as a JSON array (archived packs need --all)")
+
 	// list-mods
 	listMods := &cobra.Command{
 		Use:     "list-mods [modpack]",
 		Aliases: []string{"lm"},
 		Short:   "List all mods in a modpack",
-		Args:    cobra.ExactArgs(1),
+		Args:    cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			packName := args[0]
-			cfg, err := LoadConfig(cfgFile)
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packName, err := resolvePackName(cfg, args)
 			if err != nil {
 				return err
 			}
@@ -78,23 +311,60 @@ func main() {
 			if !ok {
 				return fmt.Errorf("modpack %q not found", packName)
 			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			rows, err := modRows(cfg, packName, state[packName], modsSort, modsCheck)
+			if err != nil {
+				return err
+			}
+			if sortReverse {
+				slices.Reverse(rows)
+			}
 			fmt.Printf("Mods in %s (MC: %s, Loader: %s):\n", packName, packCfg.MCVersion, packCfg.Loader)
-			for _, slug := range packCfg.Mods {
-				fmt.Printf(" • %s\n", slug)
+			for _, row := range rows {
+				line := " • " + row.Slug
+				if row.From != "" {
+					line += fmt.Sprintf(" (from %s)", row.From)
+				}
+				if len(row.Deps) > 0 {
+					line += fmt.Sprintf(" (dependency of %s)", strings.Join(row.Deps, ", "))
+				}
+				// Show the value the list is sorted by
+				switch modsSort {
+				case "version":
+					line += " — " + ternary(row.Version == "", "not installed", row.Version)
+				case "size":
+					line += " — " + ternary(row.Size < 0, "no file", humanSize(row.Size))
+				case "status":
+					line += " — " + row.Status
+				}
+				fmt.Println(line)
+				if verbose && row.Note != "" {
+					fmt.Printf("     %s\n", row.Note)
+				}
 			}
 			return nil
 		},
 	}
 
+	listMods.Flags().StringVar(&modsSort, "sort", "name", "order by name, version (installed), size (on disk), status, or config (the pack's own order)")
+	listMods.Flags().BoolVar(&sortReverse, "reverse", false, "reverse the --sort order")
+	listMods.Flags().BoolVar(&modsCheck, "check", false, "with --sort status, ask Modrinth which mods are outdated")
+
 	// add-mod
 	addMod := &cobra.Command{
 		Use:   "add-mod [modpack] [modSlugs...]",
-		Short: "Add one or more Modrinth slugs to a modpack",
+		Short: "Add one or more Modrinth slugs (or version links, which pin that build) to a modpack",
 		Args:  cobra.MinimumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, false); err != nil {
+				return err
+			}
 			packName := args[0]
 			slugs := args[1:]
-			cfg, err := LoadConfig(cfgFile)
+			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
@@ -102,25 +372,84 @@ func main() {
 			if !ok {
 				return fmt.Errorf("modpack %q not found", packName)
 			}
+			// All or nothing: edits go to copies, saved only if every slug passes its checks
+			packCfg.Mods = slices.Clone(packCfg.Mods)
+			packCfg.Pins = maps.Clone(packCfg.Pins)
+			reader := bufio.NewReader(os.Stdin)
 			changed := false
+			var rejected, added []string
 			for _, slug := range slugs {
-				exists := false
-				for _, m := range packCfg.Mods {
-					if m == slug {
-						fmt.Printf("%q already in %s\n", slug, packName)
-						exists = true
-						break
+				// A version link adds the mod pinned to exactly that build
+				if linkSlug, linkVersion, ok := ParseVersionURL(slug); ok {
+					ver, err := resolvePin(linkSlug, linkVersion, packCfg)
+					if err != nil {
+						fmt.Printf("✗ %v\n", err)
+						rejected = append(rejected, slug)
+						continue
+					}
+					slug = linkSlug
+					if packCfg.Pins == nil {
+						packCfg.Pins = make(map[string]string)
+					}
+					packCfg.Pins[slug] = ver.ID
+					fmt.Printf("Pinned %q to %s in %s\n", slug, showVer(ver), packName)
+					changed = true
+					if packCfg.Entry(slug) < 0 && cfg.InheritedFrom(packCfg, slug) == "" {
+						packCfg.Mods = append(packCfg.Mods, ModEntry{Slug: slug, Note: modNote})
+						fmt.Printf("Added %q to %s\n", slug, packName)
 					}
+					added = append(added, slug)
+					continue
 				}
-				if !exists {
-					packCfg.Mods = append(packCfg.Mods, slug)
+				if i := packCfg.Entry(slug); i >= 0 {
+					if modNote != "" && packCfg.Mods[i].Note != modNote {
+						packCfg.Mods[i].Note = modNote
+						fmt.Printf("Updated note for %q in %s\n", slug, packName)
+						changed = true
+					} else {
+						fmt.Printf("%q already in %s\n", slug, packName)
+					}
+				} else {
+					if group := cfg.InheritedFrom(packCfg, slug); group != "" {
+						fmt.Printf("%q already in %s (inherited from %s)\n", slug, packName, group)
+						continue
+					}
+					if !addForce && addValidate && !confirmRightPack(reader, cfg, slug, packName, packCfg) {
+						rejected = append(rejected, slug)
+						continue
+					}
+					if !addForce && !confirmCompatible(reader, slug, packName, packCfg) {
+						rejected = append(rejected, slug)
+						continue
+					}
+					packCfg.Mods = append(packCfg.Mods, ModEntry{Slug: slug, Note: modNote})
 					fmt.Printf("Added %q to %s\n", slug, packName)
+					added = append(added, slug)
 					changed = true
 				}
 			}
+			if len(rejected) > 0 && !addForce {
+				return fmt.Errorf("%d of %d mod(s) were not accepted (%s), so nothing was saved; fix them or pass --force to add the rest", len(rejected), len(slugs), strings.Join(rejected, ", "))
+			}
+			if addValidate && len(added) > 0 {
+				state, err := LoadState(stateFile)
+				if err != nil {
+					return err
+				}
+				problems := validateAdd(cfg, state, packName, packCfg, added)
+				for _, p := range problems {
+					fmt.Printf("✗ %s: %s\n", p.Slug, p.Problem)
+				}
+				if len(problems) > 0 && !addForce {
+					return fmt.Errorf("%s would have %d problem(s) after this add, so nothing was saved; pass --force to add anyway", packName, len(problems))
+				}
+				if len(problems) == 0 {
+					fmt.Printf("✓ %s validates with %s\n", packName, strings.Join(added, ", "))
+				}
+			}
 			if changed {
 				cfg.Modpacks[packName] = packCfg // Update the map entry
-				if err := SaveConfig(cfgFile, cfg); err != nil {
+				if err := saveConfig(cfg); err != nil {
 					return err
 				}
 			}
@@ -128,15 +457,73 @@ func main() {
 		},
 	}
 
+	addMod.Flags().StringVar(&modNote, "note", "", "note to store with the added mods (replaces the note of mods already in the pack)")
+	addMod.Flags().BoolVar(&addValidate, "validate", false, "also check each mod has builds for the pack's loader and MC version (naming the packs it fits if not), and before saving check the pack as it would be for duplicate projects, mods without a build, declared incompatibilities and known conflicts involving the new mods")
+	addMod.Flags().BoolVar(&addForce, "force", false, "skip the compatibility check (offline bulk adds) and save even if some mods were refused or --validate found problems")
+
+	// pin-version
+	pinVersion := &cobra.Command{
+		Use:   "pin-version [modpack] [version-url | slug version]",
+		Short: "Pin a mod to one Modrinth version, given its link or its slug and version ID/number",
+		Args:  cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, false); err != nil {
+				return err
+			}
+			packName := args[0]
+			var slug, version string
+			if len(args) == 3 {
+				slug, version = args[1], args[2]
+			} else if linkSlug, linkVersion, ok := ParseVersionURL(args[1]); ok {
+				slug, version = linkSlug, linkVersion
+			} else {
+				return fmt.Errorf("%q is not a Modrinth version link (https://modrinth.com/mod/<slug>/version/<version>); pass the slug and version separately instead", args[1])
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packCfg, ok := cfg.Modpacks[packName]
+			if !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			ver, err := resolvePin(slug, version, packCfg)
+			if err != nil {
+				return err
+			}
+			if packCfg.Pins[slug] == ver.ID {
+				fmt.Printf("%q is already pinned to %s in %s\n", slug, showVer(ver), packName)
+				return nil
+			}
+			if packCfg.Pins == nil {
+				packCfg.Pins = make(map[string]string)
+			}
+			packCfg.Pins[slug] = ver.ID
+			if packCfg.Entry(slug) < 0 && cfg.InheritedFrom(packCfg, slug) == "" {
+				packCfg.Mods = append(packCfg.Mods, ModEntry{Slug: slug})
+				fmt.Printf("Added %q to %s\n", slug, packName)
+			}
+			cfg.Modpacks[packName] = packCfg
+			if err := saveConfig(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("Pinned %q to %s in %s; run 'modpilot update %s' to install it\n", slug, showVer(ver), packName, packName)
+			return nil
+		},
+	}
+
 	// remove-mod
 	removeMod := &cobra.Command{
 		Use:   "remove-mod [modpack] [modSlugs...]",
 		Short: "Remove one or more Modrinth slugs from a modpack",
 		Args:  cobra.MinimumNArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, true); err != nil {
+				return err
+			}
 			packName := args[0]
 			rem := args[1:]
-			cfg, err := LoadConfig(cfgFile)
+			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
@@ -145,28 +532,54 @@ func main() {
 				return fmt.Errorf("modpack %q not found", packName)
 			}
 			origLen := len(packCfg.Mods)
-			for _, slug := range rem {
+			reader := bufio.NewReader(os.Stdin)
+			var rejected, removed []string
+			for i, slug := range rem {
+				// A typo would otherwise be a silent no-op; offer the pack's nearest slug instead
+				if packCfg.Entry(slug) < 0 && cfg.InheritedFrom(packCfg, slug) == "" {
+					if match, ok := closestSlug(slug, cfg.EffectiveMods(packCfg)); ok {
+						fmt.Printf("%q not in %s; did you mean %q?\n", slug, packName, match)
+						if autoYes || !askYesNo(reader, fmt.Sprintf("Remove %q instead? [y/N]: ", match)) {
+							rejected = append(rejected, slug)
+							continue
+						}
+						slug, rem[i] = match, match
+					}
+				}
 				found := false
-				newList := make([]string, 0, len(packCfg.Mods))
+				newList := make([]ModEntry, 0, len(packCfg.Mods))
 				for _, m := range packCfg.Mods {
-					if m == slug {
+					if m.Slug == slug {
 						found = true
 					} else {
 						newList = append(newList, m)
 					}
 				}
 				if !found {
-					fmt.Printf("%q not in %s\n", slug, packName)
+					if group := cfg.InheritedFrom(packCfg, slug); group != "" {
+						fmt.Printf("%q is inherited from shared group %q; edit the group in %s to remove it\n", slug, group, cfgFile)
+					} else {
+						fmt.Printf("%q not in %s\n", slug, packName)
+					}
+					rejected = append(rejected, slug)
 				} else {
 					packCfg.Mods = newList
-					fmt.Printf("Removed %q from %s\n", slug, packName)
+					removed = append(removed, slug)
 				}
 			}
+			// All or nothing, like add-mod; packCfg.Mods was replaced rather than edited, so the loaded config is untouched
+			if len(rejected) > 0 && !partialEdit && len(packCfg.Mods) != origLen {
+				return fmt.Errorf("%d of %d mod(s) could not be removed (%s), so nothing was saved; pass --partial to remove the rest", len(rejected), len(rem), strings.Join(rejected, ", "))
+			}
 			if len(packCfg.Mods) != origLen {
 				cfg.Modpacks[packName] = packCfg // Update the map entry
-				if err := SaveConfig(cfgFile, cfg); err != nil {
+				if err := saveConfig(cfg); err != nil {
 					return err
 				}
+				// Only now is the removal real
+				for _, slug := range removed {
+					fmt.Printf("Removed %q from %s\n", slug, packName)
+				}
 
 				// Also remove from state
 				state, err := LoadState(stateFile)
@@ -175,17 +588,31 @@ func main() {
 					fmt.Printf("Warning: could not load state file to remove mod entries: %v\n", err)
 				} else if packState, ok := state[packName]; ok {
 					stateChanged := false
+					remaining := cfg.EffectiveMods(packCfg)
 					for _, slug := range rem {
-						if _, exists := packState[slug]; exists {
+						// Keep state for slugs the pack still gets from a shared group
+						if ms, exists := packState[slug]; exists && !slices.Contains(remaining, slug) {
+							// Still needed by something the pack keeps: leave it installed as a dependency
+							dependents := slices.DeleteFunc(slices.Clone(ms.RequiredBy), func(d string) bool {
+								_, installed := packState[d]
+								return !installed || slices.Contains(rem, d)
+							})
+							if len(dependents) > 0 {
+								fmt.Printf("%q is still required by %s; kept installed as a dependency\n", slug, strings.Join(dependents, ", "))
+								continue
+							}
 							delete(packState, slug)
 							stateChanged = true
 							if verbose {
 								fmt.Printf("Removed %q from state for %s\n", slug, packName)
 							}
+							if deleteFile && ms.Filename != "" {
+								removeModFile(filepath.Join(modsDir, packName, ms.Filename))
+							}
 						}
 					}
 					if stateChanged {
-						if err := SaveState(stateFile, state); err != nil {
+						if err := saveState(state); err != nil {
 							fmt.Printf("Warning: could not save updated state file: %v\n", err)
 						}
 					}
@@ -195,14 +622,88 @@ func main() {
 		},
 	}
 
+	removeMod.Flags().BoolVar(&partialEdit, "partial", false, "remove the slugs that are in the pack even if others aren't (by default nothing is saved then)")
+	removeMod.Flags().BoolVar(&deleteFile, "delete-file", false, "also delete each removed mod's jar (the filename recorded in state) from the pack directory")
+
+	// reorder-mods
+	reorderMods := &cobra.Command{
+		Use:   "reorder-mods [modpack] [modSlugs...]",
+		Short: "Reorder a modpack's mods: listed slugs first, the rest after in their current order",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, false); err != nil {
+				return err
+			}
+			packName := args[0]
+			order := args[1:]
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packCfg, ok := cfg.Modpacks[packName]
+			if !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			if cfg.SortMods && reorderSort != "alpha" {
+				return fmt.Errorf("sort_mods is enabled in %s, so mod lists are alphabetized on save; disable it to keep a manual order", cfgFile)
+			}
+			var newList []ModEntry
+			switch {
+			case reorderSort == "alpha":
+				if len(order) > 0 {
+					return fmt.Errorf("--sort alpha does not take a slug order")
+				}
+				newList = append(newList, packCfg.Mods...)
+				sort.SliceStable(newList, func(i, j int) bool { return newList[i].Slug < newList[j].Slug })
+			case reorderSort != "":
+				return fmt.Errorf("unknown --sort %q (want alpha)", reorderSort)
+			case len(order) == 0:
+				return fmt.Errorf("give the slugs in the desired order, or use --sort alpha")
+			default:
+				placed := make(map[string]bool, len(order))
+				for _, slug := range order {
+					i := packCfg.Entry(slug)
+					if i < 0 {
+						return fmt.Errorf("%q not in %s", slug, packName)
+					}
+					if !placed[slug] {
+						newList = append(newList, packCfg.Mods[i])
+						placed[slug] = true
+					}
+				}
+				for _, m := range packCfg.Mods {
+					if !placed[m.Slug] {
+						newList = append(newList, m)
+					}
+				}
+			}
+			packCfg.Mods = newList
+			cfg.Modpacks[packName] = packCfg // Update the map entry
+			if err := saveConfig(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("Reordered %d mod(s) in %s\n", len(newList), packName)
+			if verbose {
+				for _, m := range newList {
+					fmt.Printf(" • %s\n", m.Slug)
+				}
+			}
+			return nil
+		},
+	}
+	reorderMods.Flags().StringVar(&reorderSort, "sort", "", "sort instead of giving an explicit order (alpha)")
+
 	// create-pack
 	createPack := &cobra.Command{
 		Use:   "create-pack [modpack]",
 		Short: "Create a new modpack in the config, prompting for settings",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, false); err != nil {
+				return err
+			}
 			name := args[0]
-			cfg, err := LoadConfig(cfgFile)
+			cfg, err := loadConfig()
 			if err != nil && !os.IsNotExist(err) {
 				return err
 			} else if err != nil { // File doesn't exist, create new config
@@ -214,44 +715,52 @@ func main() {
 			}
 
 			reader := bufio.NewReader(os.Stdin)
-			var mcVersion, loader string
+			// --mc-version/--loader answer the prompts up front, for scripts
+			mcVersion, loader := mcVersionFlag, loaderFlag
+			var eof bool
 
 			// Prompt for MC Version, using default if available
-			promptMC := fmt.Sprintf("Enter Minecraft version for %s", name)
-			if cfg.DefaultMCVersion != "" {
-				promptMC += fmt.Sprintf(" (default: %s)", cfg.DefaultMCVersion)
+			if mcVersion == "" {
+				promptMC := fmt.Sprintf("Enter Minecraft version for %s", name)
+				if cfg.DefaultMCVersion != "" {
+					promptMC += fmt.Sprintf(" (default: %s)", cfg.DefaultMCVersion)
+				}
+				fmt.Print(promptMC + ": ")
+				mcVersion, eof = readLine(reader)
 			}
-			fmt.Print(promptMC + ": ")
-			mcVersion, _ = reader.ReadString('\n')
-			mcVersion = strings.TrimSpace(mcVersion)
 			if mcVersion == "" {
 				mcVersion = cfg.DefaultMCVersion // Use default if input is empty
 			}
-			if mcVersion == "" {
+			if mcVersion == "" && eof {
+				return fmt.Errorf("Minecraft version cannot be empty (no input; pass --mc-version)")
+			} else if mcVersion == "" {
 				return fmt.Errorf("Minecraft version cannot be empty")
 			}
 
 			// Prompt for Loader, using default if available
-			promptLoader := fmt.Sprintf("Enter mod loader for %s (e.g., fabric, forge)", name)
-			if cfg.DefaultLoader != "" {
-				promptLoader += fmt.Sprintf(" (default: %s)", cfg.DefaultLoader)
+			if loader == "" {
+				promptLoader := fmt.Sprintf("Enter mod loader for %s (e.g., fabric, forge)", name)
+				if cfg.DefaultLoader != "" {
+					promptLoader += fmt.Sprintf(" (default: %s)", cfg.DefaultLoader)
+				}
+				fmt.Print(promptLoader + ": ")
+				loader, eof = readLine(reader)
 			}
-			fmt.Print(promptLoader + ": ")
-			loader, _ = reader.ReadString('\n')
-			loader = strings.TrimSpace(loader)
 			if loader == "" {
 				loader = cfg.DefaultLoader // Use default if input is empty
 			}
-			if loader == "" {
+			if loader == "" && eof {
+				return fmt.Errorf("mod loader cannot be empty (no input; pass --loader)")
+			} else if loader == "" {
 				return fmt.Errorf("mod loader cannot be empty")
 			}
 
 			cfg.Modpacks[name] = ModpackConfig{
 				MCVersion: mcVersion,
 				Loader:    loader,
-				Mods:      []string{},
+				Mods:      []ModEntry{},
 			}
-			if err := SaveConfig(cfgFile, cfg); err != nil {
+			if err := saveConfig(cfg); err != nil {
 				return err
 			}
 			fmt.Printf("Created modpack %q with MC %s and loader %s\n", name, mcVersion, loader)
@@ -259,14 +768,124 @@ func main() {
 		},
 	}
 
+	// import-dir
+	importDir := &cobra.Command{
+		Use:   "import-dir [dir] [modpack]",
+		Short: "Create a modpack from a folder of jars, identifying each on Modrinth and adopting the files",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, true); err != nil {
+				return err
+			}
+			dir, name := args[0], args[1]
+			cfg, err := loadConfig()
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			} else if err != nil {
+				cfg = &Config{Modpacks: make(map[string]ModpackConfig)}
+			}
+			if _, exists := cfg.Modpacks[name]; exists {
+				return fmt.Errorf("modpack %q already exists; import into a new pack name", name)
+			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+
+			jars, err := identifyJars(dir)
+			if err != nil {
+				return err
+			}
+			if len(jars) == 0 {
+				return fmt.Errorf("no jars found in %s", dir)
+			}
+			mcVersion, loader, warnings := inferTarget(jars)
+			if mcVersionFlag != "" {
+				mcVersion = mcVersionFlag
+			}
+			if loaderFlag != "" {
+				loader = loaderFlag
+			}
+			if mcVersion == "" || loader == "" {
+				return fmt.Errorf("could not tell the MC version and loader from the jars in %s; pass --mc-version and --loader", dir)
+			}
+
+			packCfg := ModpackConfig{MCVersion: mcVersion, Loader: loader, Mods: []ModEntry{}}
+			packState := make(map[string]ModState)
+			destDir := filepath.Join(modsDir, name)
+			sameDir := false
+			if a, err := filepath.Abs(dir); err == nil {
+				if b, err := filepath.Abs(destDir); err == nil {
+					sameDir = a == b
+				}
+			}
+			var unidentified, notAdopted []string
+			for _, j := range jars {
+				switch {
+				case j.Slug == "":
+					fmt.Printf("? %s: %v\n", j.File, j.Err)
+					unidentified = append(unidentified, j.File)
+					continue
+				case packCfg.Entry(j.Slug) >= 0:
+					fmt.Printf("? %s: another jar already provides %s; skipped\n", j.File, j.Slug)
+					unidentified = append(unidentified, j.File)
+					continue
+				}
+				packCfg.Mods = append(packCfg.Mods, ModEntry{Slug: j.Slug})
+				if j.Version == nil {
+					// A local or repackaged build: the mod is known, but not this file
+					fmt.Printf("~ %s → %s (from its %s metadata; not adopted, update installs Modrinth's build)\n", j.File, j.Slug, j.Jar.Loader)
+					notAdopted = append(notAdopted, j.File)
+					continue
+				}
+				fmt.Printf("✓ %s → %s %s\n", j.File, j.Slug, showVer(j.Version))
+				if !sameDir && !dryRun {
+					if err := os.MkdirAll(destDir, 0755); err != nil {
+						return err
+					}
+					if err := copyFile(filepath.Join(dir, j.File), filepath.Join(destDir, j.File)); err != nil {
+						return fmt.Errorf("failed to copy %s into %s: %w", j.File, destDir, err)
+					}
+				}
+				packState[j.Slug] = ModState{VersionID: j.Version.ID, VersionNumber: j.Version.VersionNumber, Filename: j.File, SHA512: j.SHA512}
+			}
+			for _, w := range warnings {
+				fmt.Printf("⚠ %s\n", w)
+			}
+			if len(packCfg.Mods) == 0 {
+				return fmt.Errorf("none of the %d jar(s) in %s could be identified, so no pack was created", len(jars), dir)
+			}
+
+			cfg.Modpacks[name] = packCfg
+			state[name] = packState
+			if err := saveConfig(cfg); err != nil {
+				return err
+			}
+			if err := saveState(state); err != nil {
+				return err
+			}
+			fmt.Printf("Created modpack %q (MC %s, %s) with %d mod(s), %d adopted from %s\n", name, mcVersion, loader, len(packCfg.Mods), len(packState), ternary(sameDir, dir, dir+" into "+destDir))
+			if len(unidentified) > 0 {
+				fmt.Printf("%d file(s) were not imported: %s\n", len(unidentified), strings.Join(unidentified, ", "))
+			}
+			if len(notAdopted) > 0 {
+				fmt.Printf("Run 'modpilot update %s' to install Modrinth builds of %d mod(s) that weren't adopted\n", name, len(notAdopted))
+			}
+			return nil
+		},
+	}
+
 	// delete-pack
 	deletePack := &cobra.Command{
 		Use:   "delete-pack [modpack]",
 		Short: "Delete a modpack from the config",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, false); err != nil {
+				return err
+			}
 			name := args[0]
-			cfg, err := LoadConfig(cfgFile)
+			cfg, err := loadConfig()
 			if err != nil {
 				return err
 			}
@@ -275,7 +894,10 @@ func main() {
 				return nil
 			}
 			delete(cfg.Modpacks, name)
-			if err := SaveConfig(cfgFile, cfg); err != nil {
+			if cfg.ActivePack == name {
+				cfg.ActivePack = ""
+			}
+			if err := saveConfig(cfg); err != nil {
 				return err
 			}
 			fmt.Printf("Deleted modpack %q\n", name)
@@ -287,46 +909,324 @@ func main() {
 			return nil
 		},
 	}
-	// init
-	initCmd := &cobra.Command{
-		Use:   "init",
-		Short: "Initialize or update config/state files, setting global defaults",
+	// archive-pack / unarchive-pack
+	setArchived := func(name string, archived bool) error {
+		if err := checkWritable(true, false); err != nil {
+			return err
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		packCfg, ok := cfg.Modpacks[name]
+		if !ok {
+			return fmt.Errorf("modpack %q not found", name)
+		}
+		if packCfg.Archived == archived {
+			fmt.Printf("modpack %q is already %s\n", name, ternary(archived, "archived", "active"))
+			return nil
+		}
+		packCfg.Archived = archived
+		cfg.Modpacks[name] = packCfg
+		if archived && cfg.ActivePack == name {
+			cfg.ActivePack = ""
+			fmt.Printf("Unset %q as the active pack\n", name)
+		}
+		if err := saveConfig(cfg); err != nil {
+			return err
+		}
+		fmt.Printf("%s modpack %q\n", ternary(archived, "Archived", "Unarchived"), name)
+		return nil
+	}
+	archivePack := &cobra.Command{
+		Use:   "archive-pack [modpack]",
+		Short: "Hide a modpack from list-packs and stats without deleting it",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := LoadConfig(cfgFile)
-			if err != nil && !os.IsNotExist(err) {
-				return err
-			} else if err != nil { // File doesn't exist, create new config
-				cfg = &Config{Modpacks: make(map[string]ModpackConfig)}
-			}
-			reader := bufio.NewReader(os.Stdin)
+			return setArchived(args[0], true)
+		},
+	}
+	unarchivePack := &cobra.Command{
+		Use:   "unarchive-pack [modpack]",
+		Short: "Restore an archived modpack",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setArchived(args[0], false)
+		},
+	}
 
-			// Prompt for Default MC Version
-			fmt.Printf("Enter default Minecraft version (optional, e.g. 1.19.2) [%s]: ", cfg.DefaultMCVersion)
-			v, _ := reader.ReadString('\n')
-			v = strings.TrimSpace(v)
-			if v != "" { // Only update if user provided input
-				cfg.DefaultMCVersion = v
+	// freeze-all / unfreeze-all
+	setAllFrozen := func(args []string, frozen bool) error {
+		if err := checkWritable(true, false); err != nil {
+			return err
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		packName, err := resolvePackName(cfg, args)
+		if err != nil {
+			return err
+		}
+		packCfg, ok := cfg.Modpacks[packName]
+		if !ok {
+			return fmt.Errorf("modpack %q not found", packName)
+		}
+		mods := cfg.EffectiveMods(packCfg)
+		changed, already := 0, 0
+		for _, slug := range mods {
+			if slices.Contains(packCfg.Frozen, slug) == frozen {
+				already++
+				continue
 			}
-
-			// Prompt for Default Loader
-			fmt.Printf("Enter default mod loader (optional, e.g. fabric/forge) [%s]: ", cfg.DefaultLoader)
-			l, _ := reader.ReadString('\n')
-			l = strings.TrimSpace(l)
-			if l != "" { // Only update if user provided input
-				cfg.DefaultLoader = l
+			if frozen {
+				packCfg.Frozen = append(packCfg.Frozen, slug)
 			}
-
-			if err := SaveConfig(cfgFile, cfg); err != nil {
+			changed++
+		}
+		// Unfreezing also drops entries for mods the pack no longer has, which aren't counted
+		dirty := changed > 0 || (!frozen && len(packCfg.Frozen) > 0)
+		if !frozen {
+			packCfg.Frozen = nil
+		}
+		verb := ternary(frozen, "frozen", "unfrozen")
+		if dirty {
+			cfg.Modpacks[packName] = packCfg
+			if err := saveConfig(cfg); err != nil {
 				return err
 			}
-			fmt.Printf("Saved config at %s\n", cfgFile)
-			// Create state if missing
-			if _, err := os.Stat(stateFile); os.IsNotExist(err) {
-				if err := SaveState(stateFile, make(State)); err != nil {
-					return err
-				}
-				fmt.Printf("Created default state at %s\n", stateFile)
-			}
+		}
+		if changed == 0 {
+			fmt.Printf("All %d mod(s) in %s are already %s\n", len(mods), packName, verb)
+			return nil
+		}
+		fmt.Printf("%s %d mod(s) in %s (%d already %s)\n", ternary(frozen, "Froze", "Unfroze"), changed, packName, already, verb)
+		return nil
+	}
+	freezeAll := &cobra.Command{
+		Use:   "freeze-all [modpack]",
+		Short: "Freeze every mod in a modpack so update and check-updates leave them alone",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setAllFrozen(args, true)
+		},
+	}
+	unfreezeAll := &cobra.Command{
+		Use:   "unfreeze-all [modpack]",
+		Short: "Clear the frozen flag from every mod in a modpack",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setAllFrozen(args, false)
+		},
+	}
+
+	// use-pack
+	usePack := &cobra.Command{
+		Use:   "use-pack [modpack]",
+		Short: "Set the active modpack used when a command's pack argument is omitted",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(len(args) > 0 || usePackClear, false); err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				if usePackClear {
+					cfg.ActivePack = ""
+					if err := saveConfig(cfg); err != nil {
+						return err
+					}
+					fmt.Println("Cleared active modpack")
+				} else if cfg.ActivePack == "" {
+					fmt.Println("No active modpack set")
+				} else {
+					fmt.Printf("Active modpack: %s\n", cfg.ActivePack)
+				}
+				return nil
+			}
+			name := args[0]
+			if _, ok := cfg.Modpacks[name]; !ok {
+				return fmt.Errorf("modpack %q not found", name)
+			}
+			cfg.ActivePack = name
+			if err := saveConfig(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("Active modpack set to %q\n", name)
+			return nil
+		},
+	}
+	usePack.Flags().BoolVar(&usePackClear, "clear", false, "unset the active modpack")
+
+	// migrate-loader
+	migrateLoader := &cobra.Command{
+		Use:   "migrate-loader [modpack] [loader]",
+		Short: "Switch a modpack to another loader after reporting which mods have builds for it",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, migrateFetch); err != nil {
+				return err
+			}
+			packName, newLoader := args[0], canonicalLoader(args[1])
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packCfg, ok := cfg.Modpacks[packName]
+			if !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			if canonicalLoader(packCfg.Loader) == newLoader {
+				return fmt.Errorf("%s already uses %s", packName, newLoader)
+			}
+
+			fmt.Printf("Checking %s mods for %s builds (MC %s)...\n", packName, newLoader, packCfg.MCVersion)
+			resolved := make(map[string]*Version)
+			var missing []string
+			mods := cfg.EffectiveMods(packCfg)
+			for _, slug := range mods {
+				ver, err := FetchLatestVersionForChannel(slug, packCfg.MCVersion, newLoader, packCfg.ChannelFor(slug))
+				if err != nil {
+					fmt.Printf("  ✗ %s: %v\n", slug, err)
+					missing = append(missing, slug)
+					continue
+				}
+				resolved[slug] = ver
+				fmt.Printf("  ✓ %s: %s\n", slug, showVer(ver))
+			}
+			fmt.Printf("\n%d of %d mod(s) have a %s build.\n", len(resolved), len(mods), newLoader)
+			if len(missing) > 0 {
+				fmt.Printf("Without a build: %s\n", strings.Join(missing, ", "))
+			}
+			if len(packCfg.Pins) > 0 {
+				fmt.Printf("%d pin(s) refer to %s builds and will be dropped.\n", len(packCfg.Pins), packCfg.Loader)
+			}
+
+			if dryRun {
+				fmt.Printf("[dry-run] would switch %s from %s to %s\n", packName, packCfg.Loader, newLoader)
+				return nil
+			}
+			reader := bufio.NewReader(os.Stdin)
+			confirm := func(question string) bool {
+				if autoYes {
+					return true
+				}
+				return askYesNo(reader, question+" [y/N]: ")
+			}
+			if !confirm(fmt.Sprintf("Switch %s from %s to %s?", packName, packCfg.Loader, newLoader)) {
+				fmt.Println("Aborted; nothing changed.")
+				return nil
+			}
+			oldLoader := packCfg.Loader
+			packCfg.Loader = newLoader
+			packCfg.Pins = nil
+			cfg.Modpacks[packName] = packCfg
+			if err := saveConfig(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("Switched %s from %s to %s.\n", packName, oldLoader, newLoader)
+
+			if !migrateFetch {
+				fmt.Printf("Run 'modpilot update %s' to replace the %s jars.\n", packName, oldLoader)
+				return nil
+			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			dir := filepath.Join(modsDir, packName)
+			if !confirm(fmt.Sprintf("Delete the %d %s jar(s) recorded for %s and download the %d %s build(s)?", len(state[packName]), oldLoader, packName, len(resolved), newLoader)) {
+				fmt.Printf("Kept the existing jars; run 'modpilot update %s' when ready.\n", packName)
+				return nil
+			}
+			for _, ms := range state[packName] {
+				if ms.Filename != "" {
+					if err := os.Remove(filepath.Join(dir, ms.Filename)); err != nil && !os.IsNotExist(err) {
+						fmt.Printf("  ✗ Failed to remove %s: %v\n", ms.Filename, err)
+					}
+				}
+			}
+			packState := make(map[string]ModState)
+			state[packName] = packState
+			failed := 0
+			for _, slug := range mods {
+				ver, ok := resolved[slug]
+				if !ok {
+					continue
+				}
+				fmt.Printf("Downloading %s %s...\n", slug, ver.VersionNumber)
+				ms, err := installVersion(ver, dir, slug, "")
+				if err != nil {
+					fmt.Printf("  ✗ %s: %v\n", slug, err)
+					failed++
+					continue
+				}
+				packState[slug] = ms
+			}
+			if err := saveState(state); err != nil {
+				return err
+			}
+			fmt.Printf("\nDownloaded %d %s build(s), %d failed, %d with no build.\n", len(packState), newLoader, failed, len(missing))
+			return nil
+		},
+	}
+	migrateLoader.Flags().BoolVar(&migrateFetch, "download", false, "after switching, replace the old jars with the new loader's builds (asks first)")
+
+	// init
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Initialize or update config/state files, setting global defaults",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(true, true); err != nil {
+				return err
+			}
+			if packDir != "" && dryRun {
+				fmt.Printf("[dry-run] would create %s\n", modsDir)
+			} else if packDir != "" {
+				if err := os.MkdirAll(modsDir, 0755); err != nil {
+					return fmt.Errorf("failed to create pack directory: %w", err)
+				}
+			}
+			cfg, err := loadConfig()
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			} else if err != nil { // File doesn't exist, create new config
+				cfg = &Config{Modpacks: make(map[string]ModpackConfig)}
+			}
+			reader := bufio.NewReader(os.Stdin)
+
+			// Prompt for Default MC Version
+			fmt.Printf("Enter default Minecraft version (optional, e.g. 1.19.2) [%s]: ", cfg.DefaultMCVersion)
+			v, _ := readLine(reader)
+			if v != "" { // Only update if user provided input
+				cfg.DefaultMCVersion = v
+			}
+
+			// Prompt for Default Loader
+			fmt.Printf("Enter default mod loader (optional, e.g. fabric/forge) [%s]: ", cfg.DefaultLoader)
+			l, _ := readLine(reader)
+			if l != "" { // Only update if user provided input
+				cfg.DefaultLoader = l
+			}
+
+			if err := saveConfig(cfg); err != nil {
+				return err
+			}
+			if !dryRun {
+				fmt.Printf("Saved config at %s\n", cfgFile)
+			}
+			// Create state if missing
+			if _, err := os.Stat(stateFile); os.IsNotExist(err) {
+				if err := saveState(make(State)); err != nil {
+					return err
+				}
+				if !dryRun {
+					fmt.Printf("Created default state at %s\n", stateFile)
+				}
+			}
 			return nil
 		},
 	}
@@ -336,10 +1236,31 @@ func main() {
 		Use:     "update [modpack]",
 		Aliases: []string{"update-pack", "upd"},
 		Short:   "Check & download new versions for a modpack",
-		Args:    cobra.ExactArgs(1),
+		Args:    cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			packName := args[0]
-			cfg, err := LoadConfig(cfgFile)
+			if planOut != "" {
+				resolveOnly = true
+			}
+			if fromPlan != "" && (resolveOnly || interactive || askChannels) {
+				return fmt.Errorf("--from-plan can't be combined with --resolve-only, --plan-out, --interactive or --interactive-channels")
+			}
+			if err := checkWritable((interactive || askChannels) && !resolveOnly, !resolveOnly); err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var saved *UpdatePlan // --from-plan
+			if fromPlan != "" {
+				if saved, err = LoadPlan(fromPlan); err != nil {
+					return err
+				}
+				if len(args) == 0 {
+					args = []string{saved.Pack}
+				}
+			}
+			packName, err := resolvePackName(cfg, args)
 			if err != nil {
 				return err
 			}
@@ -347,20 +1268,78 @@ func main() {
 			if !ok {
 				return fmt.Errorf("modpack %q not found", packName)
 			}
+			if saved != nil && saved.Pack != packName {
+				return fmt.Errorf("%s is a plan for %s, not %s", fromPlan, saved.Pack, packName)
+			}
+			if interactive && autoYes {
+				return fmt.Errorf("--interactive and --yes can't be combined")
+			}
+			if planConfirm && interactive {
+				return fmt.Errorf("--plan-confirm and --interactive can't be combined")
+			}
+			if askChannels && autoYes {
+				return fmt.Errorf("--interactive-channels and --yes can't be combined")
+			}
+			switch updateEnv {
+			case "", "client", "server", "both":
+			default:
+				return fmt.Errorf("unknown --env %q (want client, server or both)", updateEnv)
+			}
+
+			reader := sharedReader
+			if reader == nil {
+				reader = bufio.NewReader(os.Stdin)
+			}
+
+			// --interactive-channels: settle each mod's channel and save it before anything is resolved
+			if askChannels && !resolveOnly {
+				if promptChannels(reader, cfg.EffectiveMods(packCfg), &packCfg) {
+					cfg.Modpacks[packName] = packCfg
+					if err := saveConfig(cfg); err != nil {
+						return err
+					}
+				}
+			}
 
 			// Use pack-specific version and loader
 			gameVersion := packCfg.MCVersion
 			loader := packCfg.Loader
 
+			// Keep stdout clean for a JSON plan
+			var notice io.Writer = os.Stdout
+			if resolveOnly && planJSON {
+				notice = os.Stderr
+			}
+
 			// Allow overriding via flags (optional, keep or remove this logic)
 			if mcVersionFlag != "" {
-				fmt.Printf("Overriding MC version for %s: %s -> %s\n", packName, gameVersion, mcVersionFlag)
+				fmt.Fprintf(notice, "Overriding MC version for %s: %s -> %s\n", packName, gameVersion, mcVersionFlag)
 				gameVersion = mcVersionFlag
 			}
 			if loaderFlag != "" {
-				fmt.Printf("Overriding loader for %s: %s -> %s\n", packName, loader, loaderFlag)
+				fmt.Fprintf(notice, "Overriding loader for %s: %s -> %s\n", packName, loader, loaderFlag)
 				loader = loaderFlag
 			}
+			// An override that doesn't match the pack usually makes every mod come back incompatible
+			if gameVersion != packCfg.MCVersion || loader != packCfg.Loader {
+				fmt.Fprintln(notice, "!!! WARNING: the --mc-version/--loader override differs from the pack's config.")
+				fmt.Fprintln(notice, "!!! Mods without a build for the override will fail, and downloads will replace files built for the pack's own settings.")
+				if !autoYes && !forceOverride && !resolveOnly {
+					return fmt.Errorf("refusing to update %s with a mismatched override; pass --yes or --force-override to continue", packName)
+				}
+			}
+
+			if saved != nil && (saved.MCVersion != gameVersion || saved.Loader != loader) {
+				return fmt.Errorf("%s was resolved for MC %s (%s), not %s (%s)", fromPlan, saved.MCVersion, saved.Loader, gameVersion, loader)
+			}
+
+			inRange, err := rangeVersions()
+			if err != nil {
+				return err
+			}
+			if inRange != nil {
+				fmt.Fprintf(notice, "Accepting builds for MC %s (%s)\n", mcRange, strings.Join(inRange, ", "))
+			}
 
 			state, err := LoadState(stateFile)
 			if err != nil {
@@ -369,133 +1348,322 @@ func main() {
 			if state[packName] == nil {
 				state[packName] = make(map[string]ModState)
 			}
-			reader := bufio.NewReader(os.Stdin)
+			// Per-mod status lines; prompts and the closing summary always print
+			var progress io.Writer = os.Stdout
+			if summaryOnly || resolveOnly {
+				progress = io.Discard
+			}
 			packState := state[packName]
-			needsSave := false
+			configChanged := false // pins/freezes chosen during --interactive
+			plan := &UpdatePlan{Pack: packName, MCVersion: gameVersion, Loader: loader}
 
-			for _, slug := range packCfg.Mods {
-				fmt.Printf("\nChecking %s...\n", slug) // Simplified initial message
+			// Mods that failed last time go first, so a transient failure heals before anything else can go wrong
+			queue := loadRetryQueue()
+			mods := cfg.EffectiveMods(packCfg)
+			if retry := queuedMods(mods, queue[packName]); len(retry) > 0 && !resolveOnly {
+				mods = append(retry, slices.DeleteFunc(mods, func(slug string) bool { return slices.Contains(retry, slug) })...)
+				fmt.Fprintf(notice, "Retrying %d mod(s) that failed last run first: %s\n", len(retry), strings.Join(retry, ", "))
+			}
 
-				modState, modInState := packState[slug]
-				fileExists := false
-				destDir := filepath.Join(modsDir, packName)
-				expectedFilePath := ""
-				if modInState && modState.Filename != "" {
-					expectedFilePath = filepath.Join(destDir, modState.Filename)
-					if _, err := os.Stat(expectedFilePath); err == nil {
-						fileExists = true
-					} else if !os.IsNotExist(err) {
-						fmt.Printf("  ✗ Error checking file %s: %v\n", expectedFilePath, err)
-						// Decide whether to continue or skip based on error? For now, continue.
+			u := newPackUpdate(packName, packCfg, gameVersion, loader, packState, mods, progress)
+			u.inRange, u.readOnly, u.hints = inRange, resolveOnly, !resolveOnly
+			report := u.report
+			// With --staging every write goes to a copy of the pack directory that only replaces the live one once all downloads succeed
+			if useStaging && !resolveOnly && !dryRun {
+				if err := u.startStaging(); err != nil {
+					return err
+				}
+			}
+
+			// --plan-confirm: check every mod first, show the plan and ask once, then download exactly what was shown
+			confirm := planConfirm && !resolveOnly && !autoYes && !dryRun
+			if confirm {
+				u.out = io.Discard
+			}
+			var planned []*modUpdate
+
+			if saved != nil {
+				// --from-plan: the saved plan's downloads, without resolving anything again
+				for _, e := range saved.Mods {
+					m := u.planned(e)
+					plan.Mods = append(plan.Mods, m.PlanEntry)
+					if m.pending() {
+						planned = append(planned, m)
 					}
 				}
+			} else {
+				u.prefetch()
+			}
 
-				ver, err := FetchLatestVersion(slug, gameVersion, loader)
-				if err != nil {
-					fmt.Printf("  ✗ Error fetching latest version: %v\n", err)
+			// Required dependencies the pack doesn't list are appended to u.mods as they're found
+		modLoop:
+			for i := 0; i < len(u.mods) && saved == nil && runCtx.Err() == nil; i++ {
+				m := u.check(u.mods[i])
+				if resolveOnly || confirm {
+					plan.Mods = append(plan.Mods, m.PlanEntry)
+				}
+				if resolveOnly || !m.pending() {
 					continue
 				}
-
-				// Determine reason for action
-				promptMessage := ""
-				needsDownload := false
-
-				if !modInState {
-					needsDownload = true
-					promptMessage = fmt.Sprintf("  + New mod found: %s (Version: %s). Download?", slug, ver.ID)
-				} else if ver.ID != modState.VersionID {
-					needsDownload = true
-					promptMessage = fmt.Sprintf("  ⚠ Update available: %s (%s -> %s). Update?", slug, modState.VersionID, ver.ID)
-				} else if !fileExists {
-					needsDownload = true
-					// Slightly different message if filename was known vs unknown (old state format)
-					if modState.Filename != "" {
-						promptMessage = fmt.Sprintf("  ! File missing: %s (Version: %s). Redownload?", slug, ver.ID)
-					} else {
-						promptMessage = fmt.Sprintf("  ! File needed: %s (Version: %s). Download?", slug, ver.ID)
-					}
-				} else {
-					// Up to date and file exists
-					fmt.Printf("  ✓ Up to date (%s)\n", ver.ID)
-					continue // Skip to next mod
+				if confirm {
+					planned = append(planned, m)
+					continue
 				}
+				slug, modState := m.Slug, m.old
 
 				// Ask user if needed
 				proceed := autoYes
-				if needsDownload && !proceed { // Only prompt if a download is actually needed
-					fmt.Print(promptMessage + " (y/N) ")
-					yn, _ := reader.ReadString('\n')
-					yn = strings.TrimSpace(strings.ToLower(yn))
-					if yn == "y" {
+				if !proceed && interactive {
+					switch promptModAction(reader, m.prompt, m.ver, m.inState) {
+					case "u":
 						proceed = true
+					case "p":
+						if packCfg.Pins == nil {
+							packCfg.Pins = make(map[string]string)
+						}
+						packCfg.Pins[slug] = modState.VersionID
+						configChanged = true
+						fmt.Printf("    📌 Pinned %s at %s\n", slug, showInstalled(modState))
+						report.Add(slug, modState.VersionID, modState.VersionID, outcomePinned, nil)
+						continue
+					case "f":
+						packCfg.Frozen = append(packCfg.Frozen, slug)
+						configChanged = true
+						fmt.Printf("    ❄ Froze %s\n", slug)
+						report.Add(slug, modState.VersionID, modState.VersionID, outcomeFrozen, nil)
+						continue
+					case "q":
+						fmt.Println("    Quitting; remaining mods not checked.")
+						break modLoop
 					}
+				} else if !proceed {
+					proceed = askYesNo(reader, m.prompt+" (y/N) ")
 				}
 
 				if !proceed {
-					fmt.Println("    Skipped.")
+					fmt.Fprintln(progress, "    Skipped.")
+					report.Add(slug, modState.VersionID, modState.VersionID, outcomeSkipped, nil)
 					continue
 				}
+				u.queue(m)
+			}
 
-				// --- Perform Download --- 
-
-				// Remove old file ONLY if it exists AND the new filename is different
-				if fileExists && expectedFilePath != "" && modState.Filename != ver.Files[0].Filename {
-					if verbose {
-						fmt.Printf("    Removing old file: %s\n", expectedFilePath)
-					}
-					if err := os.Remove(expectedFilePath); err != nil {
-						fmt.Printf("    ✗ Failed to remove old file: %v\n", err)
+			if runCtx.Err() != nil {
+				planned = nil // interrupted during the checks
+			}
+			if confirm {
+				u.out = progress
+				plan.WriteText(os.Stdout)
+				if len(planned) > 0 && !askYesNo(reader, "\nApply this plan? [y/N]: ") {
+					fmt.Println("Aborted; nothing downloaded.")
+					for _, m := range planned {
+						report.Add(m.Slug, m.From, m.From, outcomeSkipped, nil)
 					}
+					planned = nil
 				}
+			}
+			for _, m := range planned {
+				u.queue(m)
+			}
+
+			u.download()
+			u.finish()
 
-				if verbose {
-					fmt.Printf("    Ensuring directory %s exists\n", destDir)
+			if resolveOnly {
+				for i, e := range plan.Mods {
+					plan.Mods[i].RequiredBy = sortedUnique(u.deps.requiredBy[e.Slug])
 				}
-				if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
-					fmt.Printf("    ✗ Failed to create directory: %v\n", err)
-					continue
+				if planOut != "" {
+					if err := plan.Save(planOut); err != nil {
+						return fmt.Errorf("failed to write plan: %w", err)
+					}
+					fmt.Fprintf(notice, "Wrote plan to %s; apply it with: update %s --from-plan %s\n", planOut, packName, planOut)
 				}
-
-				// Assuming the first file is the correct one
-				if len(ver.Files) == 0 {
-					fmt.Printf("    ✗ No files found for version %s\n", ver.ID)
-					continue
+				if planJSON {
+					return plan.WriteJSON(os.Stdout)
 				}
-				downloadURL := ver.Files[0].URL
-				expectedFilename := ver.Files[0].Filename
+				plan.WriteText(os.Stdout)
+				return nil
+			}
 
-				fmt.Printf("    Downloading %s...\n", expectedFilename)
-				outPath, err := DownloadFile(downloadURL, destDir)
+			if err := u.finishStaging(); err != nil {
+				return err
+			}
+
+			if u.changed {
+				if err := saveState(state); err != nil {
+					return err
+				}
+			}
+			queue.record(packName, u.mods, report, u.stageErr == nil)
+			if err := queue.save(); err != nil {
+				fmt.Printf("Warning: could not save the retry queue: %v\n", err)
+			}
+			if configChanged {
+				cfg.Modpacks[packName] = packCfg // Update the map entry
+				if err := saveConfig(cfg); err != nil {
+					return err
+				}
+			}
+			if runCtx.Err() != nil {
+				fmt.Printf("\nInterrupted after %d download(s); %s.\n", len(u.downloaded), ternary(u.changed, "saved them to state", "nothing saved"))
+				return errInterrupted
+			}
+			if prune && u.stageErr == nil {
+				// Same cleanup as sync, against the state this run just produced
+				stale, err := unexpectedJars(u.liveDir, packState, nil)
 				if err != nil {
-					fmt.Printf("    ✗ Download failed: %v\n", err)
-					continue
+					return err
+				}
+				if len(stale) > 0 && !dryRun && !autoYes {
+					if !askYesNo(reader, fmt.Sprintf("\nRemove %d jar(s) from %s not in the updated state (%s)? [y/N]: ", len(stale), u.liveDir, strings.Join(stale, ", "))) {
+						stale = nil
+						fmt.Println("  Skipped pruning.")
+					}
+				}
+				if n := removeUnexpected(u.liveDir, packName, stale); n > 0 && dryRun {
+					fmt.Printf("Would prune %d stale file(s).\n", n)
+				} else if n > 0 {
+					fmt.Printf("Pruned %d stale file(s).\n", n)
+				}
+			}
+			fmt.Println("\nUpdate check complete.")
+			fmt.Printf("Summary: %s\n", report.Summary())
+			targetMC := []string{gameVersion}
+			if inRange != nil {
+				targetMC = inRange
+			}
+			warnWrongLoader(os.Stdout, packName, u.incompatible, len(u.mods), targetMC, loader)
+			if summaryOnly {
+				for _, m := range report.Mods {
+					if m.Outcome == outcomeFailed {
+						fmt.Printf("  ✗ %s: %s\n", m.Slug, m.Error)
+					}
+				}
+			}
+			if changelogSum && u.stageErr == nil {
+				writeChangelogSummary(os.Stdout, u.updates, targetMC, loader)
+			}
+			if reportPath != "" && dryRun {
+				fmt.Printf("[dry-run] would write report to %s\n", reportPath)
+			} else if reportPath != "" {
+				if err := report.Write(reportPath); err != nil {
+					return fmt.Errorf("failed to write report: %w", err)
+				}
+				fmt.Printf("Wrote report to %s\n", reportPath)
+			}
+			if lockPath != "" && u.stageErr == nil {
+				failed := slices.IndexFunc(report.Mods, func(m ModReport) bool { return m.Outcome == outcomeFailed }) >= 0
+				switch {
+				case dryRun:
+					fmt.Printf("[dry-run] would write lock to %s\n", lockPath)
+				case failed:
+					// A lock of a half-updated pack isn't the resolution CI should commit
+					return fmt.Errorf("not writing lock to %s: some mods failed to update (%s)", lockPath, report.Summary())
+				default:
+					lock, err := buildLock(cfg, state, packName, gameVersion, loader)
+					if err != nil {
+						return fmt.Errorf("failed to build lock: %w", err)
+					}
+					if err := lock.Write(lockPath); err != nil {
+						return fmt.Errorf("failed to write lock: %w", err)
+					}
+					fmt.Printf("Wrote lock of %d mod(s) to %s\n", len(lock.Mods), lockPath)
 				}
-				fmt.Printf("    ✓ Downloaded: %s\n", filepath.Base(outPath))
+			}
+			return u.stageErr
+		},
+	}
 
-				// Update state with new version ID and filename
-				packState[slug] = ModState{VersionID: ver.ID, Filename: filepath.Base(outPath)}
-				needsSave = true
+	update.Flags().StringVar(&updateEnv, "env", "", "only install mods Modrinth marks as usable on a client or server (client, server or both)")
+	update.Flags().BoolVar(&prune, "prune", false, "after updating, remove jars not in the new state (as sync does)")
+	update.Flags().BoolVar(&planConfirm, "plan-confirm", false, "resolve every mod first, show the whole plan and ask once before downloading")
+	update.Flags().BoolVar(&changelogSum, "changelog-summary", false, "after updating, print the changelogs of every version each updated mod moved past, as one report")
+	update.Flags().BoolVar(&summaryOnly, "summary-only", false, "print only prompts and the final summary, not a status line for every mod")
+	update.Flags().BoolVar(&fastCheck, "fast", false, "treat existing files as present without checking their hash")
+	update.Flags().BoolVar(&forceOverride, "force-override", false, "allow --mc-version/--loader overrides that differ from the pack config")
+	update.Flags().BoolVar(&noDeps, "no-deps", false, "don't install the required dependencies of the pack's mods that the pack doesn't list")
+	update.Flags().BoolVar(&askChannels, "interactive-channels", false, "first choose, per mod, which release channel to accept (saved as the pack's channels) and then update")
+	update.Flags().BoolVarP(&interactive, "interactive", "i", false, "per mod, choose to update, skip, pin the current version, freeze, view the changelog or quit")
+	update.Flags().BoolVar(&useStaging, "staging", false, "download into a staging copy of the pack directory and swap it in only if every download succeeds")
+	update.Flags().BoolVar(&resolveOnly, "resolve-only", false, "resolve versions and print the plan without downloading or writing anything")
+	update.Flags().BoolVar(&planJSON, "json", false, "with --resolve-only, print the plan as JSON")
+	update.Flags().StringVar(&planOut, "plan-out", "", "resolve versions like --resolve-only and save the plan (version IDs, file URLs, sizes, SHA-512s) to this file for --from-plan")
+	update.Flags().StringVar(&fromPlan, "from-plan", "", "apply a plan saved with --plan-out, downloading exactly its files without resolving again")
+	update.Flags().StringVar(&reportPath, "report", "", "write a summary of the run to this file (.md for Markdown, otherwise JSON)")
+	update.Flags().StringVar(&lockPath, "write-lock", "", "after a successful update, atomically write every installed mod's version ID, file URL and SHA-512 to this lockfile")
 
-			} // End loop through mods
+	// update-all
+	updateAll := &cobra.Command{
+		Use:   "update-all",
+		Short: "Run update for every pack that isn't archived, remembering which ones failed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			failed := loadFailedPacks()
+			names := listedPackNames(cfg, false)
+			if retryFailed {
+				names = slices.DeleteFunc(names, func(name string) bool { _, ok := failed[name]; return !ok })
+				if len(names) == 0 {
+					fmt.Println("No packs failed in the last update-all run.")
+					return nil
+				}
+				fmt.Printf("Retrying %d pack(s) that failed last run: %s\n", len(names), strings.Join(names, ", "))
+			}
+			// Packs that are gone from the config can't be retried
+			for name := range failed {
+				if _, ok := cfg.Modpacks[name]; !ok {
+					delete(failed, name)
+				}
+			}
 
-			if needsSave {
-				if err := SaveState(stateFile, state); err != nil {
-					return err
+			sharedReader = bufio.NewReader(os.Stdin)
+			defer func() { sharedReader = nil }()
+			var failedNow []string
+			for _, name := range names {
+				fmt.Printf("\n=== %s ===\n", name)
+				err := update.RunE(update, []string{name})
+				if err == nil {
+					// A pack whose mods failed finishes without an error but leaves them queued for retry
+					if queued := loadRetryQueue()[name]; len(queued) > 0 {
+						err = fmt.Errorf("%d mod(s) failed: %s", len(queued), strings.Join(sortedKeys(queued), ", "))
+					}
+				}
+				if err != nil {
+					fmt.Printf("✗ %s: %v\n", name, err)
+					failed[name] = failedPack{Error: err.Error(), FailedAt: time.Now()}
+					failedNow = append(failedNow, name)
+				} else {
+					delete(failed, name)
 				}
 			}
-			fmt.Println("\nUpdate check complete.")
+			if err := failed.save(); err != nil {
+				fmt.Printf("Warning: could not save the failed pack list: %v\n", err)
+			}
+
+			fmt.Printf("\nUpdated %d of %d pack(s).\n", len(names)-len(failedNow), len(names))
+			if len(failedNow) > 0 {
+				return fmt.Errorf("%d pack(s) failed (%s); run 'modpilot update-all --retry-failed-only' to retry just those", len(failedNow), strings.Join(failedNow, ", "))
+			}
 			return nil
 		},
 	}
+	updateAll.Flags().BoolVar(&retryFailed, "retry-failed-only", false, "only update the packs that failed in the previous update-all run")
 
 	// check-updates
 	checkUpdatesCmd := &cobra.Command{
 		Use:   "check-updates [modpack]", // Renamed from "status"
 		Short: "Check Modrinth for newer versions of mods in a modpack", // Updated description
-		Args:  cobra.ExactArgs(1),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			packName := args[0]
-			cfg, err := LoadConfig(cfgFile)
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packName, err := resolvePackName(cfg, args)
 			if err != nil {
 				return err
 			}
@@ -503,6 +1671,12 @@ func main() {
 			if !ok {
 				return fmt.Errorf("modpack %q not found", packName)
 			}
+			if _, ok := channelRank[compareChannel]; compareChannel != "" && (!ok || compareChannel == "release") {
+				return fmt.Errorf("invalid --compare-channel %q (want beta or alpha)", compareChannel)
+			}
+			if err := checkNotify(notifyMode, cfg); err != nil {
+				return err
+			}
 			state, err := LoadState(stateFile)
 			if err != nil {
 				return err
@@ -522,13 +1696,27 @@ func main() {
 				loader = loaderFlag
 			}
 
-			fmt.Printf("Checking for updates in %s (MC: %s, Loader: %s):\n", packName, gameVersion, loader)
+			inRange, err := rangeVersions()
+			if err != nil {
+				return err
+			}
+			if inRange != nil {
+				fmt.Printf("Checking for updates in %s (MC: %s, Loader: %s):\n", packName, mcRange, loader)
+			} else {
+				fmt.Printf("Checking for updates in %s (MC: %s, Loader: %s):\n", packName, gameVersion, loader)
+			}
+			if warning, err := LegacyMCWarning(gameVersion); err != nil && verbose {
+				fmt.Printf("  (could not check Minecraft version age: %v)\n", err)
+			} else if warning != "" {
+				fmt.Printf("  ⚠ %s\n", warning)
+			}
 			updatesFound := 0
 			missingFiles := 0
+			var pending []string // "slug (what changed)" per update, for --notify
 			packState := state[packName]
 			destDir := filepath.Join(modsDir, packName)
 
-			for _, slug := range packCfg.Mods {
+			for _, slug := range cfg.EffectiveMods(packCfg) {
 				modState, modInState := packState[slug]
 				fileExists := false
 				if modInState && modState.Filename != "" {
@@ -537,31 +1725,112 @@ func main() {
 						fileExists = true
 					} else if !os.IsNotExist(err) {
 						fmt.Printf("  ! %s: error checking file %s: %v\n", slug, filePath, err)
+					} else if found, _ := findByHash(destDir, modState.SHA512); found != "" {
+						fmt.Printf("  ! %s: found as %s instead of %s (run 'update' to rename it)\n", slug, found, modState.Filename)
 					}
 				}
-
-				ver, err := FetchLatestVersion(slug, gameVersion, loader)
-				if err != nil {
-					fmt.Printf("  ✗ %s: error fetching version: %v\n", slug, err)
+				if slices.Contains(packCfg.Frozen, slug) {
+					if verbose {
+						fmt.Printf("  ❄ %s: frozen\n", slug)
+					}
 					continue
 				}
+				if modState.SkipEnv != "" {
+					if verbose {
+						fmt.Printf("  ⊘ %s: not installed (unsupported on %s)\n", slug, modState.SkipEnv)
+					}
+					continue
+				}
+
+				var versions []Version
+				if inRange != nil {
+					versions, err = FetchLoaderVersions(slug, loader)
+				} else {
+					versions, err = FetchVersions(slug, gameVersion, loader)
+				}
+				if err != nil {
+					fmt.Printf("  ✗ %s: error fetching version: %v\n", slug, err)
+					printAvailabilityHint(slug, gameVersion, loader, "    ")
+					continue
+				}
+				latest := func(channel string) (*Version, string, error) {
+					if inRange != nil {
+						return LatestInRange(versions, slug, inRange, loader, channel)
+					}
+					v, err := LatestCompatible(versions, slug, gameVersion, loader, channel)
+					return v, "", err
+				}
+				ver, matchedMC, err := latest(packCfg.ChannelFor(slug))
+				if compareChannel != "" {
+					// Informational only: never counted as an update
+					if cmpVer, _, cmpErr := latest(compareChannel); cmpErr == nil && (ver == nil || cmpVer.ID != ver.ID) && cmpVer.VersionType != "release" {
+						fmt.Printf("  β %s: newer %s build available: %s [informational, not installed]\n", slug, cmpVer.VersionType, showVer(cmpVer))
+					}
+				}
+				if err != nil {
+					fmt.Printf("  ✗ %s: error fetching version: %v\n", slug, err)
+					printAvailabilityHint(slug, gameVersion, loader, "    ")
+					continue
+				}
+
+				// A pinned mod is measured against its pin rather than the latest version
+				targetID := ver.ID
+				if pinID, pinned := packCfg.Pins[slug]; pinned {
+					if pinID != ver.ID {
+						fmt.Printf("  📌 %s: pinned at %s (latest is %s)\n", slug, showVersionID(pinID), showVer(ver))
+					}
+					targetID = pinID
+				}
+				target := showVer(ver) // targetID for display
+				if targetID != ver.ID {
+					target = showVersionID(targetID)
+				}
+				forMC := "" // which in-range MC version the target was built for
+				if matchedMC != "" && targetID == ver.ID {
+					forMC = " (MC " + matchedMC + ")"
+				}
+
+				// --max-versions-behind: how far an outdated mod trails, and whether that's tolerated
+				behind, tolerated := "", false
+				if _, pinned := packCfg.Pins[slug]; maxBehind >= 0 && !pinned && modInState {
+					if n, unit, ok := versionsBehind(versions, modState.VersionID, ver, packCfg.ChannelFor(slug)); ok {
+						behind = " (" + describeBehind(n, unit) + ")"
+						tolerated = n <= maxBehind && fileExists
+					}
+				}
 
 				if !modInState {
-					fmt.Printf("  + %s: new mod, latest version is %s\n", slug, ver.ID)
+					fmt.Printf("  + %s: new mod, latest version is %s%s%s\n", slug, target, forMC, packCfg.channelNote(slug))
 					updatesFound++ // Count as needing update
-				} else if ver.ID != modState.VersionID {
-					fmt.Printf("  ⚠ %s: outdated: %s → %s%s\n", slug, modState.VersionID, ver.ID, ternary(fileExists, "", " (file missing!)"))
+					pending = append(pending, slug+" (new)")
+				} else if strings.TrimSpace(modState.VersionID) == "" && fileExists {
+					fmt.Printf("  ? %s: installed version unknown (state has no version_id); run 'modpilot doctor --fix' to identify %s\n", slug, modState.Filename)
+				} else if _, pinned := packCfg.Pins[slug]; !pinned && fileExists && installedAhead(versions, modState.VersionID, ver) {
+					// Installed by hand from outside the MC/loader filter (e.g. a preview); updating would downgrade it
+					fmt.Printf("  ⇡ %s: ahead (local newer): %s is newer than the latest compatible %s\n", slug, showInstalled(modState), showVer(ver))
+				} else if targetID != modState.VersionID && tolerated {
+					if verbose {
+						fmt.Printf("  ✓ %s: %s%s, within --max-versions-behind %d\n", slug, showInstalled(modState), behind, maxBehind)
+					}
+				} else if targetID != modState.VersionID {
+					fmt.Printf("  ⚠ %s: outdated: %s → %s%s%s%s%s\n", slug, showInstalled(modState), target, forMC, packCfg.channelNote(slug), behind, ternary(fileExists, "", " (file missing!)"))
 					updatesFound++
+					pending = append(pending, fmt.Sprintf("%s (%s → %s)", slug, showInstalled(modState), target))
 					if !fileExists {
 						missingFiles++
 					}
 				} else if !fileExists {
-					fmt.Printf("  ! %s: file missing for current version %s\n", slug, ver.ID)
+					fmt.Printf("  ! %s: file missing for current version %s\n", slug, target)
 					missingFiles++
 					updatesFound++ // Count as needing update because file is missing
+					pending = append(pending, slug+" (file missing)")
+				} else if file, err := ver.PrimaryFile(); err == nil && targetID == ver.ID && reuploaded(modState, slug, file) {
+					fmt.Printf("  ↻ %s: %s is now published as %s (installed as %s); update will redownload it\n", slug, target, file.Filename, modState.Filename)
+					updatesFound++
+					pending = append(pending, slug+" (file renamed upstream)")
 				} else {
 					if verbose {
-						fmt.Printf("  ✓ %s: up to date (%s)\n", slug, ver.ID)
+						fmt.Printf("  ✓ %s: up to date at %s%s%s\n", slug, target, forMC, packCfg.channelNote(slug))
 					}
 				}
 			}
@@ -570,19 +1839,43 @@ func main() {
 			} else {
 				fmt.Printf("\nFound %d potential update(s) and %d missing file(s). Run 'modpilot update %s' to fix.\n", updatesFound, missingFiles, packName)
 			}
+			if notifyMode != "" && len(pending) > 0 {
+				if dryRun {
+					fmt.Printf("[dry-run] would send a %s notification for %d update(s)\n", notifyMode, len(pending))
+				} else if sent, err := notifyUpdates(cfg, notifyMode, packName, pending); err != nil {
+					return fmt.Errorf("failed to send notification: %w", err)
+				} else if sent {
+					fmt.Println("Sent update notification.")
+				}
+			}
 			return nil
 		},
 	}
 
+	checkUpdatesCmd.Flags().StringVar(&compareChannel, "compare-channel", "", "also report newer builds on a less stable channel (beta|alpha) without installing them")
+	checkUpdatesCmd.Flags().StringVar(&notifyMode, "notify", "", "when updates are found, notify via the config's notify_webhook and/or the desktop: auto (the default with a bare --notify), webhook, desktop or all")
+	checkUpdatesCmd.Flags().Lookup("notify").NoOptDefVal = notifyAuto
+
 	// sync
 	syncCmd := &cobra.Command{
 		Use:     "sync [modpack]",
 		Aliases: []string{"sync-pack", "clean"},
 		Short:   "Remove mod jars not listed in the state file for the modpack", // Updated description
-		Args:    cobra.ExactArgs(1),
+		Args:    cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			packName := args[0]
-			// No need to load config for sync, only state
+			// Only need the config to find the active pack when no name is given
+			var packName string
+			if len(args) == 1 {
+				packName = args[0]
+			} else {
+				cfg, err := loadConfig()
+				if err != nil {
+					return err
+				}
+				if packName, err = resolvePackName(cfg, args); err != nil {
+					return err
+				}
+			}
 			state, err := LoadState(stateFile)
 			if err != nil {
 				return fmt.Errorf("failed to load state for sync: %w", err)
@@ -595,68 +1888,1151 @@ func main() {
 			}
 
 			dir := filepath.Join(modsDir, packName)
-			files, err := ioutil.ReadDir(dir)
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				fmt.Printf("Mods directory for %s (%s) does not exist, nothing to sync.\n", packName, dir)
+				return nil // Not an error if dir doesn't exist
+			}
+			stale, err := unexpectedJars(dir, packState, syncExclude)
+			if err != nil {
+				return err
+			}
+			if syncDedupe {
+				dups := duplicateJars(dir, packState, stale)
+				for _, d := range dups {
+					kept := packState[d.Slug].Filename
+					if dryRun {
+						fmt.Printf("[dry-run] would remove %s (%s %s), keeping %s\n", d.Name, d.Slug, d.Version, kept)
+						continue
+					}
+					if err := os.Remove(filepath.Join(dir, d.Name)); err != nil {
+						fmt.Printf("  ✗ Failed to remove %s: %v\n", d.Name, err)
+						continue
+					}
+					fmt.Printf("Removed %s (%s %s), keeping %s\n", d.Name, d.Slug, d.Version, kept)
+				}
+				if left := len(stale) - len(dups); left > 0 {
+					fmt.Printf("%d other untracked jar(s) left alone; run sync without --dedupe to remove them.\n", left)
+				}
+				if len(dups) == 0 {
+					fmt.Println("No duplicate mod files found.")
+				}
+				return nil
+			}
+			removedCount := removeUnexpected(dir, packName, stale)
+			if removedCount > 0 && dryRun {
+				fmt.Printf("Dry run complete. Would remove %d unexpected file(s).\n", removedCount)
+			} else if removedCount > 0 {
+				fmt.Printf("Sync complete. Removed %d unexpected file(s).\n", removedCount)
+			} else {
+				fmt.Println("Sync complete. No unexpected files found.")
+			}
+			return nil
+		},
+	}
+
+	syncCmd.Flags().BoolVar(&syncDedupe, "dedupe", false, "only remove jars that Modrinth identifies by hash as other versions of mods in state, keeping the recorded file")
+	syncCmd.Flags().StringArrayVar(&syncExclude, "exclude", nil, "glob of jar names to keep even if they aren't in state (repeatable), e.g. \"*-dev.jar\"")
+
+	// reconcile-filenames
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile-filenames [modpack]",
+		Short: "Rename a modpack's jars to the filenames Modrinth reports, matching them by hash",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(false, true); err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packName, err := resolvePackName(cfg, args)
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Modpacks[packName]; !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			dir := filepath.Join(modsDir, packName)
+			fixed, failed, err := reconcileFilenames(dir, state[packName])
+			if err != nil {
+				return err
+			}
+			renamed := 0
+			for _, f := range fixed {
+				if f.From == f.To {
+					fmt.Printf("✓ %s: recorded %s\n", f.Slug, f.To)
+					continue
+				}
+				renamed++
+				fmt.Printf("%s %s: %s → %s\n", ternary(dryRun, "[dry-run] would rename", "✓ Renamed"), f.Slug, f.From, f.To)
+			}
+			for _, slug := range sortedKeys(failed) {
+				fmt.Printf("✗ %s: %v\n", slug, failed[slug])
+			}
+			if len(fixed) > 0 {
+				if err := saveState(state); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("\n%s: %d renamed, %d re-recorded, %d failed\n", packName, renamed, len(fixed)-renamed, len(failed))
+			return nil
+		},
+	}
+
+	// reinstall
+	reinstallCmd := &cobra.Command{
+		Use:   "reinstall [modpack]",
+		Short: "Delete a modpack's directory and redownload every mod at the version recorded in state",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(false, true); err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packName, err := resolvePackName(cfg, args)
+			if err != nil {
+				return err
+			}
+			packCfg, ok := cfg.Modpacks[packName]
+			if !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			if state[packName] == nil {
+				state[packName] = make(map[string]ModState)
+			}
+			packState := state[packName]
+			dir := filepath.Join(modsDir, packName)
+			mods := cfg.EffectiveMods(packCfg)
+			mods = append(mods, autoDeps(packState, mods)...)
+
+			if dryRun {
+				fmt.Printf("[dry-run] would delete %s and redownload %d mod(s)\n", dir, len(mods))
+				return nil
+			}
+			if !resume {
+				if !autoYes {
+					if !askYesNo(bufio.NewReader(os.Stdin), fmt.Sprintf("Delete %s and redownload all %d mod(s) of %s? [y/N]: ", dir, len(mods), packName)) {
+						fmt.Println("Aborted.")
+						return nil
+					}
+				}
+				if err := os.RemoveAll(dir); err != nil {
+					return fmt.Errorf("failed to delete %s: %w", dir, err)
+				}
+				// Keep the version IDs but forget the files, so an interrupted run can be resumed
+				for slug, ms := range packState {
+					ms.Filename, ms.SHA512 = "", ""
+					packState[slug] = ms
+				}
+				if err := saveState(state); err != nil {
+					return err
+				}
+			}
+
+			var reinstalled, kept []string
+			failed := make(map[string]error)
+			for _, slug := range mods {
+				ms := packState[slug]
+				if ms.SkipEnv != "" {
+					continue
+				}
+				if ms.Filename != "" {
+					// Only reached with --resume: done on an earlier run if the file still checks out
+					if sum, err := fileSHA512(filepath.Join(dir, ms.Filename)); err == nil && (ms.SHA512 == "" || sum == ms.SHA512) {
+						kept = append(kept, slug)
+						continue
+					}
+				}
+
+				var ver *Version
+				switch pinID, pinned := packCfg.Pins[slug]; {
+				case pinned:
+					ver, err = FetchVersion(pinID)
+				case ms.VersionID != "":
+					ver, err = FetchVersion(ms.VersionID)
+				default:
+					ver, err = FetchLatestVersionForChannel(slug, packCfg.MCVersion, packCfg.Loader, packCfg.ChannelFor(slug))
+				}
+				if err == nil {
+					fmt.Printf("Downloading %s %s...\n", slug, showVer(ver))
+					requiredBy := ms.RequiredBy
+					ms, err = installVersion(ver, dir, slug, ms.Filename)
+					ms.RequiredBy = requiredBy
+				}
+				if err != nil {
+					fmt.Printf("  ✗ %s: %v\n", slug, err)
+					failed[slug] = err
+					continue
+				}
+				packState[slug] = ms
+				reinstalled = append(reinstalled, slug)
+				// Save as we go so --resume picks up where a failed or interrupted run stopped
+				if err := saveState(state); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("\nReinstall of %s: %d downloaded, %d already present, %d failed.\n", packName, len(reinstalled), len(kept), len(failed))
+			if len(failed) > 0 {
+				fmt.Printf("Failed: %s\n", strings.Join(sortedKeys(failed), ", "))
+				return fmt.Errorf("%d mod(s) failed to reinstall; run 'modpilot reinstall %s --resume' to retry them", len(failed), packName)
+			}
+			return nil
+		},
+	}
+	reinstallCmd.Flags().BoolVar(&resume, "resume", false, "keep the directory and only fetch mods not yet reinstalled")
+
+	// stats
+	statsCmd := &cobra.Command{
+		Use:   "stats [modpack]",
+		Short: "Summarise mod counts, outdated counts and disk usage for every pack (or just one)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			names := listedPackNames(cfg, false)
+			if len(args) == 1 {
+				if _, ok := cfg.Modpacks[args[0]]; !ok {
+					return fmt.Errorf("modpack %q not found", args[0])
+				}
+				names = args
+			}
+			state, err := LoadState(stateFile)
 			if err != nil {
-				if os.IsNotExist(err) {
-					fmt.Printf("Mods directory for %s (%s) does not exist, nothing to sync.\n", packName, dir)
-					return nil // Not an error if dir doesn't exist
+				return err
+			}
+			fleet := gatherStats(cfg, state, names, statsOffline)
+			if statsJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(fleet)
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tMC\tLOADER\tJAVA\tMODS\tIN STATE\tOUTDATED\tDISK")
+			for _, ps := range fleet.Packs {
+				outdated := "-"
+				if ps.Outdated != nil {
+					outdated = fmt.Sprint(*ps.Outdated)
+					if *ps.Unchecked > 0 {
+						outdated += fmt.Sprintf(" (%d unchecked)", *ps.Unchecked)
+					}
 				}
-				return fmt.Errorf("failed to read mods directory %s: %w", dir, err)
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", ps.Name, ps.MCVersion, ps.Loader, javaLabel(ps.Java), ps.Mods, ps.Tracked, outdated, humanSize(ps.DiskBytes))
 			}
+			return tw.Flush()
+		},
+	}
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "print the summary as JSON")
+	statsCmd.Flags().BoolVar(&statsOffline, "offline", false, "skip the Modrinth checks and report local fields only")
 
-			// Build a map of expected filenames from the state
-			expectedFiles := make(map[string]bool)
-			for _, modState := range packState {
-				if modState.Filename != "" {
-					expectedFiles[modState.Filename] = true
+	// graph
+	graphCmd := &cobra.Command{
+		Use:   "graph [modpack]",
+		Short: "Print a modpack's required-dependency graph as Graphviz DOT or Mermaid",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packName, err := resolvePackName(cfg, args)
+			if err != nil {
+				return err
+			}
+			packCfg, ok := cfg.Modpacks[packName]
+			if !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			if graphFormat != "dot" && graphFormat != "mermaid" {
+				return fmt.Errorf("unknown --format %q (want dot or mermaid)", graphFormat)
+			}
+			g := buildDepGraph(cfg, packCfg)
+			if graphFormat == "mermaid" {
+				g.WriteMermaid(os.Stdout)
+			} else {
+				g.WriteDOT(os.Stdout, packName)
+			}
+			// Keep stdout renderable; explain unresolved nodes separately
+			for _, slug := range g.Nodes {
+				if reason, missing := g.Missing[slug]; missing {
+					fmt.Fprintf(os.Stderr, "warning: %s: %s\n", slug, reason)
 				}
 			}
+			return nil
+		},
+	}
+	graphCmd.Flags().StringVar(&graphFormat, "format", "dot", "output format: dot or mermaid")
 
-			// Iterate through files in the directory
-			removedCount := 0
-			for _, f := range files {
-				if f.IsDir() || !strings.HasSuffix(strings.ToLower(f.Name()), ".jar") {
-					continue // Skip directories and non-jar files
+	// export-mrpack
+	exportMrpackCmd := &cobra.Command{
+		Use:   "export-mrpack [modpack]",
+		Short: "Write a modpack as a launcher-ready .mrpack, bundling local jars and --overrides",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packName, err := resolvePackName(cfg, args)
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Modpacks[packName]; !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			if loaderVersion == "" {
+				return fmt.Errorf("pass --loader-version; launchers need to know which %s build to install", cfg.Modpacks[packName].Loader)
+			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			dest := mrpackPath
+			if dest == "" {
+				dest = packName + ".mrpack"
+			}
+			res, err := exportMrpack(cfg, state, packName, dest, overridesDir, loaderVersion, packVersion)
+			if err != nil {
+				return err
+			}
+			for _, name := range res.LocalJars {
+				fmt.Printf("  bundling %s (no Modrinth source)\n", name)
+			}
+			verb := "Wrote"
+			if dryRun {
+				verb = "Would write"
+			}
+			fmt.Printf("%s %s: %d Modrinth download(s), %d bundled jar(s), %d override file(s).\n", verb, dest, len(res.Downloads), len(res.LocalJars), res.Overrides)
+			return nil
+		},
+	}
+	exportMrpackCmd.Flags().StringVar(&mrpackPath, "file", "", "where to write the pack (default <modpack>.mrpack)")
+	exportMrpackCmd.Flags().StringVar(&overridesDir, "overrides", "", "directory whose contents (config files, resource packs, ...) go under overrides/ in the pack")
+	exportMrpackCmd.Flags().StringVar(&loaderVersion, "loader-version", "", "version of the pack's loader to record, e.g. 0.15.11 for Fabric (required)")
+	exportMrpackCmd.Flags().StringVar(&packVersion, "pack-version", "1.0.0", "version string for the pack itself")
+
+	// serve
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve packs, mods, update checks and updates as a local HTTP+JSON API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(false, true); err != nil {
+				return err
+			}
+			switch updateEnv {
+			case "", "client", "server", "both":
+			default:
+				return fmt.Errorf("unknown --env %q (want client, server or both)", updateEnv)
+			}
+			addr, err := listenAddr(serveAddr, allowRemote)
+			if err != nil {
+				return err
+			}
+			// Download settings come from the config as it is now; requests reread the rest
+			if _, err := loadConfig(); err != nil {
+				return err
+			}
+			if serveToken == "" {
+				serveToken = os.Getenv("MODPILOT_SERVE_TOKEN")
+			}
+			if serveToken == "" {
+				if serveToken, err = newServeToken(); err != nil {
+					return err
 				}
+				fmt.Printf("Generated token for mutating requests: %s\n", serveToken)
+			}
+			fmt.Printf("Serving on http://%s/api/packs\n", addr)
+			srv := &http.Server{Addr: addr, Handler: (&apiServer{token: serveToken}).routes()}
+			go func() {
+				<-cmd.Context().Done()
+				srv.Shutdown(context.Background())
+			}()
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "address to listen on (a bare :port listens on localhost)")
+	serveCmd.Flags().StringVar(&serveToken, "auth-token", "", "bearer token required by mutating endpoints (default $MODPILOT_SERVE_TOKEN, else a random one)")
+	serveCmd.Flags().BoolVar(&allowRemote, "allow-remote", false, "allow --addr to listen on a non-loopback interface")
+	serveCmd.Flags().StringVar(&updateEnv, "env", "", "checks and updates only install mods Modrinth marks as usable on a client or server, as update --env does")
+	serveCmd.Flags().BoolVar(&useStaging, "staging", false, "updates download into a staging copy of the pack directory, as update --staging does")
+
+	// version
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the modpilot version, commit, build date and Go version",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := currentBuild()
+			if versionJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			}
+			fmt.Println(info)
+			return nil
+		},
+	}
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print the build metadata as JSON")
+
+	// doctor
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the config, state and mods folders for problems, optionally fixing the safe ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWritable(doctorFix, doctorFix); err != nil {
+				return err
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			issues := diagnose(cfg, state)
+			issues = append(issues, conflictIssues(cfg, state)...)
+			if doctorDeep {
+				issues = append(issues, deepIssues(cfg, state)...)
+			}
+			if checkAbandoned {
+				issues = append(issues, abandonedIssues(cfg)...)
+			}
+			if len(issues) == 0 {
+				fmt.Println("No problems found.")
+				return nil
+			}
 
-				if !expectedFiles[f.Name()] {
-					filePath := filepath.Join(dir, f.Name())
-					fmt.Printf("Removing %s (not found in state for %s)...\n", filePath, packName)
-					if err := os.Remove(filePath); err != nil {
-						fmt.Printf("  ✗ Failed to remove: %v\n", err)
+			configChanged, stateChanged := false, false
+			fixed, unfixed := 0, 0
+			for _, is := range issues {
+				where := is.Pack
+				if is.Slug != "" {
+					where += "/" + is.Slug
+				}
+				fmt.Printf("✗ %s: %s\n", where, is.Problem)
+				switch {
+				case is.fix == nil:
+					fmt.Printf("    needs manual attention: %s\n", is.Remedy)
+					unfixed++
+				case !doctorFix:
+					fmt.Printf("    fixable with --fix: %s\n", is.Remedy)
+					unfixed++
+				case is.destructive && !autoYes:
+					fmt.Printf("    not fixed (needs --yes): %s\n", is.Remedy)
+					unfixed++
+				case dryRun:
+					fmt.Printf("    [dry-run] would %s\n", is.Remedy)
+					unfixed++
+				default:
+					if err := is.fix(); err != nil {
+						fmt.Printf("    ✗ could not %s: %v\n", is.Remedy, err)
+						unfixed++
+						continue
+					}
+					fmt.Printf("    ✓ fixed: %s\n", is.Remedy)
+					fixed++
+					if is.editsConfig {
+						configChanged = true
 					} else {
-						removedCount++
+						stateChanged = true
 					}
 				}
 			}
-			if removedCount > 0 {
-				fmt.Printf("Sync complete. Removed %d unexpected file(s).\n", removedCount)
+
+			if stateChanged {
+				if err := saveState(state); err != nil {
+					return err
+				}
+			}
+			if configChanged {
+				if err := saveConfig(cfg); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("\n%d problem(s) found, %d fixed, %d remaining.\n", len(issues), fixed, unfixed)
+			return nil
+		},
+	}
+	doctorCmd.Flags().BoolVar(&checkAbandoned, "abandoned", false, "also look for mods with no recent builds and suggest similarly named projects that may have replaced them")
+	doctorCmd.Flags().BoolVar(&doctorDeep, "deep", false, "also open every jar on disk as a zip and flag those that are corrupt or truncated (--fix redownloads them)")
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "fix what can be fixed automatically (state removals also need --yes)")
+
+	// search
+	searchCmd := &cobra.Command{
+		Use:   "search [query...]",
+		Short: "Search Modrinth for mods, optionally adding and downloading one in the same step",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if searchPick != 0 && !searchInstall {
+				return fmt.Errorf("--pick only applies with --install")
+			}
+			if err := checkWritable(searchInstall, searchInstall); err != nil {
+				return err
+			}
+			hits, err := SearchProjects(strings.Join(args, " "), searchLimit)
+			if err != nil {
+				return err
+			}
+			if len(hits) == 0 {
+				fmt.Println("No mods found.")
+				return nil
+			}
+			for i, h := range hits {
+				fmt.Printf("%2d. %s (%s), %d downloads\n", i+1, h.Title, h.Slug, h.Downloads)
+				if h.Description != "" {
+					fmt.Printf("    %s\n", h.Description)
+				}
+			}
+			if !searchInstall {
+				return nil
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var packArgs []string
+			if searchPack != "" {
+				packArgs = []string{searchPack}
+			}
+			packName, err := resolvePackName(cfg, packArgs)
+			if err != nil {
+				return err
+			}
+			packCfg, ok := cfg.Modpacks[packName]
+			if !ok {
+				return fmt.Errorf("modpack %q not found", packName)
+			}
+			pick := searchPick
+			if pick == 0 {
+				if autoYes {
+					return fmt.Errorf("--install with --yes needs --pick to say which result to install")
+				}
+				fmt.Printf("Install which result into %s? [1-%d, Enter to cancel]: ", packName, len(hits))
+				line, _ := readLine(bufio.NewReader(os.Stdin))
+				if line == "" {
+					fmt.Println("Nothing installed.")
+					return nil
+				}
+				if pick, err = strconv.Atoi(line); err != nil {
+					pick = -1
+				}
+			}
+			if pick < 1 || pick > len(hits) {
+				return fmt.Errorf("no result %d (want 1-%d)", pick, len(hits))
+			}
+			slug := hits[pick-1].Slug
+
+			// Compatibility first, so an incompatible pick changes nothing
+			ver, err := FetchLatestVersionForChannel(slug, packCfg.MCVersion, packCfg.Loader, packCfg.ChannelFor(slug))
+			if err != nil {
+				return fmt.Errorf("not installing %s: %w", slug, err)
+			}
+			if packCfg.Entry(slug) >= 0 || cfg.InheritedFrom(packCfg, slug) != "" {
+				fmt.Printf("%q is already in %s\n", slug, packName)
 			} else {
-				fmt.Println("Sync complete. No unexpected files found.")
+				packCfg.Mods = append(packCfg.Mods, ModEntry{Slug: slug})
+				cfg.Modpacks[packName] = packCfg
+				if err := saveConfig(cfg); err != nil {
+					return err
+				}
+				fmt.Printf("Added %q to %s\n", slug, packName)
+			}
+
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			if ms, ok := state[packName][slug]; ok && ms.VersionID == ver.ID {
+				fmt.Printf("%s %s is already installed\n", slug, ver.VersionNumber)
+				return nil
+			}
+			if dryRun {
+				fmt.Printf("[dry-run] would download %s %s\n", slug, showVer(ver))
+				return nil
+			}
+			fmt.Printf("Downloading %s %s...\n", slug, showVer(ver))
+			ms, err := installVersion(ver, filepath.Join(modsDir, packName), slug, state[packName][slug].Filename)
+			if err != nil {
+				return fmt.Errorf("%s was added but its download failed (run 'modpilot update %s' to retry): %w", slug, packName, err)
+			}
+			ms.RequiredBy = state[packName][slug].RequiredBy
+			if state[packName] == nil {
+				state[packName] = make(map[string]ModState)
+			}
+			state[packName][slug] = ms
+			if err := saveState(state); err != nil {
+				return err
+			}
+			fmt.Printf("✓ Installed %s\n", ms.Filename)
+			return nil
+		},
+	}
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 10, "how many results to show")
+	searchCmd.Flags().BoolVar(&searchInstall, "install", false, "pick a result, add it to --pack (or the active pack) and download its latest compatible version")
+	searchCmd.Flags().IntVar(&searchPick, "pick", 0, "with --install, the result number to install instead of asking")
+	searchCmd.Flags().StringVar(&searchPack, "pack", "", "with --install, the pack to add the mod to (default: the active pack)")
+
+	// lint
+	lintCmd := &cobra.Command{
+		Use:   "lint [modpack]",
+		Short: "Flag mods that don't belong on the side a pack is deployed to, such as client-only mods on a server, and known conflicts",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			packName, err := resolvePackName(cfg, args)
+			if err != nil {
+				return err
+			}
+			mismatches, failed, err := sideMismatches(cfg, packName, lintSide)
+			if err != nil {
+				return err
+			}
+			state, err := LoadState(stateFile)
+			if err != nil {
+				return err
+			}
+			conflicts, err := loadConflicts(cfg)
+			if err != nil {
+				return err
+			}
+			hits := packConflicts(conflicts, installedMods(cfg, state, packName))
+			other := ternary(lintSide == "server", "client", "server")
+			for _, m := range mismatches {
+				fmt.Printf("✗ %s: %s-only (client: %s, server: %s)\n", m.Slug, other, m.ClientSide, m.ServerSide)
+			}
+			for _, hit := range hits {
+				fmt.Printf("✗ %s: known conflict: %s\n", strings.Join(hit.Mods, " + "), hit.Reason)
+			}
+			for _, slug := range cfg.EffectiveMods(cfg.Modpacks[packName]) {
+				if err, ok := failed[slug]; ok {
+					fmt.Printf("? %s: could not check: %v\n", slug, err)
+				}
+			}
+			if len(mismatches) == 0 && len(hits) == 0 {
+				fmt.Printf("No %s-only mods or known conflicts in %s.\n", other, packName)
+				return nil
+			}
+			fmt.Println()
+			if len(mismatches) > 0 {
+				fmt.Printf("%d mod(s) in %s are not used on a %s.\n", len(mismatches), packName, lintSide)
+			}
+			if len(hits) > 0 {
+				fmt.Printf("%d known conflict(s) in %s; keep one mod of each.\n", len(hits), packName)
+			}
+			return nil
+		},
+	}
+	lintCmd.Flags().StringVar(&lintSide, "side", "server", "environment the pack is for: server flags client-only mods, client flags server-only mods")
+
+	// cache
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and manage the Modrinth API response cache",
+	}
+	cacheStats := &cobra.Command{
+		Use:   "stats",
+		Short: "Show cache entries, size and hit rate since the last purge",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := cacheEntries()
+			if err != nil {
+				return err
+			}
+			var size int64
+			expired := 0
+			for _, f := range entries {
+				if info, err := f.Info(); err == nil {
+					size += info.Size()
+				}
+				if cacheExpired(filepath.Join(cacheDir, f.Name()), cacheTTL) {
+					expired++
+				}
+			}
+			stats := loadCacheStats()
+			lookups := stats.Hits + stats.Misses + stats.Revalidated
+			fmt.Printf("Cache directory: %s\n", cacheDir)
+			fmt.Printf("Entries: %d (%d expired at TTL %s)\n", len(entries), expired, cacheTTL)
+			fmt.Printf("Size: %d bytes\n", size)
+			if lookups > 0 {
+				fmt.Printf("Hit rate: %d/%d (%.0f%%) since %s\n", stats.Hits, lookups, 100*float64(stats.Hits)/float64(lookups), stats.Since.Format(time.RFC3339))
+				fmt.Printf("Revalidated (304 Not Modified): %d\n", stats.Revalidated)
+			} else {
+				fmt.Println("Hit rate: no lookups recorded yet")
+			}
+			return nil
+		},
+	}
+	cacheClean := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove cache entries older than --cache-ttl",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := cleanCache(cacheTTL, dryRun)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s %d expired cache entr%s.\n", ternary(dryRun, "[dry-run] Would remove", "Removed"), removed, ternary(removed == 1, "y", "ies"))
+			return nil
+		},
+	}
+	cachePurge := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove every cache entry and reset the statistics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := cleanCache(0, dryRun)
+			if err != nil {
+				return err
+			}
+			if !dryRun {
+				if err := os.Remove(filepath.Join(cacheDir, cacheStatsFile)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
 			}
+			fmt.Printf("%s %d cache entr%s.\n", ternary(dryRun, "[dry-run] Would remove", "Removed"), removed, ternary(removed == 1, "y", "ies"))
 			return nil
 		},
 	}
+	cacheCmd.AddCommand(cacheStats, cacheClean, cachePurge)
 
 	root.AddCommand(
 		listPacks,
 		listMods,
 		addMod,
+		searchCmd,
+		pinVersion,
 		removeMod,
+		reorderMods,
 		createPack,
+		importDir,
 		deletePack,
+		archivePack,
+		unarchivePack,
+		freezeAll,
+		unfreezeAll,
+		usePack,
+		migrateLoader,
 		initCmd,
 		update,
+		updateAll,
 		checkUpdatesCmd,
 		syncCmd,
+		reconcileCmd,
+		reinstallCmd,
+		statsCmd,
+		doctorCmd,
+		lintCmd,
+		graphCmd,
+		exportMrpackCmd,
+		versionCmd,
+		serveCmd,
+		cacheCmd,
 	)
 
-	if err := root.Execute(); err != nil {
+	// The first Ctrl-C lets the command wind down and save what finished; a second one quits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	runCtx = ctx
+	go func() {
+		<-ctx.Done()
+		stop()
+		fmt.Fprintln(os.Stderr, "\nInterrupted; finishing up (Ctrl-C again to quit at once).")
+	}()
+
+	err := root.ExecuteContext(ctx)
+	// Whether or not the command succeeded, its lookups happened
+	flushCacheStats()
+	printRunStats(os.Stderr)
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
+	}
+	if ctx.Err() != nil {
+		os.Exit(130)
+	} else if err != nil {
 		os.Exit(1)
 	}
 }
 
+// errSkipHashMirror refuses --skip-hash with a download mirror, whose files are only trusted because
+// their hash is checked
+var errSkipHashMirror = errors.New("--skip-hash can't be combined with a download mirror (--mirror or the config's download_mirror), whose files are only used when they match Modrinth's hash")
+
+// loadConfig reads --config for a command and applies its run-wide settings: download_mirror
+// (unless --mirror was given), download_headers and compact_state. LoadConfig itself changes
+// nothing global, so reading include files or reloading in the API server leaves downloads alone.
+func loadConfig() (*Config, error) {
+	cfg, err := LoadConfig(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	if downloadMirror == "" {
+		downloadMirror = cfg.DownloadMirror // --mirror wins
+	}
+	if skipHash && downloadMirror != "" {
+		return nil, errSkipHashMirror
+	}
+	downloadHeaders = cfg.DownloadHeaders
+	if cfg.CompactState {
+		compactState = true
+	}
+	return cfg, nil
+}
+
+// saveConfig writes cfg to --config, or only reports that it would under --dry-run
+func saveConfig(cfg *Config) error {
+	path := cfgFile
+	if outputFile != "" {
+		path = outputFile
+	}
+	if dryRun {
+		fmt.Printf("[dry-run] would save %s\n", path)
+		return nil
+	}
+	if path == stdinPath {
+		return fmt.Errorf("the config was read from stdin and can't be saved; pass --output to write it to a file")
+	}
+	if isRemote(path) {
+		return fmt.Errorf("the config was fetched from %s and is read-only; pass --output to save changes to a local file", path)
+	}
+	return SaveConfig(path, cfg)
+}
+
+// saveState writes state to --state, or only reports that it would under --dry-run
+func saveState(state State) error {
+	if dryRun {
+		fmt.Printf("[dry-run] would save %s\n", stateFile)
+		return nil
+	}
+	if stateFile == stdinPath {
+		return fmt.Errorf("the state was read from stdin and can't be saved; pass a --state file")
+	}
+	return SaveState(stateFile, state)
+}
+
+// checkWritable fails a command up front, before it changes anything, when the config or state
+// it would save was read from stdin
+func checkWritable(config, state bool) error {
+	if dryRun {
+		return nil
+	}
+	if config && cfgFile == stdinPath && outputFile == "" {
+		return fmt.Errorf("this command saves the config, which was read from stdin; pass --output to write it to a file")
+	}
+	if config && isRemote(cfgFile) && outputFile == "" {
+		return fmt.Errorf("this command saves the config, which is read-only when fetched from a URL; pass --output to write a local copy")
+	}
+	if state && stateFile == stdinPath {
+		return fmt.Errorf("this command saves the state, which was read from stdin; pass a --state file")
+	}
+	return nil
+}
+
+// installedAhead reports whether the installed version id is newer than latest. The installed version
+// is looked up in versions first and fetched by ID only if it isn't there; lookup failures count as not ahead.
+func installedAhead(versions []Version, id string, latest *Version) bool {
+	if id == latest.ID {
+		return false
+	}
+	var installed *Version
+	for i := range versions {
+		if versions[i].ID == id {
+			installed = &versions[i]
+			break
+		}
+	}
+	if installed == nil {
+		v, err := FetchVersion(id)
+		if err != nil {
+			return false
+		}
+		installed = v
+	}
+	return versionAhead(installed, latest)
+}
+
+// resolvePackName returns the pack named in args ("." meaning the config's only pack), falling back to the config's active pack
+func resolvePackName(cfg *Config, args []string) (string, error) {
+	if len(args) > 0 && args[0] == "." {
+		// "this folder's pack", for a --pack-dir holding a single pack
+		if len(cfg.Modpacks) == 1 {
+			for name := range cfg.Modpacks {
+				return name, nil
+			}
+		}
+		if cfg.ActivePack == "" {
+			return "", fmt.Errorf("\".\" needs a config with a single pack or an active pack (see 'modpilot use-pack')")
+		}
+		return cfg.ActivePack, nil
+	}
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	if cfg.ActivePack == "" {
+		return "", fmt.Errorf("no modpack given and no active modpack set (see 'modpilot use-pack')")
+	}
+	return cfg.ActivePack, nil
+}
+
+// countOutdated checks each mod against Modrinth using the pack's own settings and returns
+// how many are new or behind their state entry, and how many couldn't be checked (listed on w under --verbose)
+func countOutdated(w io.Writer, mods []string, packCfg ModpackConfig, packState map[string]ModState) (outdated, failed int) {
+	for _, slug := range mods {
+		ver, err := FetchLatestVersionForChannel(slug, packCfg.MCVersion, packCfg.Loader, packCfg.ChannelFor(slug))
+		if err != nil {
+			if verbose {
+				fmt.Fprintf(w, "  ✗ %s: %v\n", slug, err)
+			}
+			failed++
+			continue
+		}
+		if modState, ok := packState[slug]; !ok || modState.VersionID != ver.ID {
+			outdated++
+		}
+	}
+	return outdated, failed
+}
+
+// promptModAction asks what to do with a mod needing action during update --interactive and returns
+// one of "u", "s", "p", "f" or "q". Viewing the changelog re-asks; empty input or EOF means skip.
+func promptModAction(reader *bufio.Reader, promptMessage string, ver *Version, installed bool) string {
+	for {
+		fmt.Print(promptMessage + " [u]pdate/[s]kip/[p]in current/[f]reeze/[c]hangelog/[q]uit (default s): ")
+		line, eof := readLine(reader)
+		choice := strings.ToLower(line)
+		switch {
+		case eof && choice == "":
+			return "s"
+		case choice == "" || choice == "s" || choice == "skip":
+			return "s"
+		case choice == "u" || choice == "update" || choice == "y":
+			return "u"
+		case choice == "p" || choice == "pin":
+			if !installed {
+				fmt.Println("    Nothing installed yet to pin.")
+				continue
+			}
+			return "p"
+		case choice == "f" || choice == "freeze":
+			return "f"
+		case choice == "q" || choice == "quit":
+			return "q"
+		case choice == "c" || choice == "changelog":
+			fmt.Printf("    --- Changelog for %s ---\n", showVer(ver))
+			changelog := strings.TrimSpace(ver.Changelog)
+			if changelog == "" {
+				changelog = "(no changelog provided)"
+			}
+			for _, l := range strings.Split(changelog, "\n") {
+				fmt.Printf("    %s\n", l)
+			}
+		default:
+			fmt.Printf("    Unknown choice %q\n", choice)
+		}
+	}
+}
+
+// promptChannels asks which release channel each of mods should be resolved on and records the
+// answers in packCfg.Channels; "pack default" drops a mod's own channel. Empty input or EOF keeps
+// the current choice. It reports whether anything changed.
+func promptChannels(reader *bufio.Reader, mods []string, packCfg *ModpackConfig) bool {
+	fmt.Printf("Choose a release channel for each mod (the pack's is %s); Enter keeps the current one.\n", ternary(packCfg.Channel == "", "any", packCfg.Channel))
+	changed := false
+	for _, slug := range mods {
+		_, own := packCfg.Channels[slug]
+		current := ternary(packCfg.ChannelFor(slug) == "", "any", packCfg.ChannelFor(slug))
+	ask:
+		for {
+			fmt.Printf("  %s (%s%s) [r]elease/[b]eta/[a]lpha/[p]ack default: ", slug, current, ternary(own, "", ", from pack"))
+			line, _ := readLine(reader)
+			choice := strings.ToLower(line)
+			channel := ""
+			switch choice {
+			case "":
+				break ask
+			case "r", "release":
+				channel = "release"
+			case "b", "beta":
+				channel = "beta"
+			case "a", "alpha":
+				channel = "alpha"
+			case "p", "pack", "default":
+				if own {
+					delete(packCfg.Channels, slug)
+					changed = true
+				}
+				break ask
+			default:
+				fmt.Printf("    Unknown choice %q\n", choice)
+				continue
+			}
+			if old, ok := packCfg.Channels[slug]; !ok || old != channel {
+				if packCfg.Channels == nil {
+					packCfg.Channels = make(map[string]string)
+				}
+				packCfg.Channels[slug] = channel
+				changed = true
+			}
+			break
+		}
+	}
+	return changed
+}
+
+// confirmCompatible checks that slug has a build for the pack and decides whether to add it.
+// Incompatible or unknown mods are refused under --yes and prompted for otherwise; if Modrinth
+// can't be reached the mod is added with a warning so offline edits still work.
+func confirmCompatible(reader *bufio.Reader, slug, packName string, packCfg ModpackConfig) bool {
+	_, err := FetchLatestVersionForChannel(slug, packCfg.MCVersion, packCfg.Loader, packCfg.ChannelFor(slug))
+	if err == nil {
+		return true
+	}
+	var incompatible *IncompatibleError
+	var status *StatusError
+	switch {
+	case errors.As(err, &incompatible):
+		fmt.Printf("✗ %s has no build for %s (MC %s, loader %s)\n", slug, packName, packCfg.MCVersion, packCfg.Loader)
+		if hint, herr := DescribeAvailability(slug, packCfg.MCVersion, packCfg.Loader); herr == nil {
+			fmt.Printf("  ↳ %s\n", hint)
+		}
+	case errors.As(err, &status) && status.StatusCode == http.StatusNotFound:
+		if match, ok := suggestProject(slug); ok {
+			fmt.Printf("✗ %s: no such Modrinth project; did you mean %q?\n", slug, match)
+		} else {
+			fmt.Printf("✗ %s: no such Modrinth project\n", slug)
+		}
+	default:
+		fmt.Printf("Warning: could not check compatibility of %s (%v); adding anyway\n", slug, err)
+		return true
+	}
+	if autoYes {
+		fmt.Printf("  Not adding %s (use --force to add it anyway)\n", slug)
+		return false
+	}
+	return askYesNo(reader, fmt.Sprintf("  Add %s to %s anyway? (y/N) ", slug, packName))
+}
+
+// confirmRightPack is the --validate guard against adding a mod to the wrong pack. It checks the
+// mod's builds for the pack's loader and MC version separately, and when either is missing lists
+// what the mod supports and which other packs it would fit before asking whether to add it anyway.
+func confirmRightPack(reader *bufio.Reader, cfg *Config, slug, packName string, packCfg ModpackConfig) bool {
+	versions, err := FetchAllVersions(slug)
+	if err != nil {
+		fmt.Printf("Warning: could not check the builds of %s (%v); adding anyway\n", slug, err)
+		return true
+	}
+	var loaders, mcVersions []string
+	for _, v := range versions {
+		for _, l := range v.Loaders {
+			loaders = appendUnique(loaders, l)
+		}
+		for _, gv := range v.GameVersions {
+			mcVersions = appendUnique(mcVersions, gv)
+		}
+	}
+	fits := func(p ModpackConfig) bool {
+		return slices.Contains(loaders, p.Loader) && slices.Contains(mcVersions, p.MCVersion)
+	}
+	if fits(packCfg) {
+		return true
+	}
+	if !slices.Contains(loaders, packCfg.Loader) {
+		fmt.Printf("✗ %s has no %s builds, but %s is a %s pack; it supports %s\n", slug, packCfg.Loader, packName, packCfg.Loader, strings.Join(loaders, ", "))
+	}
+	if !slices.Contains(mcVersions, packCfg.MCVersion) {
+		const maxShown = 5
+		shown := mcVersions[:min(len(mcVersions), maxShown)]
+		fmt.Printf("✗ %s has no builds for MC %s, which %s targets (newest targets: %s)\n", slug, packCfg.MCVersion, packName, strings.Join(shown, ", "))
+	}
+	var others []string
+	for _, name := range listedPackNames(cfg, false) {
+		if name != packName && fits(cfg.Modpacks[name]) {
+			others = append(others, name)
+		}
+	}
+	if len(others) > 0 {
+		fmt.Printf("  ↳ it would fit %s\n", strings.Join(others, ", "))
+	}
+	if autoYes {
+		fmt.Printf("  Not adding %s to %s\n", slug, packName)
+		return false
+	}
+	return askYesNo(reader, fmt.Sprintf("  Is %s the right pack? Add %s anyway? (y/N) ", packName, slug))
+}
+
+// removeModFile deletes a removed mod's jar, honoring --dry-run and warning when it's already gone
+func removeModFile(path string) {
+	if dryRun {
+		fmt.Printf("[dry-run] would delete %s\n", path)
+		return
+	}
+	if err := os.Remove(path); os.IsNotExist(err) {
+		fmt.Printf("Warning: %s is already gone\n", path)
+	} else if err != nil {
+		fmt.Printf("Warning: could not delete %s: %v\n", path, err)
+	} else {
+		fmt.Printf("Deleted %s\n", path)
+	}
+}
+
+// resolvePin looks up slug's version idOrNumber and checks it can be installed in the pack,
+// so a pin never points at a build for another Minecraft version or loader
+func resolvePin(slug, idOrNumber string, packCfg ModpackConfig) (*Version, error) {
+	ver, err := FetchProjectVersion(slug, idOrNumber)
+	var status *StatusError
+	if errors.As(err, &status) && status.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s has no version %q on Modrinth", slug, idOrNumber)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to look up version %q of %s: %w", idOrNumber, slug, err)
+	}
+	// Hand-written configs may spell the loader "Fabric"; Modrinth's names are canonical
+	if !slices.Contains(ver.GameVersions, packCfg.MCVersion) || !slices.Contains(ver.Loaders, canonicalLoader(packCfg.Loader)) {
+		return nil, &IncompatibleError{fmt.Sprintf("%s %s (%s) is for MC %s on %s, but the pack uses MC %s on %s",
+			slug, ver.VersionNumber, ver.ID, strings.Join(ver.GameVersions, ", "), strings.Join(ver.Loaders, ", "), packCfg.MCVersion, packCfg.Loader)}
+	}
+	if _, err := ver.PrimaryFile(); err != nil {
+		return nil, err
+	}
+	return ver, nil
+}
+
+// printAvailabilityHint explains, under --probe-loaders, which loaders/versions a mod does ship
+func printAvailabilityHint(slug, mcVersion, loader, indent string) {
+	if !probeLoaders {
+		return
+	}
+	hint, err := DescribeAvailability(slug, mcVersion, loader)
+	if err != nil {
+		if verbose {
+			fmt.Printf("%s↳ could not probe other loaders: %v\n", indent, err)
+		}
+		return
+	}
+	fmt.Printf("%s↳ %s\n", indent, hint)
+}
+
 // Helper for conditional printing in check-updates
 func ternary(condition bool, trueVal, falseVal string) string {
 	if condition {
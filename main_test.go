@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command line instead of the tests when runMain starts the test binary, so a
+// command can be run end to end with its own stdin
+func TestMain(m *testing.M) {
+	if os.Getenv("MODPILOT_TEST_MAIN") != "" {
+		i := slices.Index(os.Args, "--")
+		os.Args = append([]string{"modpilot"}, os.Args[i+1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs modpilot with args in dir, reading stdin, and returns its combined output
+func runMain(t *testing.T, dir, stdin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], append([]string{"--"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "MODPILOT_TEST_MAIN=1")
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestCreatePackNoInput(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.json"), `{"default_mc_version": "1.21.1", "default_loader": "fabric", "modpacks": {}}`)
+
+	// With stdin at EOF every prompt takes the config's default
+	out, err := runMain(t, dir, "", "create-pack", "survival", "-c", "config.json", "-s", "state.json")
+	if err != nil {
+		t.Fatalf("create-pack with no input failed: %v\n%s", err, out)
+	}
+	cfg, err := LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := cfg.Modpacks["survival"]; !ok || p.MCVersion != "1.21.1" || p.Loader != "fabric" {
+		t.Errorf("create-pack with no input saved %+v, want the defaults 1.21.1/fabric\n%s", cfg.Modpacks, out)
+	}
+
+	// Without a default there is nothing to fall back on, so it fails instead of prompting again
+	writeFile(t, filepath.Join(dir, "config.json"), `{"modpacks": {}}`)
+	out, err = runMain(t, dir, "", "create-pack", "survival", "-c", "config.json", "-s", "state.json")
+	if err == nil || !strings.Contains(out, "no input; pass --mc-version") {
+		t.Errorf("create-pack with no input and no default: %v\n%s", err, out)
+	}
+}
+
+func TestInitNoInput(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.json"), `{"default_mc_version": "1.20.1", "default_loader": "forge", "modpacks": {}}`)
+	out, err := runMain(t, dir, "", "init", "-c", "config.json", "-s", "state.json")
+	if err != nil {
+		t.Fatalf("init with no input failed: %v\n%s", err, out)
+	}
+	cfg, err := LoadConfig(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DefaultMCVersion != "1.20.1" || cfg.DefaultLoader != "forge" {
+		t.Errorf("init with no input changed the defaults to %s/%s", cfg.DefaultMCVersion, cfg.DefaultLoader)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state.json")); err != nil {
+		t.Errorf("init did not create the state: %v", err)
+	}
+}
+
+func TestRemoveModAllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	config := `{"modpacks": {"MyPack": {"mc_version": "1.21.1", "loader": "fabric", "mods": ["sodium", "lithium"]}}}`
+	writeFile(t, filepath.Join(dir, "config.json"), config)
+
+	out, err := runMain(t, dir, "", "remove-mod", "MyPack", "sodium", "zzzzzzzz", "-c", "config.json", "-s", "state.json")
+	if err == nil || !strings.Contains(out, "nothing was saved") {
+		t.Fatalf("remove-mod with an unknown slug: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "Removed") {
+		t.Errorf("remove-mod claimed a removal it didn't save:\n%s", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "config.json")); string(data) != config {
+		t.Errorf("remove-mod changed the config to %s", data)
+	}
+
+	out, err = runMain(t, dir, "", "remove-mod", "MyPack", "sodium", "-c", "config.json", "-s", "state.json")
+	if err != nil || !strings.Contains(out, `Removed "sodium" from MyPack`) {
+		t.Errorf("remove-mod sodium: %v\n%s", err, out)
+	}
+}
+
+func TestUnfreezeAllCountsFrozenMods(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.json"), `{"modpacks": {"MyPack": {"mc_version": "1.21.1", "loader": "fabric", "mods": ["sodium", "lithium", "iris"], "frozen": ["sodium", "gone"]}}}`)
+
+	out, err := runMain(t, dir, "", "unfreeze-all", "MyPack", "-c", "config.json", "-s", "state.json")
+	if err != nil || !strings.Contains(out, "Unfroze 1 mod(s) in MyPack (2 already unfrozen)") {
+		t.Errorf("unfreeze-all: %v\n%s", err, out)
+	}
+	out, err = runMain(t, dir, "", "unfreeze-all", "MyPack", "-c", "config.json", "-s", "state.json")
+	if err != nil || !strings.Contains(out, "All 3 mod(s) in MyPack are already unfrozen") {
+		t.Errorf("second unfreeze-all: %v\n%s", err, out)
+	}
+}
+
+func TestResolvePinCanonicalLoader(t *testing.T) {
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/sodium/version/mc1.21.1-0.6.0" {
+			http.NotFound(w, r)
+			return
+		}
+		ver := Version{ID: "sodium-v1", VersionNumber: "mc1.21.1-0.6.0", GameVersions: []string{"1.21.1"}, Loaders: []string{"fabric", "quilt"},
+			Files: []VersionFile{{URL: "https://cdn.modrinth.com/sodium.jar", Filename: "sodium.jar", Primary: true}}}
+		json.NewEncoder(w).Encode(ver)
+	}))
+	for _, loader := range []string{"fabric", "Fabric", "FabricMC"} {
+		ver, err := resolvePin("sodium", "mc1.21.1-0.6.0", ModpackConfig{MCVersion: "1.21.1", Loader: loader})
+		if err != nil || ver.ID != "sodium-v1" {
+			t.Errorf("resolvePin for loader %q = %v, %v; want sodium-v1", loader, ver, err)
+		}
+	}
+	if _, err := resolvePin("sodium", "mc1.21.1-0.6.0", ModpackConfig{MCVersion: "1.21.1", Loader: "Forge"}); err == nil {
+		t.Error("resolvePin accepted a fabric build for a forge pack")
+	}
+}
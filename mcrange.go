@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExpandMCRange turns a --mc-version-range such as "1.20.1 - 1.20.4" (inclusive; a single
+// version also works) into the Minecraft releases Modrinth knows inside it, highest first
+func ExpandMCRange(spec string) ([]string, error) {
+	loSpec, hiSpec, isRange := strings.Cut(spec, " - ")
+	if !isRange {
+		hiSpec = loSpec
+	}
+	lo, loOK := parseSemver(loSpec)
+	hi, hiOK := parseSemver(hiSpec)
+	if !loOK || !hiOK {
+		return nil, fmt.Errorf("invalid --mc-version-range %q (want e.g. \"1.20.1 - 1.20.4\")", spec)
+	}
+	if compareSemver(lo, hi) > 0 {
+		lo, hi = hi, lo
+	}
+
+	tags, err := FetchGameVersions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Minecraft versions: %w", err)
+	}
+	type release struct {
+		name string
+		v    semver
+	}
+	var in []release
+	for _, t := range tags {
+		if t.VersionType != "release" {
+			continue
+		}
+		if v, ok := parseSemver(t.Version); ok && compareSemver(v, lo) >= 0 && compareSemver(v, hi) <= 0 {
+			in = append(in, release{t.Version, v})
+		}
+	}
+	if len(in) == 0 {
+		return nil, fmt.Errorf("no Minecraft release falls within %q", spec)
+	}
+	sort.Slice(in, func(i, j int) bool { return compareSemver(in[i].v, in[j].v) > 0 })
+	names := make([]string, len(in))
+	for i, r := range in {
+		names[i] = r.name
+	}
+	return names, nil
+}
+
+// LatestInRange is LatestCompatible over several Minecraft versions: it returns the best build
+// for the highest of mcVersions (ordered highest first) that has one, and that version. A build
+// targeting the highest in-range version therefore beats a newer one that only lists older ones;
+// among builds for the same version the newest wins.
+func LatestInRange(versions []Version, slug string, mcVersions []string, loader, channel string) (*Version, string, error) {
+	for _, mc := range mcVersions {
+		if v, err := LatestCompatible(versions, slug, mc, loader, channel); err == nil {
+			return v, mc, nil
+		}
+	}
+	span := mcVersions[0]
+	if len(mcVersions) > 1 {
+		span = mcVersions[len(mcVersions)-1] + " - " + mcVersions[0]
+	}
+	return nil, "", &IncompatibleError{fmt.Sprintf("no compatible version found for %s (MC %s, loader %s)", slug, span, loader)}
+}
+
+// FetchLatestInRange fetches slug's builds for loader and picks one with LatestInRange
+func FetchLatestInRange(slug string, mcVersions []string, loader, channel string) (*Version, string, error) {
+	versions, err := FetchLoaderVersions(slug, loader)
+	if err != nil {
+		return nil, "", err
+	}
+	return LatestInRange(versions, slug, mcVersions, loader, channel)
+}
+
+// rangeVersions expands --mc-version-range for update and check-updates, returning nil when it isn't set
+func rangeVersions() ([]string, error) {
+	if mcRange == "" {
+		return nil, nil
+	}
+	if mcVersionFlag != "" {
+		return nil, fmt.Errorf("--mc-version and --mc-version-range can't be combined")
+	}
+	return ExpandMCRange(mcRange)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExpandMCRange(t *testing.T) {
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/tag/game_version" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode([]GameVersion{
+			{Version: "1.21", VersionType: "release"},
+			{Version: "24w14a", VersionType: "snapshot"},
+			{Version: "1.20.5-pre1", VersionType: "snapshot"},
+			{Version: "1.20.4", VersionType: "release"},
+			{Version: "1.20.3", VersionType: "release"},
+			{Version: "1.20.2", VersionType: "release"},
+			{Version: "1.20.1", VersionType: "release"},
+			{Version: "1.20", VersionType: "release"},
+			{Version: "1.19.4", VersionType: "release"},
+		})
+	}))
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{spec: "1.20.1 - 1.20.4", want: []string{"1.20.4", "1.20.3", "1.20.2", "1.20.1"}},
+		{spec: "1.20.4 - 1.20.1", want: []string{"1.20.4", "1.20.3", "1.20.2", "1.20.1"}}, // either order
+		{spec: "1.20 - 1.20.1", want: []string{"1.20.1", "1.20"}},
+		{spec: "1.20.2", want: []string{"1.20.2"}},
+		{spec: "1.20.4 - 1.21", want: []string{"1.21", "1.20.4"}}, // snapshots left out
+		{spec: "1.18 - 1.18.2", wantErr: true},                    // nothing in it
+		{spec: "latest", wantErr: true},
+		{spec: "1.20.1-1.20.4", wantErr: true}, // the dash needs spaces
+	}
+	for _, tt := range tests {
+		got, err := ExpandMCRange(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ExpandMCRange(%q) = %v, want an error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ExpandMCRange(%q): %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ExpandMCRange(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestLatestInRange(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	versions := []Version{
+		{ID: "new-old-mc", GameVersions: []string{"1.20.1"}, Loaders: []string{"fabric"}, VersionType: "release", DatePublished: day(9)},
+		{ID: "top-mc", GameVersions: []string{"1.20.4"}, Loaders: []string{"fabric"}, VersionType: "release", DatePublished: day(2)},
+		{ID: "top-mc-newer", GameVersions: []string{"1.20.3", "1.20.4"}, Loaders: []string{"fabric"}, VersionType: "release", DatePublished: day(3)},
+		{ID: "top-mc-beta", GameVersions: []string{"1.20.4"}, Loaders: []string{"fabric"}, VersionType: "beta", DatePublished: day(8)},
+		{ID: "forge", GameVersions: []string{"1.20.4"}, Loaders: []string{"forge"}, VersionType: "release", DatePublished: day(9)},
+	}
+	tests := []struct {
+		name       string
+		mcVersions []string
+		loader     string
+		channel    string
+		wantID     string
+		wantMC     string
+	}{
+		{"highest version beats a newer build for an older one", []string{"1.20.4", "1.20.3", "1.20.1"}, "fabric", "release", "top-mc-newer", "1.20.4"},
+		{"channel allows the beta", []string{"1.20.4", "1.20.1"}, "fabric", "beta", "top-mc-beta", "1.20.4"},
+		{"falls back to a lower version", []string{"1.20.2", "1.20.1"}, "fabric", "release", "new-old-mc", "1.20.1"},
+		{"loader filters", []string{"1.20.4"}, "forge", "release", "forge", "1.20.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, mc, err := LatestInRange(versions, "mod", tt.mcVersions, tt.loader, tt.channel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.ID != tt.wantID || mc != tt.wantMC {
+				t.Errorf("LatestInRange = %s for %s, want %s for %s", v.ID, mc, tt.wantID, tt.wantMC)
+			}
+		})
+	}
+
+	_, _, err := LatestInRange(versions, "mod", []string{"1.20.2", "1.19.4"}, "fabric", "release")
+	var incompatible *IncompatibleError
+	if !errors.As(err, &incompatible) {
+		t.Fatalf("LatestInRange with no build in range: %v, want an IncompatibleError", err)
+	}
+	if want := "no compatible version found for mod (MC 1.19.4 - 1.20.2, loader fabric)"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
@@ -1,85 +1,965 @@
 package main
 
 import (
+    "compress/gzip"
+    "context"
+    "crypto/sha512"
+    "encoding/hex"
     "encoding/json"
+    "errors"
     "fmt"
     "io"
+    "math/rand/v2"
     "net/http"
+    "net/url"
     "os"
     "path"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
 )
 
 type Version struct {
-    ID           string   `json:"id"`
-    GameVersions []string `json:"game_versions"`
-    Loaders      []string `json:"loaders"`
-    Files []struct {
-        URL      string `json:"url"`
-        Filename string `json:"filename"`
-    } `json:"files"`
+    ID            string    `json:"id"`
+    ProjectID     string    `json:"project_id"`
+    VersionNumber string    `json:"version_number"`
+    VersionType   string    `json:"version_type"` // release, beta or alpha
+    DatePublished time.Time `json:"date_published"`
+    Changelog     string    `json:"changelog"`
+    GameVersions  []string  `json:"game_versions"`
+    Loaders       []string  `json:"loaders"`
+    Files         []VersionFile `json:"files"`
+    Dependencies  []Dependency  `json:"dependencies"`
+}
+
+// Dependency is one entry of a version's dependency list
+type Dependency struct {
+    VersionID      string `json:"version_id"` // empty unless a specific version is required
+    ProjectID      string `json:"project_id"`
+    FileName       string `json:"file_name"`
+    DependencyType string `json:"dependency_type"` // required, optional, incompatible or embedded
+}
+
+// VersionFile is one downloadable file attached to a Version
+type VersionFile struct {
+    URL      string `json:"url"`
+    Filename string `json:"filename"`
+    Primary  bool   `json:"primary"`
+    Size     int64  `json:"size"`
+    FileType string `json:"file_type"` // set for resource packs, empty for mod jars
+    Hashes   struct {
+        SHA1   string `json:"sha1"`
+        SHA512 string `json:"sha512"`
+    } `json:"hashes"`
+}
+
+// PrimaryFile returns the mod jar to download for v: the primary .jar, or the only plain .jar
+// when none is flagged primary. Sources/javadoc jars and non-jar files are never picked.
+func (v *Version) PrimaryFile() (*VersionFile, error) {
+    var jars []*VersionFile
+    for i := range v.Files {
+        f := &v.Files[i]
+        name := strings.ToLower(f.Filename)
+        if !strings.HasSuffix(name, ".jar") || strings.HasSuffix(name, "-sources.jar") || strings.HasSuffix(name, "-javadoc.jar") || f.FileType != "" {
+            continue
+        }
+        if f.Primary {
+            return f, nil
+        }
+        jars = append(jars, f)
+    }
+    if len(jars) == 1 {
+        return jars[0], nil
+    }
+    if len(v.Files) == 0 {
+        return nil, fmt.Errorf("no files found for version %s", v.ID)
+    }
+    names := make([]string, len(v.Files))
+    for i, f := range v.Files {
+        names[i] = f.Filename
+        if f.Primary {
+            names[i] += " (primary)"
+        }
+    }
+    if len(jars) == 0 {
+        return nil, fmt.Errorf("version %s has no mod jar to download (files: %s)", v.ID, strings.Join(names, ", "))
+    }
+    return nil, fmt.Errorf("version %s has several jars and none is marked primary (files: %s)", v.ID, strings.Join(names, ", "))
+}
+
+// IncompatibleError reports that a project has no version for the requested MC version, loader or channel
+type IncompatibleError struct {
+    msg string
+}
+
+func (e *IncompatibleError) Error() string { return e.msg }
+
+// StatusError is returned when the API answers with anything other than 200 OK
+type StatusError struct {
+    URL        string
+    StatusCode int
+    Status     string
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("GET %s: unexpected status %s", e.URL, e.Status)
+}
+
+// channelRank orders release channels from most to least stable
+var channelRank = map[string]int{
+    "release": 0,
+    "beta":    1,
+    "alpha":   2,
 }
 
 // FetchLatestVersion queries Modrinth for the newest version matching MC+loader
 func FetchLatestVersion(slug, mcVersion, loader string) (*Version, error) {
-    url := fmt.Sprintf(
-        "https://api.modrinth.com/v2/project/%s/version?loaders=%s&game_versions=%s",
-        slug, loader, mcVersion,
-    )
-    resp, err := http.Get(url)
+    return FetchLatestVersionForChannel(slug, mcVersion, loader, "")
+}
+
+// FetchLatestVersionForChannel is FetchLatestVersion restricted to versions at least as stable as channel ("" accepts any)
+func FetchLatestVersionForChannel(slug, mcVersion, loader, channel string) (*Version, error) {
+    versions, err := FetchVersions(slug, mcVersion, loader)
     if err != nil {
         return nil, err
     }
-    defer resp.Body.Close()
+    return LatestCompatible(versions, slug, mcVersion, loader, channel)
+}
 
-    var versions []Version
-    if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+// Project is the subset of a Modrinth project that modpilot uses
+type Project struct {
+    ID         string `json:"id"`
+    Slug       string `json:"slug"`
+    Title      string `json:"title"`
+    ClientSide string `json:"client_side"` // required, optional, unsupported or unknown
+    ServerSide string `json:"server_side"`
+}
+
+// SupportsEnv reports whether the project belongs in a client or server install.
+// Only an explicit "unsupported" rules it out; "both" accepts everything.
+func (p *Project) SupportsEnv(env string) bool {
+    switch env {
+    case "client":
+        return p.ClientSide != "unsupported"
+    case "server":
+        return p.ServerSide != "unsupported"
+    }
+    return true
+}
+
+// FetchProject looks up a project by slug or ID
+func FetchProject(slug string) (*Project, error) {
+    body, err := cachedGet(fmt.Sprintf("%s/v2/project/%s", apiBase, slug))
+    if err != nil {
+        return nil, err
+    }
+    var p Project
+    if err := json.Unmarshal(body, &p); err != nil {
         return nil, err
     }
+    return &p, nil
+}
+
+// GameVersion is one entry of Modrinth's game_version tag list
+type GameVersion struct {
+    Version     string    `json:"version"`
+    VersionType string    `json:"version_type"` // release, snapshot, alpha or beta
+    Date        time.Time `json:"date"`
+    Major       bool      `json:"major"`
+}
+
+// SearchHit is one project from a Modrinth search
+type SearchHit struct {
+    Slug         string    `json:"slug"`
+    Title        string    `json:"title"`
+    Description  string    `json:"description"`
+    Downloads    int       `json:"downloads"`
+    ProjectID    string    `json:"project_id"`
+    DateModified time.Time `json:"date_modified"`
+    Versions     []string  `json:"versions"` // game versions the project has builds for
+}
+
+// SearchProjects runs a Modrinth search for mods matching query, best match first
+func SearchProjects(query string, limit int) ([]SearchHit, error) {
+    facets := url.QueryEscape(`[["project_type:mod"]]`)
+    body, err := cachedGet(fmt.Sprintf("%s/v2/search?query=%s&facets=%s&limit=%d", apiBase, url.QueryEscape(query), facets, limit))
+    if err != nil {
+        return nil, err
+    }
+    var res struct {
+        Hits []SearchHit `json:"hits"`
+    }
+    if err := json.Unmarshal(body, &res); err != nil {
+        return nil, err
+    }
+    return res.Hits, nil
+}
+
+// FetchGameVersions returns every Minecraft version Modrinth knows about, newest first
+func FetchGameVersions() ([]GameVersion, error) {
+    body, err := cachedGet(apiBase + "/v2/tag/game_version")
+    if err != nil {
+        return nil, err
+    }
+    var tags []GameVersion
+    if err := json.Unmarshal(body, &tags); err != nil {
+        return nil, err
+    }
+    return tags, nil
+}
+
+// staleMCAge is how far a pack's MC version may trail the newest release before it counts as legacy
+const staleMCAge = 2 * 365 * 24 * time.Hour
+
+// LegacyMCWarning explains, when mcVersion was released more than staleMCAge before the newest
+// Minecraft release, that few mods still publish builds for it. It returns "" otherwise.
+func LegacyMCWarning(mcVersion string) (string, error) {
+    tags, err := FetchGameVersions()
+    if err != nil {
+        return "", err
+    }
+    var own, newest *GameVersion
+    for i := range tags {
+        t := &tags[i]
+        if t.Version == mcVersion {
+            own = t
+        }
+        if t.VersionType == "release" && (newest == nil || t.Date.After(newest.Date)) {
+            newest = t
+        }
+    }
+    if own == nil || newest == nil || newest.Date.Sub(own.Date) < staleMCAge {
+        return "", nil
+    }
+    years := int(newest.Date.Sub(own.Date).Hours() / 24 / 365)
+    return fmt.Sprintf("Minecraft %s was released %s, about %d year(s) before the current %s; many mods no longer publish builds for it, so expect few updates and consider moving the pack to a newer version",
+        mcVersion, own.Date.Format("2006-01-02"), years, newest.Version), nil
+}
+
+// FetchVersion looks up a single version by its Modrinth ID
+func FetchVersion(id string) (*Version, error) {
+    body, err := cachedGet(fmt.Sprintf("%s/v2/version/%s", apiBase, id))
+    if err != nil {
+        return nil, err
+    }
+    var v Version
+    if err := json.Unmarshal(body, &v); err != nil {
+        return nil, err
+    }
+    return &v, nil
+}
+
+// FetchVersionByHash finds the version that published the file with the given SHA-512
+func FetchVersionByHash(sha512 string) (*Version, error) {
+    body, err := cachedGet(fmt.Sprintf("%s/v2/version_file/%s?algorithm=sha512", apiBase, sha512))
+    if err != nil {
+        return nil, err
+    }
+    var v Version
+    if err := json.Unmarshal(body, &v); err != nil {
+        return nil, err
+    }
+    return &v, nil
+}
+
+// FetchProjectVersion looks up one of slug's versions by its ID or version number, either of which
+// can appear in a modrinth.com version link
+func FetchProjectVersion(slug, idOrNumber string) (*Version, error) {
+    body, err := cachedGet(fmt.Sprintf("%s/v2/project/%s/version/%s", apiBase, url.PathEscape(slug), url.PathEscape(idOrNumber)))
+    if err != nil {
+        return nil, err
+    }
+    var v Version
+    if err := json.Unmarshal(body, &v); err != nil {
+        return nil, err
+    }
+    return &v, nil
+}
+
+// ParseVersionURL extracts the project slug and version (ID or number) from a modrinth.com
+// version link such as https://modrinth.com/mod/sodium/version/mc1.20.1-0.5.3
+func ParseVersionURL(raw string) (slug, version string, ok bool) {
+    u, err := url.Parse(raw)
+    if err != nil || (u.Scheme != "https" && u.Scheme != "http") || !slices.Contains([]string{"modrinth.com", "staging.modrinth.com"}, strings.TrimPrefix(u.Host, "www.")) {
+        return "", "", false
+    }
+    parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+    if len(parts) != 4 || parts[2] != "version" || parts[1] == "" || parts[3] == "" {
+        return "", "", false
+    }
+    return parts[1], parts[3], true
+}
+
+// FetchVersions returns the versions Modrinth lists for slug under MC+loader, newest first
+func FetchVersions(slug, mcVersion, loader string) ([]Version, error) {
+    url := fmt.Sprintf("%s/v2/project/%s/version?loaders=%s&game_versions=%s",
+        apiBase, slug, loader, mcVersion,
+    )
+    return fetchVersionList(slug, url)
+}
+
+// FetchLoaderVersions returns slug's versions for loader on any Minecraft version, newest first
+func FetchLoaderVersions(slug, loader string) ([]Version, error) {
+    return fetchVersionList(slug, fmt.Sprintf("%s/v2/project/%s/version?loaders=%s", apiBase, slug, loader))
+}
+
+// FetchAllVersions returns every version of slug regardless of MC version or loader, newest first
+func FetchAllVersions(slug string) ([]Version, error) {
+    return fetchVersionList(slug, fmt.Sprintf("%s/v2/project/%s/version", apiBase, slug))
+}
+
+// versionPageSize is how many versions fetchVersionList asks for per request. It keeps paging until a
+// short page comes back, so a build for an older MC version can't be missed on a prolific project.
+const (
+    versionPageSize = 100
+    maxVersionPages = 50 // stops runaway paging if the server ignores offset
+)
+
+func fetchVersionList(slug, url string) ([]Version, error) {
+    sep := "?"
+    if strings.Contains(url, "?") {
+        sep = "&"
+    }
+
+    var versions []Version
+    seen := make(map[string]bool)
+    for page := 0; page < maxVersionPages; page++ {
+        body, err := cachedGet(fmt.Sprintf("%s%slimit=%d&offset=%d", url, sep, versionPageSize, page*versionPageSize))
+        if err != nil {
+            return nil, err
+        }
+        var batch []Version
+        if err := json.Unmarshal(body, &batch); err != nil {
+            return nil, err
+        }
+        added := 0
+        for _, v := range batch {
+            if !seen[v.ID] {
+                seen[v.ID] = true
+                versions = append(versions, v)
+                added++
+            }
+        }
+        // A short page is the last one; a page with nothing new means offset isn't supported
+        if len(batch) < versionPageSize || added == 0 {
+            break
+        }
+    }
     if len(versions) == 0 {
-        return nil, fmt.Errorf("no versions found for %s", slug)
+        return nil, &IncompatibleError{fmt.Sprintf("no versions found for %s", slug)}
     }
-    // find first version whose game_versions includes mcVersion and loaders includes loader
+    return versions, nil
+}
+
+// preferVersionNumber breaks date_published ties by comparing parsed version_number
+var preferVersionNumber bool
+
+// LatestCompatible picks the most recently published version whose game_versions includes mcVersion,
+// loaders includes loader and whose channel is allowed by channel. Ties keep the API order unless
+// preferVersionNumber is set, in which case the higher version_number wins.
+func LatestCompatible(versions []Version, slug, mcVersion, loader, channel string) (*Version, error) {
+    var best *Version
+    for i := range versions {
+        v := &versions[i]
+        if !ChannelAllows(channel, v.VersionType) {
+            continue
+        }
+        if !slices.Contains(v.GameVersions, mcVersion) || !slices.Contains(v.Loaders, loader) {
+            continue
+        }
+        if best == nil || newerThan(v, best) {
+            best = v
+        }
+    }
+    if best != nil {
+        return best, nil
+    }
+    if channel != "" {
+        return nil, &IncompatibleError{fmt.Sprintf("no compatible %s version found for %s (MC %s, loader %s)", channel, slug, mcVersion, loader)}
+    }
+    return nil, &IncompatibleError{fmt.Sprintf("no compatible version found for %s (MC %s, loader %s)", slug, mcVersion, loader)}
+}
+
+// newerThan reports whether a should be preferred over b, which appears earlier in the API order
+func newerThan(a, b *Version) bool {
+    if !a.DatePublished.Equal(b.DatePublished) {
+        return a.DatePublished.After(b.DatePublished)
+    }
+    if preferVersionNumber {
+        av, aok := parseSemver(a.VersionNumber)
+        bv, bok := parseSemver(b.VersionNumber)
+        if aok && bok {
+            return compareSemver(av, bv) > 0
+        }
+    }
+    return false
+}
+
+// DescribeAvailability probes slug's unfiltered version list and explains what it does ship,
+// turning a "no compatible version" error into something actionable
+func DescribeAvailability(slug, mcVersion, loader string) (string, error) {
+    versions, err := FetchAllVersions(slug)
+    if err != nil {
+        return "", err
+    }
+    var loadersForMC, mcForLoader []string
     for _, v := range versions {
-        okGV := false
+        hasMC, hasLoader := false, false
         for _, gv := range v.GameVersions {
             if gv == mcVersion {
-                okGV = true
-                break
+                hasMC = true
             }
         }
-        if !okGV {
-            continue
-        }
         for _, ld := range v.Loaders {
             if ld == loader {
-                return &v, nil
+                hasLoader = true
             }
         }
+        if hasMC {
+            for _, ld := range v.Loaders {
+                loadersForMC = appendUnique(loadersForMC, ld)
+            }
+        }
+        if hasLoader {
+            for _, gv := range v.GameVersions {
+                mcForLoader = appendUnique(mcForLoader, gv)
+            }
+        }
+    }
+
+    switch {
+    case len(loadersForMC) > 0:
+        hint := fmt.Sprintf("%s has %s builds for %s but you're on %s", slug, strings.Join(loadersForMC, "/"), mcVersion, loader)
+        if loader == "quilt" && slices.Contains(loadersForMC, "fabric") {
+            hint += " (quilt can usually load fabric mods)"
+        }
+        return hint, nil
+    case len(mcForLoader) > 0:
+        const maxShown = 5
+        shown := mcForLoader
+        if len(shown) > maxShown {
+            shown = shown[:maxShown]
+        }
+        return fmt.Sprintf("%s has %s builds, but not for %s (newest targets: %s)", slug, loader, mcVersion, strings.Join(shown, ", ")), nil
+    default:
+        return fmt.Sprintf("%s has no builds for %s or for %s", slug, mcVersion, loader), nil
     }
-    return nil, fmt.Errorf("no compatible version found for %s (MC %s, loader %s)", slug, mcVersion, loader)
 }
 
-// DownloadFile streams the URL to destDir/<filename>
-func DownloadFile(url, destDir string) (string, error) {
-    resp, err := http.Get(url)
+func appendUnique(list []string, s string) []string {
+    if slices.Contains(list, s) {
+        return list
+    }
+    return append(list, s)
+}
+
+// ChannelAllows reports whether a version of the given type may be picked on channel.
+// An empty channel accepts everything, as does an unknown version type.
+func ChannelAllows(channel, versionType string) bool {
+    if channel == "" {
+        return true
+    }
+    rank, ok := channelRank[versionType]
+    if !ok {
+        return true
+    }
+    return rank <= channelRank[channel]
+}
+
+// Modrinth API hosts. The staging API serves its files from staging-cdn.modrinth.com, and since
+// download URLs come from API responses, downloads follow whichever host is in use.
+const (
+    productionAPI = "https://api.modrinth.com"
+    stagingAPI    = "https://staging-api.modrinth.com"
+)
+
+// apiBase is the API every Modrinth request goes to; --modrinth-staging points it at stagingAPI
+var apiBase = productionAPI
+
+// modrinthToken is a Modrinth personal access token from --token or MODRINTH_TOKEN, sent as the
+// Authorization header of API requests so private and unlisted projects resolve. It goes to apiBase
+// only: not to the CDN, mirrors or config URLs, and never into any output.
+var modrinthToken string
+
+// sendsToken reports whether a request to url carries modrinthToken
+func sendsToken(url string) bool {
+    return modrinthToken != "" && strings.HasPrefix(url, apiBase+"/")
+}
+
+// apiTimeout bounds each attempt at an API request. downloadIdleTimeout only bounds the wait for
+// the next chunk of a download, so a large file on a slow but live connection isn't cut off. 0
+// disables either.
+var (
+    apiTimeout          = 30 * time.Second
+    downloadIdleTimeout = 60 * time.Second
+)
+
+// Redirect handling for downloads, set by --max-redirects, --no-follow-redirects and --trace
+var (
+    maxRedirects    = 10
+    followRedirects = true
+    traceRedirects  = false
+)
+
+// httpClient is shared by every API request and download. Downloads are marked in their
+// request's context so the redirect settings above apply to them alone.
+var httpClient = &http.Client{CheckRedirect: checkRedirect}
+
+// downloadKey marks a request's context as a file download
+type downloadKey struct{}
+
+// userAgent identifies modpilot to Modrinth, whose API guidelines ask every client for one
+// naming the app and a contact instead of Go's default
+func userAgent() string {
+    return fmt.Sprintf("modpilot/%s (+github.com/DeadFrostt/Modpilot)", version)
+}
+
+// newRequest builds a GET for url that carries modpilot's User-Agent
+func newRequest(ctx context.Context, url string) (*http.Request, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
     if err != nil {
-        return "", err
+        return nil, err
+    }
+    req.Header.Set("User-Agent", userAgent())
+    return req, nil
+}
+
+// checkRedirect follows API redirects as net/http would. For downloads it logs each hop under
+// --trace and refuses it when redirects are off or more than maxRedirects have been followed.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+    if req.Context().Value(downloadKey{}) == nil {
+        if len(via) >= 10 {
+            return redirectError("stopped after 10 redirects")
+        }
+        return nil
+    }
+    from := via[len(via)-1].URL
+    if traceRedirects {
+        printAboveProgress("  ↪ redirect %d: %s -> %s\n", len(via), from, req.URL)
+    }
+    if !followRedirects {
+        return redirectError(fmt.Sprintf("%s redirects to %s and --no-follow-redirects is set", from, req.URL))
+    }
+    if len(via) > maxRedirects {
+        return redirectError(fmt.Sprintf("stopped after %d redirect(s) (see --max-redirects)", maxRedirects))
+    }
+    applyDownloadHeaders(req)
+    return nil
+}
+
+// redirectError is a redirect checkRedirect refused, which retrying won't change
+type redirectError string
+
+func (e redirectError) Error() string { return string(e) }
+
+// maxRetries (--max-retries) is how many times a request is retried after a network error, a 5xx
+// or a 429 before its error is returned; 0 disables retries
+var maxRetries = 3
+
+// Retry waits double from retryBaseDelay with each attempt, up to retryMaxDelay. A 429's
+// Retry-After (or, without one, its X-Ratelimit-Reset) replaces the computed wait, but is never
+// waited out longer than retryAfterLimit.
+const (
+    retryBaseDelay  = time.Second
+    retryMaxDelay   = 30 * time.Second
+    retryAfterLimit = 5 * time.Minute
+)
+
+// withRetries calls send until it succeeds with a response that isn't a 5xx or 429, the error is
+// one retrying won't fix, or maxRetries retries have been made. The last result is returned as is.
+func withRetries(url string, send func() (*http.Response, error)) (*http.Response, error) {
+    for attempt := 0; ; attempt++ {
+        resp, err := send()
+        if err != nil && runCtx.Err() != nil {
+            return nil, errInterrupted
+        }
+        if attempt >= maxRetries || !transient(resp, err) {
+            return resp, err
+        }
+        // Jittered between half and one and a half times the backoff, so parallel workers don't retry in step
+        backoff := min(retryBaseDelay<<attempt, retryMaxDelay)
+        wait := rand.N(backoff) + backoff/2
+        reason := ""
+        if err != nil {
+            reason = err.Error()
+        } else {
+            reason = resp.Status
+            if resp.StatusCode == http.StatusTooManyRequests {
+                // Wait out the window: Retry-After if sent, else the end of Modrinth's rate-limit window
+                if after, ok := retryAfter(resp); ok {
+                    wait = min(after, retryAfterLimit)
+                } else if reset, ok := rateLimitReset(resp); ok {
+                    wait = min(reset+time.Second, retryAfterLimit)
+                }
+            }
+            resp.Body.Close()
+        }
+        if verbose {
+            printAboveProgress("  ⟳ GET %s: %s; retrying in %s (retry %d of %d)\n", url, reason, wait.Round(100*time.Millisecond), attempt+1, maxRetries)
+        }
+        countRun(func(s *RunStats) { s.Retries++ })
+        select {
+        case <-time.After(wait):
+        case <-runCtx.Done():
+            return nil, errInterrupted
+        }
+    }
+}
+
+// transient reports whether a request may succeed if sent again: on network errors and timeouts,
+// and on 5xx and 429 responses, but not on a malformed URL or a refused redirect
+func transient(resp *http.Response, err error) bool {
+    if err != nil {
+        var uerr *url.Error
+        if errors.As(err, &uerr) && uerr.Op == "parse" {
+            return false
+        }
+        return !errors.As(err, new(redirectError))
+    }
+    return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter reads a response's Retry-After header, in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+    value := resp.Header.Get("Retry-After")
+    if value == "" {
+        return 0, false
+    }
+    if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+        return time.Duration(secs) * time.Second, true
+    }
+    if at, err := http.ParseTime(value); err == nil {
+        return max(time.Until(at), 0), true
+    }
+    return 0, false
+}
+
+// httpGet issues a GET for a download once the shared rate limiter allows it, retrying transient
+// failures. The request is cancelled if the server goes downloadIdleTimeout without sending anything.
+func httpGet(url string) (*http.Response, error) {
+    return withRetries(url, func() (*http.Response, error) { return downloadGet(url) })
+}
+
+// downloadGet is a single attempt of httpGet
+func downloadGet(url string) (*http.Response, error) {
+    throttle()
+    ctx := context.WithValue(runCtx, downloadKey{}, true)
+    if downloadIdleTimeout <= 0 {
+        req, err := newRequest(ctx, url)
+        if err != nil {
+            return nil, err
+        }
+        applyDownloadHeaders(req)
+        return httpClient.Do(req)
+    }
+    ctx, cancel := context.WithCancel(ctx)
+    req, err := newRequest(ctx, url)
+    if err != nil {
+        cancel()
+        return nil, err
+    }
+    applyDownloadHeaders(req)
+    timer := time.AfterFunc(downloadIdleTimeout, cancel)
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        stalled := !timer.Stop() // the timer already fired and cancelled the request
+        cancel()
+        if stalled {
+            return nil, fmt.Errorf("GET %s: no response within %s", url, downloadIdleTimeout)
+        }
+        return nil, err
+    }
+    resp.Body = &idleTimeoutBody{body: resp.Body, ctx: ctx, cancel: cancel, timer: timer}
+    return resp, nil
+}
+
+// idleTimeoutBody pushes the download's cancellation back every time data arrives
+type idleTimeoutBody struct {
+    body   io.ReadCloser
+    ctx    context.Context
+    cancel context.CancelFunc
+    timer  *time.Timer
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+    n, err := b.body.Read(p)
+    if n > 0 {
+        b.timer.Reset(downloadIdleTimeout)
+    }
+    if err != nil && err != io.EOF && runCtx.Err() != nil {
+        err = errInterrupted
+    } else if err != nil && err != io.EOF && b.ctx.Err() != nil {
+        err = fmt.Errorf("download stalled: no data for %s", downloadIdleTimeout)
+    }
+    return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+    b.timer.Stop()
+    b.cancel()
+    return b.body.Close()
+}
+
+// apiGet is httpGet for JSON API calls: it sends any extra headers, asks for a gzip-compressed response and
+// decompresses it itself (setting Accept-Encoding turns off net/http's transparent handling)
+func apiGet(url string, header http.Header) (*http.Response, error) {
+    return withRetries(url, func() (*http.Response, error) { return apiAttempt(url, header) })
+}
+
+// apiAttempt is a single attempt of apiGet, with apiTimeout applying to each attempt on its own
+func apiAttempt(url string, header http.Header) (*http.Response, error) {
+    ctx, cancel := runCtx, context.CancelFunc(func() {})
+    if apiTimeout > 0 {
+        ctx, cancel = context.WithTimeout(ctx, apiTimeout)
+    }
+    req, err := newRequest(ctx, url)
+    if err != nil {
+        cancel()
+        return nil, err
+    }
+    for k, v := range header {
+        req.Header[k] = v
+    }
+    req.Header.Set("Accept-Encoding", "gzip")
+    if sendsToken(url) {
+        req.Header.Set("Authorization", modrinthToken)
+    }
+    waitAPIWindow()
+    throttle()
+    countRun(func(s *RunStats) { s.APIRequests++ })
+    resp, err := httpClient.Do(req)
+    if err != nil {
+        cancel()
+        return nil, err
+    }
+    observeRateLimit(resp)
+    // The timeout covers reading the body too, so it is only released when the body is closed
+    resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+    if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+        gz, err := gzip.NewReader(resp.Body)
+        if err != nil {
+            resp.Body.Close()
+            return nil, fmt.Errorf("GET %s: bad gzip response: %w", url, err)
+        }
+        resp.Body = &gzipBody{Reader: gz, raw: resp.Body}
+        resp.Header.Del("Content-Encoding")
+        resp.ContentLength = -1
+    }
+    return resp, nil
+}
+
+// cancelBody releases a request's context once its response body is closed
+type cancelBody struct {
+    io.ReadCloser
+    cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+    err := b.ReadCloser.Close()
+    b.cancel()
+    return err
+}
+
+// gzipBody closes both the gzip stream and the underlying response body
+type gzipBody struct {
+    *gzip.Reader
+    raw io.Closer
+}
+
+func (b *gzipBody) Close() error {
+    b.Reader.Close()
+    return b.raw.Close()
+}
+
+// Modrinth's CDN hosts, whose download URLs --mirror rewrites
+var cdnHosts = []string{"cdn.modrinth.com", "staging-cdn.modrinth.com"}
+
+// downloadMirror is a base URL serving the same paths as Modrinth's CDN, from --mirror or the
+// config's download_mirror; "" downloads from the CDN directly
+var downloadMirror string
+
+// parseMirror checks that a mirror is an absolute http(s) URL
+func parseMirror(mirror string) (*url.URL, error) {
+    u, err := url.Parse(mirror)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return nil, fmt.Errorf("download mirror %q is not an http(s) URL", mirror)
+    }
+    return u, nil
+}
+
+// mirrorURL rewrites a Modrinth CDN URL onto downloadMirror, keeping its path under the mirror's
+// own path; ok is false for other hosts or without a mirror
+func mirrorURL(raw string) (string, bool) {
+    if downloadMirror == "" {
+        return "", false
+    }
+    u, err := url.Parse(raw)
+    if err != nil || !slices.Contains(cdnHosts, u.Hostname()) {
+        return "", false
+    }
+    m, err := parseMirror(downloadMirror)
+    if err != nil {
+        return "", false
+    }
+    u.Scheme, u.Host = m.Scheme, m.Host
+    u.Path = strings.TrimSuffix(m.Path, "/") + u.Path
+    u.RawPath = ""
+    return u.String(), true
+}
+
+// downloadHeaders are extra headers for downloads from private hosts, from the config's
+// download_headers: host -> header name -> value, where a "*.example.com" host also matches its
+// subdomains and values may reference environment variables as ${NAME}
+var downloadHeaders map[string]map[string]string
+
+// isModrinthHost reports whether host belongs to Modrinth, whose requests never get downloadHeaders
+func isModrinthHost(host string) bool {
+    host = strings.ToLower(strings.TrimSuffix(host, "."))
+    return host == "modrinth.com" || strings.HasSuffix(host, ".modrinth.com")
+}
+
+// checkDownloadHeaders validates download_headers: hosts must be bare host names outside Modrinth
+func checkDownloadHeaders(headers map[string]map[string]string) error {
+    for host, set := range headers {
+        name := strings.TrimPrefix(host, "*.")
+        if name == "" || strings.ContainsAny(name, "/:*") {
+            return fmt.Errorf("download_headers host %q is not a host name (use e.g. \"mods.example.com\" or \"*.example.com\")", host)
+        }
+        if isModrinthHost(name) {
+            return fmt.Errorf("download_headers host %q is Modrinth's; private headers are only sent to other hosts", host)
+        }
+        for key := range set {
+            if key == "" || strings.ContainsAny(key, " :\r\n") {
+                return fmt.Errorf("download_headers for %q: %q is not a header name", host, key)
+            }
+        }
+    }
+    return nil
+}
+
+// hostHeaders returns the downloadHeaders for host: an exact entry, else the longest matching
+// "*." entry, and nothing for Modrinth's own hosts
+func hostHeaders(host string) map[string]string {
+    host = strings.ToLower(host)
+    if isModrinthHost(host) {
+        return nil
+    }
+    var match map[string]string
+    best := -1
+    for pattern, set := range downloadHeaders {
+        pattern = strings.ToLower(pattern)
+        if pattern == host {
+            return set
+        }
+        if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasSuffix(host, suffix) && len(suffix) > best {
+            match, best = set, len(suffix)
+        }
+    }
+    return match
+}
+
+// applyDownloadHeaders sets the downloadHeaders matching req's host after removing any set for
+// another host, so a redirect off a private host doesn't carry its credentials along
+func applyDownloadHeaders(req *http.Request) {
+    for _, set := range downloadHeaders {
+        for key := range set {
+            req.Header.Del(key)
+        }
+    }
+    for key, value := range hostHeaders(req.URL.Hostname()) {
+        req.Header.Set(key, os.ExpandEnv(value))
+    }
+}
+
+// DownloadFile streams the URL to destDir/name, or to the URL's last path element when name is "",
+// and checks it against sha512 when that's known. It returns the file's path and its actual
+// SHA-512, which differs from sha512 only for a file kept with --skip-hash. CDN URLs are tried on downloadMirror first,
+// falling back to the original URL if the mirror fails or serves a file with the wrong hash; with
+// no hash to check the mirror's copy against, the original URL is used directly.
+func DownloadFile(url, destDir, name, sha512 string) (string, string, error) {
+    if name == "" {
+        name = urlFilename(url)
+    }
+    if mirrored, ok := mirrorURL(url); ok && sha512 != "" {
+        outPath, sum, err := download(mirrored, destDir, name, sha512)
+        if err == nil {
+            return outPath, sum, nil
+        }
+        printAboveProgress("    ⚠ Mirror download failed (%v); falling back to %s\n", err, url)
+    }
+    return download(url, destDir, name, sha512)
+}
+
+// skipHash (--skip-hash) keeps downloads that don't match Modrinth's SHA-512, with a warning. It is
+// refused with a download mirror, since a mirror's copy is only trusted when its hash matches.
+var skipHash bool
+
+// runCtx is cancelled when the run is interrupted with Ctrl-C or SIGTERM. Requests and downloads
+// in flight then fail with errInterrupted, deleting their .part files, and commands unwind normally,
+// saving what already finished.
+var runCtx = context.Background()
+
+var errInterrupted = errors.New("interrupted")
+
+// download saves url as destDir/name and returns its path and SHA-512, discarding the file when
+// it is truncated or doesn't match want (when known and not --skip-hash)
+func download(url, destDir, name, want string) (string, string, error) {
+    resp, err := httpGet(url)
+    if err != nil {
+        return "", "", err
     }
     defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return "", "", fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+    }
 
     if err := os.MkdirAll(destDir, 0755); err != nil {
-        return "", err
+        return "", "", err
     }
-    fname := path.Base(url)
-    outPath := path.Join(destDir, fname)
-    out, err := os.Create(outPath)
+    // Written under a .part name and renamed into place once complete, so an interrupted or
+    // failed download never leaves a partial jar that looks installed
+    outPath := path.Join(destDir, name)
+    partPath := outPath + ".part"
+    out, err := os.Create(partPath)
     if err != nil {
-        return "", err
+        return "", "", err
     }
     defer out.Close()
+    fail := func(err error) (string, string, error) {
+        out.Close()
+        os.Remove(partPath)
+        return "", "", err
+    }
 
-    if _, err := io.Copy(out, resp.Body); err != nil {
-        return "", err
+    track := trackDownload(name, resp.ContentLength)
+    var body io.Reader = resp.Body
+    if track != nil {
+        body = io.TeeReader(resp.Body, track)
+    }
+    h := sha512.New()
+    n, err := io.Copy(io.MultiWriter(out, h), body)
+    track.finish()
+    countRun(func(s *RunStats) { s.Downloads++; s.DownloadedBytes += n })
+    if err != nil {
+        return fail(err)
+    }
+    // ContentLength is -1 when the server didn't send one
+    if resp.ContentLength >= 0 && n != resp.ContentLength {
+        return fail(fmt.Errorf("truncated download of %s: got %d of %d bytes", name, n, resp.ContentLength))
+    }
+    if err := out.Close(); err != nil {
+        return fail(err)
+    }
+    sum := hex.EncodeToString(h.Sum(nil))
+    if want != "" && sum != want {
+        if !skipHash {
+            return fail(fmt.Errorf("downloaded %s does not match Modrinth's hash (pass --skip-hash to keep files that don't)", name))
+        }
+        printAboveProgress("    ⚠ %s does not match Modrinth's hash; keeping it (--skip-hash)\n", name)
+    }
+    if err := os.Rename(partPath, outPath); err != nil {
+        return fail(err)
     }
-    return outPath, nil
+    return outPath, sum, nil
 }
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAPI points apiBase at a local server running handler, with a fresh cache directory and no
+// rate limiting or retries, restoring them when the test ends
+func newTestAPI(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	oldBase, oldCacheDir, oldTTL, oldLimiter, oldRetries := apiBase, cacheDir, cacheTTL, apiLimiter, maxRetries
+	apiBase, cacheDir, apiLimiter, maxRetries = srv.URL, t.TempDir(), nil, 0
+	t.Cleanup(func() {
+		srv.Close()
+		apiBase, cacheDir, cacheTTL, apiLimiter, maxRetries = oldBase, oldCacheDir, oldTTL, oldLimiter, oldRetries
+	})
+	return srv
+}
+
+func TestLatestCompatibleSameTimestamp(t *testing.T) {
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	version := func(id, number string) Version {
+		return Version{ID: id, VersionNumber: number, VersionType: "release", DatePublished: published, GameVersions: []string{"1.21.1"}, Loaders: []string{"fabric"}}
+	}
+	older := version("old", "1.2.0")
+	newer := version("new", "1.10.0")
+	tests := []struct {
+		name         string
+		versions     []Version
+		preferNumber bool
+		want         string
+	}{
+		{"API order, older first", []Version{older, newer}, false, "old"},
+		{"API order, newer first", []Version{newer, older}, false, "new"},
+		{"version number, older first", []Version{older, newer}, true, "new"},
+		{"version number, newer first", []Version{newer, older}, true, "new"},
+		{"unparsable number keeps API order", []Version{older, version("odd", "nightly")}, true, "old"},
+	}
+	defer func(old bool) { preferVersionNumber = old }(preferVersionNumber)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preferVersionNumber = tt.preferNumber
+			for range 3 { // the same input always picks the same version
+				got, err := LatestCompatible(tt.versions, "mod", "1.21.1", "fabric", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.ID != tt.want {
+					t.Fatalf("LatestCompatible picked %s, want %s", got.ID, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrimaryFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   string // the version's files as the API returns them
+		want    string // filename picked
+		wantErr string // or part of the error when no file is picked
+	}{
+		{"primary jar", `[{"filename": "mod-1.0.jar", "primary": true}, {"filename": "mod-1.0-dev.jar"}]`, "mod-1.0.jar", ""},
+		{"no primary flag", `[{"filename": "mod-1.0.jar"}, {"filename": "mod-1.0-sources.jar"}, {"filename": "mod-1.0-javadoc.jar"}]`, "mod-1.0.jar", ""},
+		{"primary sources jar", `[{"filename": "mod-1.0-sources.jar", "primary": true}, {"filename": "mod-1.0.jar"}]`, "mod-1.0.jar", ""},
+		{"sources only", `[{"filename": "mod-1.0-sources.jar", "primary": true}]`, "", "no mod jar"},
+		{"javadoc only", `[{"filename": "mod-1.0-javadoc.jar"}]`, "", "no mod jar"},
+		{"no jar", `[{"filename": "mod-1.0.zip", "primary": true}, {"filename": "README.txt"}]`, "", "no mod jar"},
+		{"resource pack jar", `[{"filename": "pack.jar", "primary": true, "file_type": "required-resource-pack"}]`, "", "no mod jar"},
+		{"several jars, none primary", `[{"filename": "mod-fabric-1.0.jar"}, {"filename": "mod-forge-1.0.jar"}]`, "", "none is marked primary"},
+		{"no files", `[]`, "", "no files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := Version{ID: "abc"}
+			if err := json.Unmarshal([]byte(tt.files), &v.Files); err != nil {
+				t.Fatal(err)
+			}
+			f, err := v.PrimaryFile()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("PrimaryFile() = %v, %v; want an error containing %q", f, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Filename != tt.want {
+				t.Errorf("PrimaryFile() picked %s, want %s", f.Filename, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIGetGzip(t *testing.T) {
+	srv := newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(`[{"id": "abc", "version_number": "1.0.0"}]`))
+		gz.Close()
+	}))
+	resp, err := apiGet(srv.URL+"/v2/project/mod/version", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+		t.Errorf("Content-Encoding %q left on the decompressed response", enc)
+	}
+	var versions []Version
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		t.Fatalf("decoding the gzip response: %v", err)
+	}
+	if len(versions) != 1 || versions[0].ID != "abc" || versions[0].VersionNumber != "1.0.0" {
+		t.Errorf("decoded %+v", versions)
+	}
+}
+
+// versionPages serves a project's version list paged by limit and offset, from total versions; a
+// negative total never runs out
+func versionPages(t *testing.T, total int, offsets *[]int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, err1 := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, err2 := strconv.Atoi(r.URL.Query().Get("offset"))
+		if err1 != nil || err2 != nil {
+			t.Errorf("request %s has no limit and offset", r.URL)
+		}
+		*offsets = append(*offsets, offset)
+		end := offset + limit
+		if total >= 0 {
+			end = min(end, total)
+		}
+		page := []Version{}
+		for i := offset; i < end; i++ {
+			page = append(page, Version{ID: fmt.Sprintf("v%d", i)})
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+func TestFetchVersionListPages(t *testing.T) {
+	var offsets []int
+	srv := newTestAPI(t, versionPages(t, 2*versionPageSize+versionPageSize/2, &offsets))
+	versions, err := fetchVersionList("mod", srv.URL+"/v2/project/mod/version?loaders=fabric")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, versionPageSize, 2 * versionPageSize}; !slices.Equal(offsets, want) {
+		t.Errorf("fetched offsets %v, want %v", offsets, want)
+	}
+	if len(versions) != 2*versionPageSize+versionPageSize/2 {
+		t.Fatalf("got %d versions, want every one of %d", len(versions), 2*versionPageSize+versionPageSize/2)
+	}
+	for i, v := range versions {
+		if v.ID != fmt.Sprintf("v%d", i) {
+			t.Fatalf("version %d is %s; pages were merged out of order", i, v.ID)
+		}
+	}
+}
+
+func TestFetchVersionListPageCap(t *testing.T) {
+	var offsets []int
+	srv := newTestAPI(t, versionPages(t, -1, &offsets))
+	versions, err := fetchVersionList("mod", srv.URL+"/v2/project/mod/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) != maxVersionPages || len(versions) != maxVersionPages*versionPageSize {
+		t.Errorf("an endless version list took %d requests for %d versions, want %d for %d", len(offsets), len(versions), maxVersionPages, maxVersionPages*versionPageSize)
+	}
+}
+
+func TestDownloadSkipHash(t *testing.T) {
+	srv := newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("repacked jar"))
+	}))
+	digest := sha512.Sum512([]byte("repacked jar"))
+	actual := hex.EncodeToString(digest[:])
+	published := strings.Repeat("0", 128)
+	dir := t.TempDir()
+
+	if _, _, err := DownloadFile(srv.URL+"/mod.jar", dir, "mod.jar", published); err == nil {
+		t.Fatal("a download that doesn't match its hash succeeded")
+	}
+
+	defer func(old bool) { skipHash = old }(skipHash)
+	skipHash = true
+	path, sum, err := DownloadFile(srv.URL+"/mod.jar", dir, "mod.jar", published)
+	if err != nil {
+		t.Fatalf("--skip-hash download failed: %v", err)
+	}
+	if sum != actual {
+		t.Errorf("--skip-hash download returned hash %s, want the kept file's %s", sum, actual)
+	}
+	if got, _ := fileSHA512(path); got != sum {
+		t.Errorf("returned hash %s doesn't match the file on disk (%s)", sum, got)
+	}
+}
+
+func TestDownloadInterrupted(t *testing.T) {
+	started := make(chan struct{})
+	srv := newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte("first bytes"))
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func(old context.Context) { runCtx = old }(runCtx)
+	runCtx = ctx
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	dir := t.TempDir()
+	if _, _, err := DownloadFile(srv.URL+"/mod.jar", dir, "mod.jar", ""); !errors.Is(err, errInterrupted) {
+		t.Fatalf("interrupted download returned %v, want errInterrupted", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("interrupted download left %s behind", entries[0].Name())
+	}
+}
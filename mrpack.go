@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mrpackIndex is modrinth.index.json, the manifest at the root of a .mrpack
+type mrpackIndex struct {
+	FormatVersion int               `json:"formatVersion"`
+	Game          string            `json:"game"`
+	VersionID     string            `json:"versionId"`
+	Name          string            `json:"name"`
+	Summary       string            `json:"summary,omitempty"`
+	Files         []mrpackFile      `json:"files"`
+	Dependencies  map[string]string `json:"dependencies"`
+}
+
+type mrpackFile struct {
+	Path      string            `json:"path"`
+	Hashes    map[string]string `json:"hashes"`
+	Env       map[string]string `json:"env,omitempty"`
+	Downloads []string          `json:"downloads"`
+	FileSize  int64             `json:"fileSize"`
+}
+
+// mrpackLoaders maps loader names to their key in the index's dependencies
+var mrpackLoaders = map[string]string{
+	"fabric":   "fabric-loader",
+	"quilt":    "quilt-loader",
+	"forge":    "forge",
+	"neoforge": "neoforge",
+}
+
+// mrpackExport is what exportMrpack put into the pack, for the summary
+type mrpackExport struct {
+	Downloads []string // slugs listed as Modrinth downloads
+	LocalJars []string // jars with no Modrinth source, bundled under overrides/mods
+	Overrides int      // files copied from --overrides
+}
+
+// exportMrpack writes packName as a .mrpack to dest. Mods installed from Modrinth are listed as
+// downloads; jars in the pack's mods directory that Modrinth can't account for (state entries
+// without a version, unknown versions, untracked local or dev jars) are bundled with their bytes
+// under overrides/mods, and the contents of overridesDir, if given, go under overrides/.
+func exportMrpack(cfg *Config, state State, packName, dest, overridesDir, loaderVersion, packVersion string) (*mrpackExport, error) {
+	packCfg := cfg.Modpacks[packName]
+	loaderKey, ok := mrpackLoaders[packCfg.Loader]
+	if !ok {
+		return nil, fmt.Errorf("loader %q can't be written to a .mrpack (want fabric, quilt, forge or neoforge)", packCfg.Loader)
+	}
+	if overridesDir != "" {
+		if info, err := os.Stat(overridesDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("--overrides %s is not a directory", overridesDir)
+		}
+	}
+
+	index := mrpackIndex{
+		FormatVersion: 1,
+		Game:          "minecraft",
+		VersionID:     packVersion,
+		Name:          packName,
+		Files:         []mrpackFile{},
+		Dependencies:  map[string]string{"minecraft": packCfg.MCVersion, loaderKey: loaderVersion},
+	}
+	if packCfg.JavaVersion > 0 {
+		// The format has no field for it, so launchers show it as part of the description
+		index.Summary = fmt.Sprintf("Requires Java %d", packCfg.JavaVersion)
+	}
+	result := &mrpackExport{}
+	dir := filepath.Join(modsDir, packName)
+	packState := state[packName]
+	listed := make(map[string]bool) // filenames covered by a download
+	mods := cfg.EffectiveMods(packCfg)
+	for _, slug := range append(mods, autoDeps(packState, mods)...) {
+		ms, ok := packState[slug]
+		if !ok || ms.SkipEnv != "" || ms.VersionID == "" {
+			continue // not installed, or picked up below as a local jar
+		}
+		file, env, err := mrpackDownload(slug, ms)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v; bundling the installed jar instead\n", slug, err)
+			continue
+		}
+		index.Files = append(index.Files, mrpackFile{
+			Path:      "mods/" + file.Filename,
+			Hashes:    map[string]string{"sha1": file.Hashes.SHA1, "sha512": file.Hashes.SHA512},
+			Env:       env,
+			Downloads: []string{file.URL},
+			FileSize:  file.Size,
+		})
+		listed[ms.Filename] = true
+		result.Downloads = append(result.Downloads, slug)
+	}
+
+	var locals []string
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if !e.IsDir() && strings.HasSuffix(strings.ToLower(e.Name()), ".jar") && !listed[e.Name()] {
+				locals = append(locals, e.Name())
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read mods directory %s: %w", dir, err)
+	}
+	sort.Strings(locals)
+
+	if dryRun {
+		result.LocalJars = locals
+		if overridesDir != "" {
+			result.Overrides, _ = countFiles(overridesDir)
+		}
+		return result, nil
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	zw := zip.NewWriter(out)
+	err = func() error {
+		w, err := zw.Create("modrinth.index.json")
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(index); err != nil {
+			return err
+		}
+		written := make(map[string]bool)
+		for _, name := range locals {
+			entry := "overrides/mods/" + name
+			if err := addZipFile(zw, entry, filepath.Join(dir, name)); err != nil {
+				return err
+			}
+			written[entry] = true
+			result.LocalJars = append(result.LocalJars, name)
+		}
+		if overridesDir == "" {
+			return nil
+		}
+		return filepath.WalkDir(overridesDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(overridesDir, p)
+			if err != nil {
+				return err
+			}
+			entry := path.Join("overrides", filepath.ToSlash(rel))
+			if written[entry] {
+				fmt.Fprintf(os.Stderr, "warning: %s is already bundled from the mods directory; skipping the copy in %s\n", entry, overridesDir)
+				return nil
+			}
+			written[entry] = true
+			result.Overrides++
+			return addZipFile(zw, entry, p)
+		})
+	}()
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return nil, fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return result, nil
+}
+
+// mrpackDownload looks up the Modrinth file behind a state entry and the environments the project
+// supports, in the index's terms
+func mrpackDownload(slug string, ms ModState) (*VersionFile, map[string]string, error) {
+	ver, err := FetchVersion(ms.VersionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var file *VersionFile
+	for i := range ver.Files {
+		if ver.Files[i].Filename == ms.Filename || (ms.SHA512 != "" && ver.Files[i].Hashes.SHA512 == ms.SHA512) {
+			file = &ver.Files[i]
+			break
+		}
+	}
+	if file == nil {
+		if file, err = ver.PrimaryFile(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if file.Hashes.SHA1 == "" || file.Hashes.SHA512 == "" {
+		return nil, nil, fmt.Errorf("version %s doesn't publish the hashes a .mrpack needs", ver.ID)
+	}
+	project, err := FetchProject(slug)
+	if err != nil {
+		return file, nil, nil // env is optional; launchers then install the mod everywhere
+	}
+	env := map[string]string{"client": mrpackEnv(project.ClientSide), "server": mrpackEnv(project.ServerSide)}
+	return file, env, nil
+}
+
+// mrpackEnv maps a project's client_side/server_side to the values the index allows
+func mrpackEnv(side string) string {
+	switch side {
+	case "required", "unsupported":
+		return side
+	}
+	return "optional"
+}
+
+// addZipFile copies the file at src into zw as name
+func addZipFile(zw *zip.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// countFiles counts the regular files under dir, for --dry-run summaries
+func countFiles(dir string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	return n, err
+}
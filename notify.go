@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Targets for check-updates --notify. auto posts to the config's notify_webhook when one is set
+// and falls back to a desktop notification otherwise.
+const (
+	notifyAuto    = "auto"
+	notifyWebhook = "webhook"
+	notifyDesktop = "desktop"
+	notifyAll     = "all"
+)
+
+// webhookTimeout bounds one webhook post, so an unreachable endpoint can't hang a cron job
+const webhookTimeout = 15 * time.Second
+
+// checkNotify validates --notify against the config before any work is done
+func checkNotify(mode string, cfg *Config) error {
+	switch mode {
+	case "", notifyAuto, notifyDesktop:
+		return nil
+	case notifyWebhook, notifyAll:
+		if cfg.NotifyWebhook == "" {
+			return fmt.Errorf("--notify %s needs notify_webhook set in the config", mode)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown --notify %q (want auto, webhook, desktop or all)", mode)
+}
+
+// errNoNotifier means this system has no desktop notifier to call
+var errNoNotifier = errors.New("no desktop notifier available")
+
+// notifyUpdates announces the updates check-updates found for packName through the targets mode
+// selects, and reports whether any notification went out. A bare --notify without a desktop
+// notifier (Windows, or no notify-send) only warns on stderr rather than failing the check.
+func notifyUpdates(cfg *Config, mode, packName string, pending []string) (bool, error) {
+	title := fmt.Sprintf("modpilot: %d update(s) for %s", len(pending), packName)
+	body := strings.Join(pending, "\n")
+	webhook := mode == notifyWebhook || mode == notifyAll || (mode == notifyAuto && cfg.NotifyWebhook != "")
+	desktop := mode == notifyDesktop || mode == notifyAll || (mode == notifyAuto && cfg.NotifyWebhook == "")
+
+	sent := false
+	var errs []error
+	if webhook {
+		if err := postWebhook(cfg.NotifyWebhook, title+"\n"+body); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		} else {
+			sent = true
+		}
+	}
+	if desktop {
+		err := desktopNotify(title, body)
+		switch {
+		case err == nil:
+			sent = true
+		case mode == notifyAuto && errors.Is(err, errNoNotifier):
+			fmt.Fprintf(os.Stderr, "warning: no desktop notification sent: %v\n", err)
+		default:
+			errs = append(errs, fmt.Errorf("desktop notification: %w", err))
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// postWebhook sends msg as JSON that both Discord ("content") and Slack ("text") webhooks accept
+func postWebhook(url, msg string) error {
+	payload, err := json.Marshal(map[string]string{"content": msg, "text": msg})
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: webhookTimeout}).Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
+
+// desktopNotify shows a notification with notify-send on Linux/BSD or osascript on macOS
+func desktopNotify(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		return fmt.Errorf("%w: not supported on Windows; set notify_webhook instead", errNoNotifier)
+	default:
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return fmt.Errorf("%w: notify-send not found; install libnotify or set notify_webhook instead", errNoNotifier)
+		}
+		cmd = exec.Command("notify-send", title, body)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
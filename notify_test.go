@@ -0,0 +1,22 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestNotifyWithoutDesktopNotifier(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("osascript is always available on macOS")
+	}
+	t.Setenv("PATH", t.TempDir()) // no notify-send
+	cfg := &Config{}
+
+	sent, err := notifyUpdates(cfg, notifyAuto, "MyPack", []string{"sodium"})
+	if err != nil || sent {
+		t.Errorf("bare --notify: sent = %v, err = %v; want a warning only", sent, err)
+	}
+	if _, err := notifyUpdates(cfg, notifyDesktop, "MyPack", []string{"sodium"}); err == nil {
+		t.Error("--notify=desktop without a notifier succeeded")
+	}
+}
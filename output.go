@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// streamOutput (--stream) writes the output of concurrent work as it happens instead of in blocks
+var streamOutput bool
+
+// outputMu serializes writes from outputBlocks, so neither a block nor a streamed line is split,
+// and keeps them from writing over the download progress line
+var outputMu sync.Mutex
+
+// outputBlock collects the lines of one unit of work that runs alongside others, such as one
+// pack's checks, and writes them to w as one contiguous block on Flush. Under --stream every
+// write goes straight through instead, interleaved with the other workers but in real time.
+type outputBlock struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func newOutputBlock(w io.Writer) *outputBlock {
+	return &outputBlock{w: w}
+}
+
+func (b *outputBlock) Write(p []byte) (int, error) {
+	if streamOutput {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		clearProgress()
+		defer drawProgress()
+		return b.w.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+// Flush writes out everything buffered so far
+func (b *outputBlock) Flush() error {
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	clearProgress()
+	defer drawProgress()
+	_, err := b.w.Write(b.buf.Bytes())
+	b.buf.Reset()
+	return err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// retryQueue lists, per pack, the mods whose last update failed. It lives in a small file next to
+// the state (state.pending.json for state.json) so transient failures are retried first on the
+// next update and drop out once they succeed.
+type retryQueue map[string]map[string]retryEntry // packName -> slug -> entry
+
+type retryEntry struct {
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+	Attempts int       `json:"attempts"` // failed runs in a row
+}
+
+// failedPacks lists the packs whose update failed in the last update-all run, kept in
+// state.failed-packs.json so update-all --retry-failed-only can rerun just those
+type failedPacks map[string]failedPack
+
+type failedPack struct {
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// sidecarPath is the file next to --state with the given suffix (state.<suffix>.json), or "" when
+// the state comes from stdin
+func sidecarPath(suffix string) string {
+	if stateFile == stdinPath {
+		return ""
+	}
+	return strings.TrimSuffix(stateFile, ".json") + "." + suffix + ".json"
+}
+
+// loadSidecar reads a sidecar file into v, treating a missing or unreadable file as empty
+func loadSidecar(path string, v any) {
+	if path == "" {
+		return
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, v); err != nil && verbose {
+			fmt.Printf("Warning: ignoring unreadable %s: %v\n", path, err)
+		}
+	}
+}
+
+// saveSidecar writes v to path, or removes the file when empty is set
+func saveSidecar(path string, v any, empty bool) error {
+	if path == "" || dryRun {
+		return nil
+	}
+	if empty {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false) // errors quote URLs
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// retryQueuePath is the queue file for --state, or "" when the state comes from stdin
+func retryQueuePath() string {
+	return sidecarPath("pending")
+}
+
+// loadRetryQueue reads the queue, treating a missing or unreadable file as empty
+func loadRetryQueue() retryQueue {
+	q := make(retryQueue)
+	loadSidecar(retryQueuePath(), &q)
+	return q
+}
+
+// loadFailedPacks reads the packs the last update-all run failed on
+func loadFailedPacks() failedPacks {
+	f := make(failedPacks)
+	loadSidecar(sidecarPath("failed-packs"), &f)
+	return f
+}
+
+// save writes the list, removing the file once every pack has succeeded
+func (f failedPacks) save() error {
+	return saveSidecar(sidecarPath("failed-packs"), f, len(f) == 0)
+}
+
+// save writes the queue, removing the file once nothing is pending
+func (q retryQueue) save() error {
+	for name, mods := range q {
+		if len(mods) == 0 {
+			delete(q, name)
+		}
+	}
+	return saveSidecar(retryQueuePath(), q, len(q) == 0)
+}
+
+// queuedMods returns the slugs of mods that are in the queue, in the pack's order
+func queuedMods(mods []string, queued map[string]retryEntry) []string {
+	var retry []string
+	for _, slug := range mods {
+		if _, ok := queued[slug]; ok {
+			retry = append(retry, slug)
+		}
+	}
+	return retry
+}
+
+// record updates packName's queue from an update run: failures are added or counted again,
+// mods that ended up installed or current are cleared, and mods no longer in the pack are dropped;
+// mods is everything the run checked, dependencies included.
+// With installed false (a staged run that was thrown away) nothing is cleared.
+func (q retryQueue) record(packName string, mods []string, report *UpdateReport, installed bool) {
+	queued := q[packName]
+	if queued == nil {
+		queued = make(map[string]retryEntry)
+		q[packName] = queued
+	}
+	for slug := range queued {
+		if !slices.Contains(mods, slug) {
+			delete(queued, slug)
+		}
+	}
+	for _, m := range report.Mods {
+		switch {
+		case m.Outcome == outcomeFailed:
+			queued[m.Slug] = retryEntry{Error: m.Error, FailedAt: report.Timestamp, Attempts: queued[m.Slug].Attempts + 1}
+		case installed && (m.Outcome == outcomeDownloaded || m.Outcome == outcomeUpToDate):
+			delete(queued, m.Slug)
+		}
+	}
+}
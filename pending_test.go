@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetryQueueRecord(t *testing.T) {
+	earlier := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now := earlier.Add(24 * time.Hour)
+	q := retryQueue{"MyPack": {
+		"sodium":  {Error: "timeout", FailedAt: earlier, Attempts: 2},
+		"iris":    {Error: "timeout", FailedAt: earlier, Attempts: 1},
+		"removed": {Error: "timeout", FailedAt: earlier, Attempts: 1},
+		"lithium": {Error: "timeout", FailedAt: earlier, Attempts: 1},
+	}}
+	report := &UpdateReport{Pack: "MyPack", Timestamp: now}
+	report.Add("sodium", "", "", outcomeFailed, errors.New("502 Bad Gateway"))
+	report.Add("iris", "old", "new", outcomeDownloaded, nil)
+	report.Add("lithium", "v1", "v1", outcomeUpToDate, nil)
+	report.Add("fabric-api", "", "", outcomeFailed, errors.New("404 Not Found"))
+
+	q.record("MyPack", []string{"sodium", "iris", "lithium", "fabric-api"}, report, true)
+	want := map[string]retryEntry{
+		"sodium":     {Error: "502 Bad Gateway", FailedAt: now, Attempts: 3},
+		"fabric-api": {Error: "404 Not Found", FailedAt: now, Attempts: 1},
+	}
+	if len(q["MyPack"]) != len(want) {
+		t.Errorf("queue = %+v, want %+v", q["MyPack"], want)
+	}
+	for slug, e := range want {
+		if q["MyPack"][slug] != e {
+			t.Errorf("%s queued as %+v, want %+v", slug, q["MyPack"][slug], e)
+		}
+	}
+
+	// A staged run that was thrown away clears nothing, but still counts failures
+	q = retryQueue{"MyPack": {"iris": {Error: "timeout", FailedAt: earlier, Attempts: 1}}}
+	q.record("MyPack", []string{"sodium", "iris"}, report, false)
+	if _, ok := q["MyPack"]["iris"]; !ok {
+		t.Error("iris cleared although its download was thrown away")
+	}
+	if q["MyPack"]["sodium"].Attempts != 1 {
+		t.Errorf("sodium queued as %+v, want a first failure", q["MyPack"]["sodium"])
+	}
+
+	// A pack seen for the first time gets its own queue
+	q = make(retryQueue)
+	q.record("Other", []string{"sodium"}, report, true)
+	if q["Other"]["sodium"].Attempts != 1 {
+		t.Errorf("new pack's queue = %+v", q["Other"])
+	}
+}
+
+func TestRetryQueueSave(t *testing.T) {
+	old := stateFile
+	stateFile = filepath.Join(t.TempDir(), "state.json")
+	t.Cleanup(func() { stateFile = old })
+
+	q := retryQueue{"MyPack": {"sodium": {Error: "timeout", FailedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), Attempts: 1}}, "Empty": {}}
+	if err := q.save(); err != nil {
+		t.Fatal(err)
+	}
+	got := loadRetryQueue()
+	if len(got) != 1 || got["MyPack"]["sodium"] != q["MyPack"]["sodium"] {
+		t.Errorf("loaded %+v, want %+v", got, q)
+	}
+	entries, err := os.ReadDir(filepath.Dir(stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "state.pending.json" {
+		t.Errorf("state dir holds %v, want just state.pending.json", entries)
+	}
+
+	// Once nothing is pending the file goes away
+	delete(q["MyPack"], "sodium")
+	if err := q.save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(retryQueuePath()); !os.IsNotExist(err) {
+		t.Errorf("queue file still there once empty: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Plan actions for a mod in an UpdatePlan
+const (
+	actionNone       = "none"
+	actionNew        = "new"
+	actionUpdate     = "update"
+	actionRedownload = "redownload"
+	actionFrozen     = "frozen"
+	actionSkipEnv    = "skip-env" // not needed in the --env being installed
+)
+
+// PlanEntry is the resolved outcome for one mod in an UpdatePlan
+type PlanEntry struct {
+	Slug          string   `json:"slug"`
+	Action        string   `json:"action,omitempty"`
+	From          string   `json:"from,omitempty"`
+	VersionID     string   `json:"version_id,omitempty"`
+	VersionNumber string   `json:"version_number,omitempty"`
+	MCVersion     string   `json:"mc_version,omitempty"` // set when --mc-version-range chose it
+	Filename      string   `json:"filename,omitempty"`
+	URL           string   `json:"url,omitempty"`
+	Size          int64    `json:"size,omitempty"`
+	SHA512        string   `json:"sha512,omitempty"`
+	RequiredBy    []string `json:"required_by,omitempty"` // for dependencies the pack doesn't list
+	Error         string   `json:"error,omitempty"`
+}
+
+// UpdatePlan is what update --resolve-only emits: every mod's chosen version, without downloading
+// anything. Saved with --plan-out, update --from-plan applies exactly these downloads later.
+type UpdatePlan struct {
+	Pack      string      `json:"pack"`
+	MCVersion string      `json:"mc_version"`
+	Loader    string      `json:"loader"`
+	Mods      []PlanEntry `json:"mods"`
+}
+
+// WriteJSON encodes the plan as indented JSON
+func (p *UpdatePlan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// Save writes the plan to path as JSON, for update --from-plan
+func (p *UpdatePlan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadPlan reads a plan saved with update --plan-out
+func LoadPlan(path string) (*UpdatePlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p UpdatePlan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if p.Pack == "" {
+		return nil, fmt.Errorf("invalid plan %s: no pack", path)
+	}
+	return &p, nil
+}
+
+// Pending counts the mods the plan would download
+func (p *UpdatePlan) Pending() int {
+	n := 0
+	for _, e := range p.Mods {
+		if e.Error == "" && (e.Action == actionNew || e.Action == actionUpdate || e.Action == actionRedownload) {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText prints the plan in a human-readable form, listing mods that need action first
+func (p *UpdatePlan) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Resolved plan for %s (MC: %s, Loader: %s):\n", p.Pack, p.MCVersion, p.Loader)
+	pending, current, skipped, failed := 0, 0, 0, 0
+	byAction := make(map[string]int)
+	for _, e := range p.Mods {
+		switch {
+		case e.Error != "":
+			failed++
+		case e.Action == actionNone || e.Action == actionFrozen:
+			current++
+		case e.Action == actionSkipEnv:
+			skipped++
+		default:
+			pending++
+			byAction[e.Action]++
+			from := showVersionID(e.From)
+			if from == "" {
+				from = "-"
+			}
+			fmt.Fprintf(w, "  %-10s %s: %s → %s (%s, %d bytes)\n  %10s %s\n", e.Action, e.Slug, from, showVersion(e.VersionNumber, e.VersionID), e.Filename, e.Size, "", e.URL)
+		}
+	}
+	for _, e := range p.Mods {
+		if e.Error != "" {
+			fmt.Fprintf(w, "  ✗ %s: %s\n", e.Slug, e.Error)
+		}
+	}
+	fmt.Fprintf(w, "\n%d to download (%d new, %d updated, %d redownloaded), %d up to date or frozen, %d unresolved.\n",
+		pending, byAction[actionNew], byAction[actionUpdate], byAction[actionRedownload], current, failed)
+	if skipped > 0 {
+		fmt.Fprintf(w, "%d not needed in this environment.\n", skipped)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quiet (--quiet) turns off the download progress line
+var quiet bool
+
+// progressInterval is how often the progress line is redrawn at most
+const progressInterval = 100 * time.Millisecond
+
+// progressWidth caps the progress line so it fits a narrow terminal without wrapping
+const progressWidth = 79
+
+// downloadProgress is the status line of the downloads in flight, drawn in place on stderr: bytes
+// and files done across the whole run plus each active file's percentage. Lines other output
+// writes through outputBlock clear it first and redraw it after, under outputMu.
+type downloadProgress struct {
+	mu       sync.Mutex
+	files    int
+	finished int
+	total    int64 // expected bytes of the run; 0 when unknown
+	done     int64
+	active   []*fileProgress
+	lastDraw time.Time
+	drawn    bool
+	single   bool // started for one download outside runDownloads; ends with it
+}
+
+// fileProgress is one download's share of the progress line
+type fileProgress struct {
+	status *downloadProgress
+	name   string
+	got    int64
+	size   int64 // from Content-Length; -1 when the server sent none
+}
+
+// activeProgress is the progress line currently shown, nil when none is
+var activeProgress *downloadProgress
+
+// progressShown reports whether downloads show a progress line: not under --quiet, and only when
+// stderr is a terminal, so logs and CI output stay clean
+func progressShown() bool {
+	if quiet {
+		return false
+	}
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startProgress shows a progress line for a run of files downloads totalling total bytes. The
+// returned func removes it again.
+func startProgress(files int, total int64) func() {
+	if !progressShown() {
+		return func() {}
+	}
+	outputMu.Lock()
+	activeProgress = &downloadProgress{files: files, total: total}
+	outputMu.Unlock()
+	return stopProgress
+}
+
+func stopProgress() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	clearProgress()
+	activeProgress = nil
+}
+
+// trackDownload registers a download of size bytes (-1 if unknown) with the progress line,
+// starting a line of its own when none is shown; nil means progress isn't shown
+func trackDownload(name string, size int64) *fileProgress {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if activeProgress == nil {
+		if !progressShown() {
+			return nil
+		}
+		activeProgress = &downloadProgress{files: 1, total: max(size, 0), single: true}
+	}
+	p := activeProgress
+	f := &fileProgress{status: p, name: name, size: size}
+	p.mu.Lock()
+	p.active = append(p.active, f)
+	p.mu.Unlock()
+	return f
+}
+
+// Write counts downloaded bytes, for use with io.TeeReader
+func (f *fileProgress) Write(b []byte) (int, error) {
+	p := f.status
+	p.mu.Lock()
+	f.got += int64(len(b))
+	p.done += int64(len(b))
+	due := time.Since(p.lastDraw) >= progressInterval
+	p.mu.Unlock()
+	if due {
+		outputMu.Lock()
+		drawProgress()
+		outputMu.Unlock()
+	}
+	return len(b), nil
+}
+
+// finish takes the download off the line. A failed download's bytes stay counted, as the
+// run's total still includes its size.
+func (f *fileProgress) finish() {
+	if f == nil {
+		return
+	}
+	p := f.status
+	p.mu.Lock()
+	p.finished++
+	for i, a := range p.active {
+		if a == f {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			break
+		}
+	}
+	p.mu.Unlock()
+	if p.single {
+		stopProgress()
+		return
+	}
+	outputMu.Lock()
+	drawProgress()
+	outputMu.Unlock()
+}
+
+// line renders the progress line, e.g.
+// "  ⇣ 5/23 files · 412.3 MiB of 1.2 GiB (34%) · sodium-0.6.0.jar 45%"
+func (p *downloadProgress) line() string {
+	parts := []string{}
+	if p.files > 1 {
+		parts = append(parts, fmt.Sprintf("%d/%d files", p.finished, p.files))
+	}
+	if p.total > 0 {
+		parts = append(parts, fmt.Sprintf("%s of %s (%d%%)", humanSize(p.done), humanSize(p.total), min(100, p.done*100/p.total)))
+	} else {
+		parts = append(parts, humanSize(p.done))
+	}
+	for _, f := range p.active {
+		if f.size > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d%%", f.name, min(100, f.got*100/f.size)))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s %s", f.name, humanSize(f.got)))
+		}
+	}
+	line := []rune("  ⇣ " + strings.Join(parts, " · "))
+	if len(line) > progressWidth {
+		line = append(line[:progressWidth-1], '…')
+	}
+	return string(line)
+}
+
+// drawProgress redraws the progress line in place; callers hold outputMu
+func drawProgress() {
+	p := activeProgress
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	line := p.line()
+	p.lastDraw = time.Now()
+	p.drawn = true
+	p.mu.Unlock()
+	fmt.Fprint(os.Stderr, "\r\033[K"+line)
+}
+
+// clearProgress erases the progress line so other output starts on a clean line; callers hold outputMu
+func clearProgress() {
+	p := activeProgress
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// printAboveProgress writes a message to stderr on a line of its own, above the progress line if
+// one is shown, for warnings raised while downloads are in flight
+func printAboveProgress(format string, args ...any) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	clearProgress()
+	fmt.Fprintf(os.Stderr, format, args...)
+	drawProgress()
+}
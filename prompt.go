@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// readLine reads one answer from reader, trimmed. When input ends (piped, redirected from
+// /dev/null or closed) it finishes the prompt's line and reports eof, so callers take the
+// prompt's default instead of asking again.
+func readLine(reader *bufio.Reader) (line string, eof bool) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Println()
+		return strings.TrimSpace(line), true
+	}
+	return strings.TrimSpace(line), false
+}
+
+// askYesNo prints prompt and reports whether the answer was yes. Anything else, including
+// empty input or EOF, is the documented default of no.
+func askYesNo(reader *bufio.Reader, prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := readLine(reader)
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
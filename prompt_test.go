@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+		eof   bool
+	}{
+		{"", "", true},
+		{"fabric\n", "fabric", false},
+		{"  1.21.1  \r\n", "1.21.1", false},
+		{"forge", "forge", true}, // a last line without a newline is still read
+	}
+	for _, tt := range tests {
+		line, eof := readLine(bufio.NewReader(strings.NewReader(tt.input)))
+		if line != tt.line || eof != tt.eof {
+			t.Errorf("readLine(%q) = %q, %v; want %q, %v", tt.input, line, eof, tt.line, tt.eof)
+		}
+	}
+}
+
+func TestAskYesNo(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"", false}, // EOF takes the default of no
+		{"\n", false},
+		{"y\n", true},
+		{"YES\n", true},
+		{"yes", true},
+		{"nope\n", false},
+	}
+	for _, tt := range tests {
+		if got := askYesNo(bufio.NewReader(strings.NewReader(tt.input)), "Continue? "); got != tt.want {
+			t.Errorf("askYesNo with input %q = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
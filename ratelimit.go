@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxJitter is the upper bound of the random pause added before each request
+const maxJitter = 50 * time.Millisecond
+
+// tokenBucket is a goroutine-safe token-bucket limiter for outgoing requests
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // bucket capacity
+	tokens float64 // may go negative while callers hold reservations
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket allowing qps requests per second
+func newTokenBucket(qps float64) *tokenBucket {
+	burst := max(1, qps)
+	return &tokenBucket{rate: qps, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait blocks until the caller may send a request
+func (b *tokenBucket) Wait() {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	var wait time.Duration
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens-- // reserve our token now so concurrent callers queue up behind us
+	b.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// apiLimiter is shared by every request made through throttle; nil disables limiting
+var apiLimiter *tokenBucket
+
+// setRateLimit configures the shared limiter; qps <= 0 turns it off
+func setRateLimit(qps float64) {
+	if qps <= 0 {
+		apiLimiter = nil
+		return
+	}
+	apiLimiter = newTokenBucket(qps)
+}
+
+// throttle waits for the shared limiter and a small random jitter before a request
+func throttle() {
+	if apiLimiter == nil {
+		return
+	}
+	apiLimiter.Wait()
+	time.Sleep(rand.N(maxJitter))
+}
+
+// Modrinth reports its own per-minute limit on every API response: X-Ratelimit-Remaining requests
+// are left in the window, which resets X-Ratelimit-Reset seconds later. apiWindow tracks that so
+// API requests pause for the reset instead of running into 429s, also while --qps is off.
+var apiWindow struct {
+	mu        sync.Mutex
+	known     bool
+	remaining int // requests left, counting down as requests are sent between responses
+	reset     time.Time
+	announced bool // the wait for this window has been reported
+}
+
+// observeRateLimit records the window a Modrinth API response reports. A 429 means the window is
+// used up whatever the headers say.
+func observeRateLimit(resp *http.Response) {
+	remaining, errR := strconv.Atoi(resp.Header.Get("X-Ratelimit-Remaining"))
+	resetSecs, errS := strconv.Atoi(resp.Header.Get("X-Ratelimit-Reset"))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		remaining, errR = 0, nil
+	}
+	if errR != nil || errS != nil {
+		return
+	}
+	apiWindow.mu.Lock()
+	defer apiWindow.mu.Unlock()
+	reset := time.Now().Add(time.Duration(resetSecs) * time.Second)
+	if !apiWindow.known || reset.After(apiWindow.reset.Add(time.Second)) {
+		apiWindow.announced = false // a new window
+	}
+	apiWindow.known, apiWindow.remaining, apiWindow.reset = true, remaining, reset
+}
+
+// rateLimitReset is how long until the API window resets, from a response's X-Ratelimit-Reset
+func rateLimitReset(resp *http.Response) (time.Duration, bool) {
+	secs, err := strconv.Atoi(resp.Header.Get("X-Ratelimit-Reset"))
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// waitAPIWindow blocks an API request while Modrinth's window has no requests left, until it resets
+func waitAPIWindow() {
+	for {
+		apiWindow.mu.Lock()
+		now := time.Now()
+		if !apiWindow.known || !now.Before(apiWindow.reset) {
+			apiWindow.known = false
+			apiWindow.mu.Unlock()
+			return
+		}
+		if apiWindow.remaining > 0 {
+			apiWindow.remaining-- // reserve a request so concurrent callers don't all spend the last one
+			apiWindow.mu.Unlock()
+			return
+		}
+		wait := apiWindow.reset.Sub(now)
+		if !apiWindow.announced {
+			apiWindow.announced = true
+			countRun(func(s *RunStats) { s.RateLimitWaits++ })
+			fmt.Fprintf(os.Stderr, "  ⏸ Modrinth's rate limit is used up; waiting %s for it to reset\n", wait.Round(time.Second))
+		}
+		apiWindow.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// limitedServer answers 429 once more than limit requests arrived in the last second, like an API
+// enforcing a per-second rate limit
+type limitedServer struct {
+	mu       sync.Mutex
+	limit    int
+	seen     []time.Time
+	rejected int
+}
+
+func (s *limitedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	recent := s.seen[:0]
+	for _, at := range s.seen {
+		if now.Sub(at) < time.Second {
+			recent = append(recent, at)
+		}
+	}
+	s.seen = append(recent, now)
+	if len(s.seen) > s.limit {
+		s.rejected++
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	w.Write([]byte("[]"))
+}
+
+// burst sends requests API calls from workers goroutines at once and returns how many got a 429
+func burst(t *testing.T, srv *limitedServer, url string, requests, workers int) int {
+	t.Helper()
+	jobs := make(chan struct{}, requests)
+	for range requests {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				resp, err := apiGet(url, nil)
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.rejected
+}
+
+func TestTokenBucketAvoidsRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("sends requests for a couple of seconds")
+	}
+	const qps, requests, workers = 20, 50, 8
+	// The bucket lets through at most its burst plus qps requests in any one second
+	limit := 2*qps + 5
+
+	unlimited := &limitedServer{limit: limit}
+	srv := newTestAPI(t, unlimited)
+	if got := burst(t, unlimited, srv.URL+"/v2/project/a/version", requests, workers); got == 0 {
+		t.Fatalf("without a limiter no request was rejected; the server's limit of %d/s is too loose to test against", limit)
+	}
+
+	limited := &limitedServer{limit: limit}
+	srv = newTestAPI(t, limited)
+	setRateLimit(qps)
+	start := time.Now()
+	if got := burst(t, limited, srv.URL+"/v2/project/a/version", requests, workers); got != 0 {
+		t.Errorf("with --qps %d, %d of %d requests got 429 from a server allowing %d/s", qps, got, requests, limit)
+	}
+	// The first burst goes out at once and the rest at qps
+	if want := time.Duration(requests-qps) * time.Second / qps; time.Since(start) < want*9/10 {
+		t.Errorf("%d requests at --qps %d took %s, want at least %s", requests, qps, time.Since(start), want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// filenameFix is a jar reconcile-filenames renamed (or only re-recorded, when From equals To)
+type filenameFix struct {
+	Slug string
+	From string
+	To   string
+}
+
+// urlFilename is the file name at the end of a download URL, decoded, so a URL ending in
+// Mod%2B1.0.jar names Mod+1.0.jar as Modrinth reports it
+func urlFilename(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return path.Base(rawURL)
+	}
+	return path.Base(u.Path)
+}
+
+// reconcileFilenames renames the jars of packState in dir to the filename Modrinth reports for the
+// version state records, for files saved under another name such as an older release's URL
+// basename (still URL-encoded, say). Files are matched by their SHA-512 rather than by name, the
+// --filename-collision-policy decides what happens when the right name belongs to another file,
+// and state is updated to match. Mods whose file can't be found are left for update to redownload.
+func reconcileFilenames(dir string, packState map[string]ModState) (fixed []filenameFix, failed map[string]error, err error) {
+	failed = make(map[string]error)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	var jars []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".jar") {
+			jars = append(jars, filepath.Join(dir, e.Name()))
+		}
+	}
+	byHash := make(map[string]string) // SHA-512 -> file name in dir
+	for p, sum := range hashFiles(jars) {
+		byHash[sum] = filepath.Base(p)
+	}
+
+	for _, slug := range sortedKeys(packState) {
+		ms := packState[slug]
+		if ms.SkipEnv != "" || ms.VersionID == "" {
+			continue
+		}
+		ver, err := FetchVersion(ms.VersionID)
+		if err != nil {
+			failed[slug] = err
+			continue
+		}
+		file, err := ver.PrimaryFile()
+		if err != nil {
+			failed[slug] = err
+			continue
+		}
+		sum := ms.SHA512
+		if sum == "" {
+			sum = file.Hashes.SHA512
+		}
+		current, ok := byHash[sum]
+		if !ok || sum == "" {
+			failed[slug] = fmt.Errorf("no file in %s matches version %s; run update to redownload it", dir, showInstalled(ms))
+			continue
+		}
+		// The prefix-slug name is where a collision left the file, which counts as correct
+		if current == file.Filename || current == slug+"-"+file.Filename {
+			if current != ms.Filename || ms.SHA512 == "" {
+				fixed = append(fixed, filenameFix{Slug: slug, From: current, To: current})
+				ms.Filename, ms.SHA512 = current, sum
+				packState[slug] = ms
+			}
+			continue
+		}
+		name, err := targetFilename(dir, file.Filename, slug, current, sum)
+		if err != nil {
+			failed[slug] = err
+			continue
+		}
+		if !dryRun {
+			if err := os.Rename(filepath.Join(dir, current), filepath.Join(dir, name)); err != nil {
+				failed[slug] = fmt.Errorf("failed to rename %s to %s: %w", current, name, err)
+				continue
+			}
+		}
+		delete(byHash, sum)
+		fixed = append(fixed, filenameFix{Slug: slug, From: current, To: name})
+		ms.Filename, ms.SHA512 = name, sum
+		packState[slug] = ms
+	}
+	return fixed, failed, nil
+}
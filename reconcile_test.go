@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestURLFilename(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://cdn.modrinth.com/data/AANobbMI/versions/abc/Mod%2B1.0.jar", "Mod+1.0.jar"},
+		{"https://cdn.modrinth.com/data/AANobbMI/versions/abc/My%20Mod-1.0.jar", "My Mod-1.0.jar"},
+		{"https://cdn.modrinth.com/data/AANobbMI/versions/abc/mod-1.0.jar?token=x%2Fy&v=2", "mod-1.0.jar"},
+		{"https://example.com/files/mod-1.0.jar#sha512", "mod-1.0.jar"},
+		{"sodium-0.6.0.jar", "sodium-0.6.0.jar"},
+	}
+	for _, tt := range tests {
+		if got := urlFilename(tt.url); got != tt.want {
+			t.Errorf("urlFilename(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestReconcileFilenames(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("not really a jar")
+	sum := sha512.Sum512(content)
+	hash := hex.EncodeToString(sum[:])
+	// Saved under its URL-encoded download name
+	writeFile(t, filepath.Join(dir, "Mod%2B1.0.jar"), string(content))
+
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/version/abc" {
+			http.NotFound(w, r)
+			return
+		}
+		ver := Version{ID: "abc", VersionNumber: "1.0", Files: []VersionFile{{Filename: "Mod+1.0.jar", Primary: true}}}
+		ver.Files[0].Hashes.SHA512 = hash
+		json.NewEncoder(w).Encode(ver)
+	}))
+
+	packState := map[string]ModState{
+		"mod":  {VersionID: "abc", Filename: "Mod%2B1.0.jar"},
+		"gone": {VersionID: "missing", Filename: "gone-1.0.jar"},
+	}
+	fixed, failed, err := reconcileFilenames(dir, packState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 1 || fixed[0] != (filenameFix{Slug: "mod", From: "Mod%2B1.0.jar", To: "Mod+1.0.jar"}) {
+		t.Errorf("fixed = %+v, want mod renamed from Mod%%2B1.0.jar to Mod+1.0.jar", fixed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Mod+1.0.jar")); err != nil {
+		t.Errorf("the jar was not renamed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Mod%2B1.0.jar")); !os.IsNotExist(err) {
+		t.Errorf("the URL-encoded name is still there")
+	}
+	if ms := packState["mod"]; ms.Filename != "Mod+1.0.jar" || ms.SHA512 != hash {
+		t.Errorf("state recorded %+v after the rename", ms)
+	}
+	if _, ok := failed["gone"]; !ok || len(failed) != 1 {
+		t.Errorf("failed = %v, want only the mod whose version can't be found", failed)
+	}
+
+	// A second run finds nothing left to do
+	fixed, _, err = reconcileFilenames(dir, map[string]ModState{"mod": packState["mod"]})
+	if err != nil || len(fixed) != 0 {
+		t.Errorf("second run fixed %+v, %v; want nothing", fixed, err)
+	}
+}
+
+func TestRepairFilenameWithoutHash(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("an old install")
+	sum := sha512.Sum512(content)
+	hash := hex.EncodeToString(sum[:])
+	writeFile(t, filepath.Join(dir, "sodium-renamed.jar"), string(content))
+
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ver := Version{ID: "abc", VersionNumber: "0.6.0", Files: []VersionFile{{Filename: "sodium-0.6.0.jar", Primary: true}}}
+		ver.Files[0].Hashes.SHA512 = hash
+		json.NewEncoder(w).Encode(ver)
+	}))
+
+	// Saved before state kept hashes: the version's published hash finds the file
+	found, err := repairFilename(dir, ModState{VersionID: "abc", Filename: "sodium-0.6.0.jar"})
+	if err != nil || found != "sodium-renamed.jar" {
+		t.Fatalf("repairFilename = %q, %v; want sodium-renamed.jar", found, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sodium-0.6.0.jar")); err != nil {
+		t.Errorf("the jar was not renamed: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outcomes recorded for a mod in an UpdateReport
+const (
+	outcomeUpToDate   = "up-to-date"
+	outcomeDownloaded = "downloaded"
+	outcomeSkipped    = "skipped"
+	outcomeFailed     = "failed"
+	outcomeDryRun     = "dry-run" // would have downloaded
+	outcomeFrozen     = "frozen"
+	outcomePinned     = "pinned" // pinned during an --interactive run instead of updating
+)
+
+// ModReport records what update did with a single mod
+type ModReport struct {
+	Slug    string `json:"slug"`
+	From    string `json:"from,omitempty"` // version ID before the run
+	To      string `json:"to,omitempty"`   // version ID after the run
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// UpdateReport is the summary written by update --report
+type UpdateReport struct {
+	Pack      string      `json:"pack"`
+	MCVersion string      `json:"mc_version"`
+	Loader    string      `json:"loader"`
+	Timestamp time.Time   `json:"timestamp"`
+	Mods      []ModReport `json:"mods"`
+}
+
+// Add appends a mod's result, storing err's message if non-nil
+func (r *UpdateReport) Add(slug, from, to, outcome string, err error) {
+	m := ModReport{Slug: slug, From: from, To: to, Outcome: outcome}
+	if err != nil {
+		m.Error = err.Error()
+	}
+	r.Mods = append(r.Mods, m)
+}
+
+// Summary counts the report's outcomes, e.g. "2 downloaded, 14 up-to-date, 1 failed"
+func (r *UpdateReport) Summary() string {
+	counts := make(map[string]int)
+	for _, m := range r.Mods {
+		counts[m.Outcome]++
+	}
+	var parts []string
+	for _, outcome := range []string{outcomeDownloaded, outcomeDryRun, outcomeUpToDate, outcomePinned, outcomeFrozen, outcomeSkipped, outcomeFailed} {
+		if counts[outcome] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[outcome], outcome))
+		}
+	}
+	if len(parts) == 0 {
+		return "no mods checked"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Write saves the report to path, as Markdown for .md/.markdown and JSON otherwise
+func (r *UpdateReport) Write(path string) error {
+	var data []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		data = []byte(r.markdown())
+	default:
+		var err error
+		if data, err = json.MarshalIndent(r, "", "  "); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (r *UpdateReport) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Update report: %s\n\n", r.Pack)
+	fmt.Fprintf(&b, "- Minecraft: %s\n- Loader: %s\n- Time: %s\n\n", r.MCVersion, r.Loader, r.Timestamp.Format(time.RFC3339))
+	b.WriteString("| Mod | Before | After | Outcome | Error |\n")
+	b.WriteString("|-----|--------|-------|---------|-------|\n")
+	for _, m := range r.Mods {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", m.Slug, m.From, m.To, m.Outcome, strings.ReplaceAll(m.Error, "|", "\\|"))
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Values of --stats; a bare --stats means text
+const (
+	runStatsText = "text"
+	runStatsJSON = "json"
+)
+
+// runStatsMode (--stats) prints the run's network totals when the command ends; -v prints them as text
+var runStatsMode string
+
+// RunStats counts one command's traffic to Modrinth, printed by --stats
+type RunStats struct {
+	APIRequests     int     `json:"api_requests"` // sent to the API, retries included
+	Retries         int     `json:"retries"`
+	CacheHits       int     `json:"cache_hits"`
+	CacheMisses     int     `json:"cache_misses"`
+	Revalidated     int     `json:"cache_revalidated"` // stale entries the API confirmed with a 304
+	Downloads       int     `json:"downloads"`
+	DownloadedBytes int64   `json:"downloaded_bytes"`
+	RateLimitWaits  int     `json:"rate_limit_waits"` // pauses for Modrinth's rate-limit window to reset
+	Seconds         float64 `json:"seconds"`
+}
+
+var (
+	runStats   RunStats
+	runStatsMu sync.Mutex
+	runStart   = time.Now()
+)
+
+// countRun applies f to this run's stats
+func countRun(f func(s *RunStats)) {
+	runStatsMu.Lock()
+	defer runStatsMu.Unlock()
+	f(&runStats)
+}
+
+// checkStatsMode validates --stats
+func checkStatsMode(mode string) error {
+	switch mode {
+	case "", runStatsText, runStatsJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown --stats %q (want text or json)", mode)
+}
+
+// printRunStats writes the run's stats to w under --stats or -v, as one line or a JSON object
+func printRunStats(w io.Writer) {
+	mode := runStatsMode
+	if mode == "" && verbose {
+		mode = runStatsText
+	}
+	if mode == "" {
+		return
+	}
+	runStatsMu.Lock()
+	s := runStats
+	runStatsMu.Unlock()
+	s.Seconds = time.Since(runStart).Round(time.Millisecond).Seconds()
+	if mode == runStatsJSON {
+		data, _ := json.MarshalIndent(s, "", "  ")
+		fmt.Fprintln(w, string(data))
+		return
+	}
+	fmt.Fprintf(w, "Run stats: %d API request(s) (%d retries), cache %d hit(s) / %d miss(es) / %d revalidated, %d download(s) (%s), %d rate-limit wait(s), %.1fs\n",
+		s.APIRequests, s.Retries, s.CacheHits, s.CacheMisses, s.Revalidated, s.Downloads, humanSize(s.DownloadedBytes), s.RateLimitWaits, s.Seconds)
+}
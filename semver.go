@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semver is a leniently parsed version_number: numeric core plus optional pre-release identifiers
+type semver struct {
+	core []int
+	pre  []string
+}
+
+var semverRe = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(?:-([0-9A-Za-z.-]+))?(?:\+.*)?$`)
+
+// parseSemver parses strings like "1.2", "v0.5.1-beta.2" or "3.0.0+mc1.20.1".
+// Build metadata after "+" is ignored. It reports false for anything else.
+func parseSemver(s string) (semver, bool) {
+	m := semverRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return semver{}, false
+	}
+	var v semver
+	for _, part := range strings.Split(m[1], ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, false
+		}
+		v.core = append(v.core, n)
+	}
+	if m[2] != "" {
+		v.pre = strings.Split(m[2], ".")
+	}
+	return v, true
+}
+
+// compareSemver returns -1, 0 or 1 as a is lower than, equal to or higher than b.
+// Missing core components count as 0, and a pre-release sorts below its release.
+func compareSemver(a, b semver) int {
+	for i := 0; i < max(len(a.core), len(b.core)); i++ {
+		var x, y int
+		if i < len(a.core) {
+			x = a.core[i]
+		}
+		if i < len(b.core) {
+			y = b.core[i]
+		}
+		if x != y {
+			return cmpInt(x, y)
+		}
+	}
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < min(len(a.pre), len(b.pre)); i++ {
+		x, xErr := strconv.Atoi(a.pre[i])
+		y, yErr := strconv.Atoi(b.pre[i])
+		switch {
+		case xErr == nil && yErr == nil:
+			if x != y {
+				return cmpInt(x, y)
+			}
+		case xErr == nil: // numeric identifiers sort below alphanumeric ones
+			return -1
+		case yErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(a.pre[i], b.pre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmpInt(len(a.pre), len(b.pre))
+}
+
+func cmpInt(x, y int) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// versionAhead reports whether installed is a later release than latest: by version_number when both
+// parse, by publish date otherwise
+func versionAhead(installed, latest *Version) bool {
+	a, aOK := parseSemver(installed.VersionNumber)
+	b, bOK := parseSemver(latest.VersionNumber)
+	if aOK && bOK {
+		return compareSemver(a, b) > 0
+	}
+	return installed.DatePublished.After(latest.DatePublished)
+}
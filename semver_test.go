@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVersionAhead(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name              string
+		installed, latest Version
+		want              bool
+	}{
+		{"installed newer by number", Version{VersionNumber: "2.1.0", DatePublished: day(1)}, Version{VersionNumber: "2.0.3", DatePublished: day(9)}, true},
+		{"installed older by number", Version{VersionNumber: "1.9.0", DatePublished: day(9)}, Version{VersionNumber: "2.0.0", DatePublished: day(1)}, false},
+		{"same number", Version{VersionNumber: "2.0.0", DatePublished: day(9)}, Version{VersionNumber: "2.0.0", DatePublished: day(1)}, false},
+		{"unparsable, installed published later", Version{VersionNumber: "build-b", DatePublished: day(9)}, Version{VersionNumber: "build-a", DatePublished: day(1)}, true},
+		{"unparsable, installed published earlier", Version{VersionNumber: "build-a", DatePublished: day(1)}, Version{VersionNumber: "build-b", DatePublished: day(9)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionAhead(&tt.installed, &tt.latest); got != tt.want {
+				t.Errorf("versionAhead(%s, %s) = %v, want %v", tt.installed.VersionNumber, tt.latest.VersionNumber, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstalledAhead(t *testing.T) {
+	latest := Version{ID: "rel", VersionNumber: "1.4.0", VersionType: "release"}
+	beta := Version{ID: "beta", VersionNumber: "1.5.0-beta.2", VersionType: "beta"}
+	old := Version{ID: "old", VersionNumber: "1.3.0", VersionType: "release"}
+	versions := []Version{beta, latest, old}
+
+	// A beta installed by hand on a release-channel pack is ahead of the latest release
+	if !installedAhead(versions, "beta", &latest) {
+		t.Error("installed 1.5.0-beta.2 is not ahead of latest compatible 1.4.0")
+	}
+	if installedAhead(versions, "old", &latest) {
+		t.Error("installed 1.3.0 is ahead of latest compatible 1.4.0")
+	}
+	if installedAhead(versions, "rel", &latest) {
+		t.Error("the latest version is ahead of itself")
+	}
+
+	// A version missing from the list is looked up by ID
+	newTestAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/version/dev" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(Version{ID: "dev", VersionNumber: "1.6.0"})
+	}))
+	if !installedAhead(versions, "dev", &latest) {
+		t.Error("installed 1.6.0, fetched by ID, is not ahead of latest compatible 1.4.0")
+	}
+	if installedAhead(versions, "gone", &latest) {
+		t.Error("a version that can't be looked up counts as ahead")
+	}
+}
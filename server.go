@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// apiServer serves the HTTP API started by the serve command. Config and state are reloaded
+// on every request, so edits made with the CLI while it runs are picked up.
+type apiServer struct {
+	token string
+	mu    sync.Mutex // one update at a time, since each rewrites the state file
+}
+
+// packSummary is one entry of GET /api/packs
+type packSummary struct {
+	Name      string `json:"name"`
+	MCVersion string `json:"mc_version"`
+	Loader    string `json:"loader"`
+	Java      int    `json:"java_version,omitempty"`
+	Mods      int    `json:"mods"`
+	Active    bool   `json:"active,omitempty"`
+	Archived  bool   `json:"archived,omitempty"`
+}
+
+// modSummary is one entry of GET /api/packs/{pack}/mods
+type modSummary struct {
+	Slug      string `json:"slug"`
+	Note      string `json:"note,omitempty"`
+	VersionID string `json:"version_id,omitempty"`
+	Filename  string `json:"filename,omitempty"`
+	Pinned    string `json:"pinned,omitempty"`
+	Frozen    bool   `json:"frozen,omitempty"`
+}
+
+// modCheck is one entry of GET /api/packs/{pack}/check
+type modCheck struct {
+	Slug      string `json:"slug"`
+	Status    string `json:"status"` // up-to-date, outdated, new, missing, frozen, skipped or error
+	Installed string `json:"installed,omitempty"`
+	Target    string `json:"target,omitempty"` // latest compatible version, or the pin
+	Error     string `json:"error,omitempty"`
+}
+
+// listenAddr resolves --addr, binding to localhost when no host is given and refusing other
+// interfaces unless allowRemote is set
+func listenAddr(addr string, allowRemote bool) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid --addr %q: %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if ip := net.ParseIP(host); (ip == nil || !ip.IsLoopback()) && host != "localhost" && !allowRemote {
+		return "", fmt.Errorf("refusing to listen on %s; pass --allow-remote to expose the API beyond localhost", host)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// newServeToken returns a random token for mutating requests when none was configured
+func newServeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/packs", s.handlePacks)
+	mux.HandleFunc("GET /api/packs/{pack}/mods", s.handleMods)
+	mux.HandleFunc("GET /api/packs/{pack}/check", s.handleCheck)
+	mux.HandleFunc("POST /api/packs/{pack}/update", s.requireToken(s.handleUpdate))
+	return mux
+}
+
+// requireToken rejects requests without "Authorization: Bearer <token>"
+func (s *apiServer) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, fmt.Errorf("missing or wrong bearer token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// loadPack reads the config and state and looks up the request's pack, answering with an error itself if that fails
+func loadPack(w http.ResponseWriter, r *http.Request) (*Config, State, string, bool) {
+	cfg, err := LoadConfig(cfgFile)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return nil, nil, "", false
+	}
+	name := r.PathValue("pack")
+	if _, ok := cfg.Modpacks[name]; !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("modpack %q not found", name))
+		return nil, nil, "", false
+	}
+	state, err := LoadState(stateFile)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return nil, nil, "", false
+	}
+	return cfg, state, name, true
+}
+
+func (s *apiServer) handlePacks(w http.ResponseWriter, r *http.Request) {
+	cfg, err := LoadConfig(cfgFile)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	packs := []packSummary{}
+	for _, name := range sortedPackNames(cfg) {
+		p := cfg.Modpacks[name]
+		packs = append(packs, packSummary{Name: name, MCVersion: p.MCVersion, Loader: p.Loader, Java: p.JavaVersion, Mods: len(cfg.EffectiveMods(p)), Active: name == cfg.ActivePack, Archived: p.Archived})
+	}
+	writeJSON(w, packs)
+}
+
+func (s *apiServer) handleMods(w http.ResponseWriter, r *http.Request) {
+	cfg, state, name, ok := loadPack(w, r)
+	if !ok {
+		return
+	}
+	packCfg := cfg.Modpacks[name]
+	mods := []modSummary{}
+	for _, slug := range cfg.EffectiveMods(packCfg) {
+		m := modSummary{Slug: slug, Pinned: packCfg.Pins[slug], Frozen: slices.Contains(packCfg.Frozen, slug)}
+		if i := packCfg.Entry(slug); i >= 0 {
+			m.Note = packCfg.Mods[i].Note
+		}
+		if ms, ok := state[name][slug]; ok {
+			m.VersionID, m.Filename = ms.VersionID, ms.Filename
+		}
+		mods = append(mods, m)
+	}
+	writeJSON(w, mods)
+}
+
+// checkStatus is what GET /api/packs/{pack}/check calls each plan action
+var checkStatus = map[string]string{
+	actionNone:       "up-to-date",
+	actionNew:        "new",
+	actionUpdate:     "outdated",
+	actionRedownload: "missing",
+	actionFrozen:     "frozen",
+	actionSkipEnv:    "skipped",
+}
+
+func (s *apiServer) handleCheck(w http.ResponseWriter, r *http.Request) {
+	cfg, state, name, ok := loadPack(w, r)
+	if !ok {
+		return
+	}
+	packCfg := cfg.Modpacks[name]
+	if state[name] == nil {
+		state[name] = make(map[string]ModState)
+	}
+	u := newPackUpdate(name, packCfg, packCfg.MCVersion, packCfg.Loader, state[name], cfg.EffectiveMods(packCfg), io.Discard)
+	u.readOnly = true
+	u.prefetch()
+	checks := []modCheck{}
+	for i := 0; i < len(u.mods); i++ {
+		m := u.check(u.mods[i])
+		c := modCheck{Slug: m.Slug, Installed: m.From, Target: m.VersionID, Status: checkStatus[m.Action], Error: m.Error}
+		if m.Error != "" {
+			c.Status = "error"
+		}
+		checks = append(checks, c)
+	}
+	writeJSON(w, checks)
+}
+
+// handleUpdate installs every mod that is new, outdated or missing, as update --yes does, streaming
+// one server-sent "mod" event per mod and a closing "done" event carrying the whole report
+func (s *apiServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg, state, name, ok := loadPack(w, r)
+	if !ok {
+		return
+	}
+	packCfg := cfg.Modpacks[name]
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(event string, v any) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	if state[name] == nil {
+		state[name] = make(map[string]ModState)
+	}
+	u := newPackUpdate(name, packCfg, packCfg.MCVersion, packCfg.Loader, state[name], cfg.EffectiveMods(packCfg), io.Discard)
+	if useStaging && !dryRun {
+		if err := u.startStaging(); err != nil {
+			send("error", map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	u.prefetch()
+	sent := 0
+	flush := func() {
+		for ; sent < len(u.report.Mods); sent++ {
+			send("mod", u.report.Mods[sent])
+		}
+	}
+	for i := 0; i < len(u.mods); i++ {
+		if r.Context().Err() != nil {
+			break // client went away; keep what was installed so far
+		}
+		if m := u.check(u.mods[i]); m.pending() {
+			u.queue(m)
+		}
+		flush()
+	}
+	u.download()
+	u.finish()
+	flush()
+	if err := u.finishStaging(); err != nil {
+		send("error", map[string]string{"error": err.Error()})
+		return
+	}
+	if u.changed {
+		if err := saveState(state); err != nil {
+			send("error", map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if u.stageErr != nil {
+		send("error", map[string]string{"error": u.stageErr.Error()})
+	}
+	send("done", u.report)
+}
+
+// resolveTarget returns the version slug should be on: its pin, or the latest compatible build
+func resolveTarget(slug string, packCfg ModpackConfig) (*Version, error) {
+	if pinID, pinned := packCfg.Pins[slug]; pinned {
+		return FetchVersion(pinID)
+	}
+	return FetchLatestVersionForChannel(slug, packCfg.MCVersion, packCfg.Loader, packCfg.ChannelFor(slug))
+}
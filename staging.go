@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// prepareStaging creates a fresh sibling of liveDir holding a copy of its current files.
+// Files are copied rather than hard-linked so redownloading a file in place can't touch the live copy.
+func prepareStaging(liveDir string) (string, error) {
+	stageDir := liveDir + ".staging"
+	if err := os.RemoveAll(stageDir); err != nil {
+		return "", fmt.Errorf("failed to clear old staging directory: %w", err)
+	}
+	if err := os.MkdirAll(stageDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	files, err := os.ReadDir(liveDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if err := copyFile(filepath.Join(liveDir, f.Name()), filepath.Join(stageDir, f.Name())); err != nil {
+			os.RemoveAll(stageDir)
+			return "", fmt.Errorf("failed to copy %s into staging: %w", f.Name(), err)
+		}
+	}
+	return stageDir, nil
+}
+
+// commitStaging swaps stageDir into liveDir's place. Each rename is atomic; the live
+// directory only goes missing for the instant between the two renames.
+func commitStaging(stageDir, liveDir string) error {
+	oldDir := liveDir + ".old"
+	if err := os.RemoveAll(oldDir); err != nil {
+		return err
+	}
+	if _, err := os.Stat(liveDir); err == nil {
+		if err := os.Rename(liveDir, oldDir); err != nil {
+			return fmt.Errorf("failed to move live directory aside: %w", err)
+		}
+	}
+	if err := os.Rename(stageDir, liveDir); err != nil {
+		// Put the live directory back so nothing is lost
+		os.Rename(oldDir, liveDir)
+		return fmt.Errorf("failed to move staging directory into place: %w", err)
+	}
+	return os.RemoveAll(oldDir)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
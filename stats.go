@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PackStats summarises one modpack for the stats command
+type PackStats struct {
+	Name      string `json:"name"`
+	MCVersion string `json:"mc_version"`
+	Loader    string `json:"loader"`
+	Java      int    `json:"java_version,omitempty"`
+	Mods      int    `json:"mods"`
+	Tracked   int    `json:"tracked"`             // mods with a state entry
+	Outdated  *int   `json:"outdated,omitempty"`  // nil when offline
+	Unchecked *int   `json:"unchecked,omitempty"` // mods whose online check failed
+	DiskBytes int64  `json:"disk_bytes"`
+}
+
+// FleetStats is the document printed by stats --json
+type FleetStats struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Offline     bool        `json:"offline"`
+	Packs       []PackStats `json:"packs"`
+}
+
+// gatherStats collects stats for the named packs, running the online checks concurrently unless offline
+func gatherStats(cfg *Config, state State, names []string, offline bool) *FleetStats {
+	fleet := &FleetStats{GeneratedAt: time.Now(), Offline: offline, Packs: make([]PackStats, len(names))}
+	sem := make(chan struct{}, networkWorkers())
+	var wg sync.WaitGroup
+	for i, name := range names {
+		packCfg := cfg.Modpacks[name]
+		mods := cfg.EffectiveMods(packCfg)
+		ps := PackStats{Name: name, MCVersion: packCfg.MCVersion, Loader: packCfg.Loader, Java: packCfg.JavaVersion, Mods: len(mods), DiskBytes: dirSize(filepath.Join(modsDir, name))}
+		for _, slug := range mods {
+			if _, ok := state[name][slug]; ok {
+				ps.Tracked++
+			}
+		}
+		fleet.Packs[i] = ps
+		if offline {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			// Each pack's lines come out together rather than interleaved with the other packs'
+			out := newOutputBlock(os.Stdout)
+			if verbose {
+				fmt.Fprintf(out, "%s:\n", name)
+			}
+			outdated, failed := countOutdated(out, mods, packCfg, state[name])
+			out.Flush()
+			fleet.Packs[i].Outdated = &outdated
+			fleet.Packs[i].Unchecked = &failed
+		}(i)
+	}
+	wg.Wait()
+	return fleet
+}
+
+// dirSize returns the total size of the regular files directly inside dir, or 0 if it doesn't exist
+func dirSize(dir string) int64 {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, f := range files {
+		if f.Type().IsRegular() {
+			if info, err := f.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+	}
+	return total
+}
+
+// sortedPackNames returns the config's pack names in alphabetical order
+func sortedPackNames(cfg *Config) []string {
+	names := make([]string, 0, len(cfg.Modpacks))
+	for name := range cfg.Modpacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listedPackNames is sortedPackNames without archived packs, unless all is set
+func listedPackNames(cfg *Config, all bool) []string {
+	names := sortedPackNames(cfg)
+	if all {
+		return names
+	}
+	return slices.DeleteFunc(names, func(name string) bool { return cfg.Modpacks[name].Archived })
+}
+
+// javaLabel formats a pack's java_version for listings, "-" when unset
+func javaLabel(v int) string {
+	if v == 0 {
+		return "-"
+	}
+	return strconv.Itoa(v)
+}
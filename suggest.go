@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+)
+
+// maxTypoDistance is the most edits between a mistyped slug and the one meant for a suggestion
+const maxTypoDistance = 2
+
+// editDistance is the Levenshtein distance between a and b, counted in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// closestSlug returns the candidate nearest to slug when it is close enough to be what was meant:
+// at most maxTypoDistance edits and fewer than half of slug's length, so short slugs don't match
+// everything. Case is ignored, so a candidate differing from slug only in case is the best match.
+// Ties go to the earlier candidate.
+func closestSlug(slug string, candidates []string) (string, bool) {
+	lower := strings.ToLower(slug)
+	best, bestDist := "", maxTypoDistance+1
+	for _, c := range candidates {
+		if c == slug {
+			continue
+		}
+		if d := editDistance(lower, strings.ToLower(c)); d < bestDist && (d == 0 || d*2 < len(lower)) {
+			best, bestDist = c, d
+		}
+	}
+	return best, best != ""
+}
+
+// suggestProject searches Modrinth for a project whose slug is a likely correction of slug, for
+// mods that turn out not to exist. Search failures just mean no suggestion.
+func suggestProject(slug string) (string, bool) {
+	hits, err := SearchProjects(slug, 10)
+	if err != nil {
+		return "", false
+	}
+	slugs := make([]string, len(hits))
+	for i, h := range hits {
+		slugs[i] = h.Slug
+	}
+	return closestSlug(slug, slugs)
+}
@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"sodium", "sodium", 0},
+		{"", "iris", 4},
+		{"iris", "", 4},
+		{"sodim", "sodium", 1},
+		{"sodiumm", "sodium", 1},
+		{"sodiun", "sodium", 1},
+		{"fabirc-api", "fabric-api", 2},
+		{"kitten", "sitting", 3},
+		{"über", "uber", 1}, // counted in runes, not bytes
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestClosestSlug(t *testing.T) {
+	candidates := []string{"sodium", "appleskin", "iris", "fabric-api", "lithium"}
+	tests := []struct {
+		slug   string
+		want   string
+		wantOK bool
+	}{
+		{"sodim", "sodium", true},
+		{"fabirc-api", "fabric-api", true},
+		{"AppleSkin", "appleskin", true}, // only the case differs
+		{"SODIM", "sodium", true},
+		{"sodium", "", false}, // already exact
+		{"irs", "iris", true},
+		{"ir", "", false}, // two edits are too much of a short slug
+		{"create", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := closestSlug(tt.slug, candidates)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("closestSlug(%q) = %q, %v; want %q, %v", tt.slug, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	// Ties go to the earlier candidate
+	if got, _ := closestSlug("lithum", []string{"lithium", "lithumm"}); got != "lithium" {
+		t.Errorf("closestSlug tie = %q, want the first candidate", got)
+	}
+}
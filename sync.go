@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// unexpectedJars lists the jars in dir that no entry of packState accounts for, leaving out names
+// matching any of the exclude globs. A missing directory is not an error; it simply has nothing to remove.
+func unexpectedJars(dir string, packState map[string]ModState, exclude []string) ([]string, error) {
+	for _, pattern := range exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad --exclude pattern %q: %w", pattern, err)
+		}
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read mods directory %s: %w", dir, err)
+	}
+
+	// Build a map of expected filenames from the state
+	expectedFiles := make(map[string]bool)
+	for _, modState := range packState {
+		if modState.Filename != "" {
+			expectedFiles[modState.Filename] = true
+		}
+	}
+
+	var stale []string
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(strings.ToLower(f.Name()), ".jar") {
+			continue // Skip directories and non-jar files
+		}
+		if !expectedFiles[f.Name()] && !excluded(f.Name(), exclude) {
+			stale = append(stale, f.Name())
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// excluded reports whether name matches one of the glob patterns
+func excluded(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// removeUnexpected deletes the named files from dir, honoring --dry-run, and returns how many were (or would be) removed
+func removeUnexpected(dir, packName string, names []string) int {
+	removedCount := 0
+	for _, name := range names {
+		filePath := filepath.Join(dir, name)
+		if dryRun {
+			fmt.Printf("[dry-run] would remove %s (not found in state for %s)\n", filePath, packName)
+			removedCount++
+			continue
+		}
+		fmt.Printf("Removing %s (not found in state for %s)...\n", filePath, packName)
+		if err := os.Remove(filePath); err != nil {
+			fmt.Printf("  ✗ Failed to remove: %v\n", err)
+		} else {
+			removedCount++
+		}
+	}
+	return removedCount
+}
+
+// duplicateJar is an untracked jar that is another version of a mod state already has a file for
+type duplicateJar struct {
+	Name    string
+	Slug    string
+	Version string // version number of the duplicate
+}
+
+// duplicateJars identifies the untracked jars names in dir by hash and returns those that belong
+// to a mod of packState, as an interrupted update leaves behind. Jars Modrinth doesn't know or
+// that belong to other projects are left out, and so are copies of a mod whose recorded file is
+// missing, since that copy may be the only one left.
+func duplicateJars(dir string, packState map[string]ModState, names []string) []duplicateJar {
+	slugByProject := make(map[string]string)
+	for slug, ms := range packState {
+		if ms.Filename == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, ms.Filename)); err != nil {
+			continue
+		}
+		if proj, err := FetchProject(slug); err == nil {
+			slugByProject[proj.ID] = slug
+		} else if verbose {
+			fmt.Printf("Warning: could not look up %s: %v\n", slug, err)
+		}
+	}
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+	}
+	sums := hashFiles(paths)
+
+	var dups []duplicateJar
+	for i, name := range names {
+		sum, ok := sums[paths[i]]
+		if !ok {
+			continue
+		}
+		ver, err := FetchVersionByHash(sum)
+		if err != nil {
+			continue
+		}
+		if slug, ok := slugByProject[ver.ProjectID]; ok {
+			dups = append(dups, duplicateJar{Name: name, Slug: slug, Version: ver.VersionNumber})
+		}
+	}
+	return dups
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Download throughput is remembered across runs (state.throughput.json) so update can estimate how
+// long a batch of downloads will take before starting it.
+const (
+	// throughputMinSample is how many bytes a run must download before its speed is recorded;
+	// a few small jars measure request latency more than bandwidth
+	throughputMinSample = 1 << 20
+	// throughputWeight is the share a new measurement gets in the rolling average
+	throughputWeight = 0.3
+)
+
+// throughputHistory is the rolling average download speed of past runs
+type throughputHistory struct {
+	BytesPerSec float64   `json:"bytes_per_sec"`
+	Samples     int       `json:"samples"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// loadThroughput reads the recorded download speed; BytesPerSec is 0 when there is none
+func loadThroughput() throughputHistory {
+	var h throughputHistory
+	loadSidecar(sidecarPath("throughput"), &h)
+	return h
+}
+
+// record folds a run that downloaded bytes in elapsed into the average and saves it. Runs too small
+// to say much about bandwidth are ignored.
+func (h *throughputHistory) record(bytes int64, elapsed time.Duration) error {
+	if bytes < throughputMinSample || elapsed <= 0 {
+		return nil
+	}
+	rate := float64(bytes) / elapsed.Seconds()
+	if h.Samples == 0 || h.BytesPerSec <= 0 {
+		h.BytesPerSec = rate
+	} else {
+		h.BytesPerSec = throughputWeight*rate + (1-throughputWeight)*h.BytesPerSec
+	}
+	h.Samples++
+	h.UpdatedAt = time.Now()
+	return saveSidecar(sidecarPath("throughput"), h, false)
+}
+
+// estimateDuration is how long bytes take at bytesPerSec, and false when the speed is unknown
+func estimateDuration(bytes int64, bytesPerSec float64) (time.Duration, bool) {
+	if bytesPerSec <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(bytes) / bytesPerSec * float64(time.Second)), true
+}
+
+// roughDuration formats an estimate as loosely as it deserves, e.g. "~4 min" or "a few seconds"
+func roughDuration(d time.Duration) string {
+	d = d.Round(10 * time.Second)
+	switch {
+	case d < 10*time.Second:
+		return "a few seconds"
+	case d < time.Minute:
+		return fmt.Sprintf("~%d s", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("~%d min", int(d.Round(time.Minute).Minutes()))
+	default:
+		return fmt.Sprintf("~%.1f h", d.Hours())
+	}
+}
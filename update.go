@@ -0,0 +1,488 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// packUpdate is one update run over a pack, shared by the update command and the HTTP API so both
+// install mods the same way. check settles what each mod needs, queue approves a mod's download,
+// download runs the approved ones and records them in state, and finish settles dependencies.
+type packUpdate struct {
+	name      string
+	packCfg   ModpackConfig
+	mcVersion string
+	loader    string
+	inRange   []string  // --mc-version-range, nil to resolve against mcVersion
+	readOnly  bool      // only look: no renames, no state changes (--resolve-only, API checks)
+	hints     bool      // say which other loaders have a build of a mod with none for this one
+	out       io.Writer // per-mod status lines
+
+	packState map[string]ModState
+	destDir   string   // the pack directory, or its staging copy
+	liveDir   string   // the pack directory
+	mods      []string // checked in order: the pack's mods, then dependencies found along the way
+	listed    []string // the pack's own mods
+	deps      *depResolver
+	targets   map[string]resolvedTarget
+	claimed   map[string]string // filename -> slug whose queued download saves under it
+	jobs      []downloadJob
+	report    *UpdateReport
+
+	changed      bool              // packState needs saving
+	renamed      bool              // misnamed files repaired this run
+	failed       bool              // a download or its preparation failed, which fails a staged run
+	stageErr     error             // set when a staged run was thrown away
+	downloaded   []string          // slugs written this run
+	updates      []changelogUpdate // for --changelog-summary
+	incompatible []string          // mods with no build for the pack, checked for a wrong loader afterwards
+}
+
+// newPackUpdate starts a run over mods, the pack's own mods in the order to check them, resolving
+// against mcVersion and loader
+func newPackUpdate(name string, packCfg ModpackConfig, mcVersion, loader string, packState map[string]ModState, mods []string, out io.Writer) *packUpdate {
+	dir := filepath.Join(modsDir, name)
+	return &packUpdate{
+		name: name, packCfg: packCfg, mcVersion: mcVersion, loader: loader, out: out,
+		packState: packState, destDir: dir, liveDir: dir,
+		mods: slices.Clone(mods), listed: slices.Clone(mods),
+		deps: newDepResolver(), targets: make(map[string]resolvedTarget), claimed: make(map[string]string),
+		report: &UpdateReport{Pack: name, MCVersion: mcVersion, Loader: loader, Timestamp: time.Now()},
+	}
+}
+
+// modUpdate is what check found for one mod: the plan entry, plus what queue needs to act on it
+type modUpdate struct {
+	PlanEntry
+	ver        *Version
+	file       *VersionFile
+	old        ModState // the mod's state before the run
+	inState    bool
+	fileExists bool   // old's file is in the pack directory
+	prompt     string // asks whether to download, for a pending mod
+}
+
+// pending reports whether the mod needs a download
+func (m *modUpdate) pending() bool {
+	return m.Error == "" && (m.Action == actionNew || m.Action == actionUpdate || m.Action == actionRedownload)
+}
+
+// resolve returns the version slug should be on: its pin, else the latest build on its channel for
+// the run's MC version (or range) and loader
+func (u *packUpdate) resolve(slug string) (*Version, string, error) {
+	if pinID, pinned := u.packCfg.Pins[slug]; pinned {
+		ver, err := FetchVersion(pinID)
+		return ver, "", err
+	} else if u.inRange != nil {
+		return FetchLatestInRange(slug, u.inRange, u.loader, u.packCfg.ChannelFor(slug))
+	}
+	ver, err := FetchLatestVersionForChannel(slug, u.mcVersion, u.loader, u.packCfg.ChannelFor(slug))
+	return ver, "", err
+}
+
+// prefetch looks every listed mod's target version up concurrently, so the checks, which run in
+// order, don't wait on Modrinth one mod at a time
+func (u *packUpdate) prefetch() {
+	u.targets = resolveTargets(slices.DeleteFunc(slices.Clone(u.mods), func(slug string) bool { return slices.Contains(u.packCfg.Frozen, slug) }), u.resolve)
+}
+
+// startStaging points the run's writes at a copy of the pack directory (--staging), which only
+// replaces the live one once every download succeeded
+func (u *packUpdate) startStaging() error {
+	dir, err := prepareStaging(u.liveDir)
+	if err != nil {
+		return err
+	}
+	u.destDir = dir
+	if verbose {
+		fmt.Printf("Staging downloads in %s\n", dir)
+	}
+	return nil
+}
+
+// finishStaging verifies everything staged and swaps it in for the live directory. If anything
+// failed the copy is thrown away, the state left unsaved and stageErr set instead.
+func (u *packUpdate) finishStaging() error {
+	if u.destDir == u.liveDir {
+		return nil
+	}
+	for _, slug := range u.downloaded {
+		ms := u.packState[slug]
+		if u.failed || ms.SHA512 == "" {
+			continue
+		}
+		if sum, err := fileSHA512(filepath.Join(u.destDir, ms.Filename)); err != nil || sum != ms.SHA512 {
+			fmt.Fprintf(u.out, "  ✗ Staged file for %s failed verification\n", slug)
+			u.failed = true
+		}
+	}
+	switch {
+	case u.failed:
+		os.RemoveAll(u.destDir)
+		u.stageErr = fmt.Errorf("staged update of %s failed; live directory %s left untouched and state not saved", u.name, u.liveDir)
+		u.changed = false
+	case u.changed || u.renamed:
+		if err := commitStaging(u.destDir, u.liveDir); err != nil {
+			return err
+		}
+		fmt.Fprintf(u.out, "\nSwapped staged files into %s\n", u.liveDir)
+	default:
+		os.RemoveAll(u.destDir)
+	}
+	return nil
+}
+
+// check works out what slug needs: nothing (frozen, skipped, failed or current, reported as such),
+// or a download, described by the returned entry's action and prompt. Required dependencies the
+// pack doesn't list are appended to the run's mods as they're found.
+func (u *packUpdate) check(slug string) *modUpdate {
+	old, inState := u.packState[slug]
+	m := &modUpdate{PlanEntry: PlanEntry{Slug: slug, From: old.VersionID}, old: old, inState: inState}
+	dependency := ""
+	if !slices.Contains(u.listed, slug) {
+		dependency = fmt.Sprintf(" (dependency of %s)", strings.Join(u.deps.requiredBy[slug], ", "))
+	}
+	fmt.Fprintf(u.out, "\nChecking %s%s%s...\n", slug, u.packCfg.channelNote(slug), dependency)
+
+	if slices.Contains(u.packCfg.Frozen, slug) {
+		// A frozen mod still needs what its installed version requires
+		if !noDeps && inState && old.VersionID != "" {
+			if installed, err := FetchVersion(old.VersionID); err == nil {
+				u.mods = append(u.mods, u.deps.add(u.out, slug, installed, u.mods)...)
+			}
+		}
+		m.Action = actionFrozen
+		fmt.Fprintln(u.out, "  ❄ Frozen, skipped")
+		u.report.Add(slug, old.VersionID, old.VersionID, outcomeFrozen, nil)
+		return m
+	}
+	if updateEnv != "" && updateEnv != "both" {
+		proj, err := FetchProject(slug)
+		if err != nil {
+			m.Error = err.Error()
+			fmt.Fprintf(u.out, "  ✗ Error fetching project: %v\n", err)
+			u.report.Add(slug, old.VersionID, old.VersionID, outcomeFailed, err)
+			return m
+		}
+		if !proj.SupportsEnv(updateEnv) {
+			m.Action = actionSkipEnv
+			fmt.Fprintf(u.out, "  ⊘ Not used on a %s (client: %s, server: %s), skipped\n", updateEnv, proj.ClientSide, proj.ServerSide)
+			u.report.Add(slug, old.VersionID, old.VersionID, outcomeSkipped, nil)
+			// Forget any installed file so sync/--prune clear it out of this environment
+			if old.SkipEnv != updateEnv && !dryRun && !u.readOnly {
+				u.packState[slug] = ModState{SkipEnv: updateEnv, RequiredBy: old.RequiredBy}
+				u.changed = true
+			}
+			return m
+		}
+	}
+
+	path := ""
+	if inState && old.Filename != "" {
+		path = filepath.Join(u.destDir, old.Filename)
+		if _, err := os.Stat(path); err == nil {
+			m.fileExists = true
+		} else if !os.IsNotExist(err) {
+			fmt.Fprintf(u.out, "  ✗ Error checking file %s: %v\n", path, err)
+		} else if !u.readOnly {
+			// The file may be on disk under a different name; rename it rather than redownloading
+			if found, err := repairFilename(u.destDir, old); err != nil {
+				fmt.Fprintf(u.out, "  ✗ %v\n", err)
+			} else if found != "" && dryRun {
+				fmt.Fprintf(u.out, "  [dry-run] would rename %s to %s\n", found, old.Filename)
+			} else if found != "" {
+				fmt.Fprintf(u.out, "  ✓ Renamed %s to %s\n", found, old.Filename)
+				m.fileExists = true
+				u.renamed = true
+			}
+		}
+	}
+	// A present file only counts if it still matches the recorded hash
+	fileValid := m.fileExists
+	if m.fileExists && old.SHA512 != "" && !fastCheck {
+		sum, err := fileSHA512(path)
+		if err != nil {
+			fmt.Fprintf(u.out, "  ✗ Error hashing file %s: %v\n", path, err)
+			fileValid = false
+		} else if sum != old.SHA512 {
+			if verbose {
+				fmt.Fprintf(u.out, "  Hash mismatch for %s: expected %s, got %s\n", path, old.SHA512, sum)
+			}
+			fileValid = false
+		}
+	}
+
+	target, ok := u.targets[slug]
+	if !ok {
+		// A dependency found during the checks
+		target.Ver, target.MatchedMC, target.Err = u.resolve(slug)
+	}
+	ver, err := target.Ver, target.Err
+	var file *VersionFile
+	if err == nil {
+		file, err = ver.PrimaryFile()
+	}
+	if err != nil {
+		m.Error = err.Error()
+		fmt.Fprintf(u.out, "  ✗ Error fetching latest version: %v\n", err)
+		if u.hints {
+			printAvailabilityHint(slug, u.mcVersion, u.loader, "    ")
+		}
+		u.report.Add(slug, old.VersionID, old.VersionID, outcomeFailed, err)
+		if errors.As(err, new(*IncompatibleError)) {
+			u.incompatible = append(u.incompatible, slug)
+		}
+		return m
+	}
+	m.ver, m.file = ver, file
+	m.VersionID, m.VersionNumber, m.MCVersion = ver.ID, ver.VersionNumber, target.MatchedMC
+	m.Filename, m.URL, m.Size, m.SHA512 = file.Filename, file.URL, file.Size, file.Hashes.SHA512
+
+	if target.MatchedMC != "" {
+		fmt.Fprintf(u.out, "  ↳ %s is for MC %s\n", ver.VersionNumber, target.MatchedMC)
+	}
+	if !noDeps {
+		added := u.deps.add(u.out, slug, ver, u.mods)
+		if len(added) > 0 {
+			fmt.Fprintf(u.out, "  + Requires %s, not in the pack; installing as a dependency\n", strings.Join(added, ", "))
+		}
+		u.mods = append(u.mods, added...)
+	}
+
+	m.Action = actionNone
+	switch {
+	case !inState:
+		m.Action = actionNew
+		m.prompt = fmt.Sprintf("  + New mod found: %s (version %s). Download?", slug, showVer(ver))
+	case ver.ID != old.VersionID:
+		m.Action = actionUpdate
+		m.prompt = fmt.Sprintf("  ⚠ Update available: %s (%s -> %s). Update?", slug, showInstalled(old), showVer(ver))
+	case !fileValid:
+		m.Action = actionRedownload
+		// Slightly different message if filename was known vs unknown (old state format)
+		if m.fileExists {
+			m.prompt = fmt.Sprintf("  ! File corrupted or replaced: %s (version %s). Redownload?", slug, showVer(ver))
+		} else if old.Filename != "" {
+			m.prompt = fmt.Sprintf("  ! File missing: %s (version %s). Redownload?", slug, showVer(ver))
+		} else {
+			m.prompt = fmt.Sprintf("  ! File needed: %s (version %s). Download?", slug, showVer(ver))
+		}
+	case reuploaded(old, slug, file):
+		// Same version, but the author replaced its file; the old name would otherwise stick forever
+		m.Action = actionRedownload
+		m.prompt = fmt.Sprintf("  ↻ File renamed upstream: %s (version %s) is now %s, installed as %s. Redownload?", slug, showVer(ver), file.Filename, old.Filename)
+	}
+
+	// --max-versions-behind: small gaps count as current; bigger ones say how far behind they are
+	if _, pinned := u.packCfg.Pins[slug]; m.Action == actionUpdate && maxBehind >= 0 && !pinned {
+		var versions []Version
+		if u.inRange != nil {
+			versions, err = FetchLoaderVersions(slug, u.loader)
+		} else {
+			versions, err = FetchVersions(slug, u.mcVersion, u.loader)
+		}
+		if n, unit, ok := versionsBehind(versions, old.VersionID, ver, u.packCfg.ChannelFor(slug)); err == nil && ok {
+			if n <= maxBehind && fileValid {
+				// A missing file needs a download anyway, so that one takes the latest
+				m.Action = actionNone
+				fmt.Fprintf(u.out, "  ✓ Keeping %s (%s %s; within --max-versions-behind %d)\n", showInstalled(old), describeBehind(n, unit), showVer(ver), maxBehind)
+				u.report.Add(slug, old.VersionID, old.VersionID, outcomeUpToDate, nil)
+				return m
+			} else if n > maxBehind {
+				m.prompt = strings.TrimSuffix(m.prompt, "). Update?") + ", " + describeBehind(n, unit) + "). Update?"
+			}
+		}
+	}
+
+	if m.Action == actionNone {
+		fmt.Fprintf(u.out, "  ✓ Up to date: %s\n", showVer(ver))
+		if old.VersionNumber == "" && old.VersionID == ver.ID && !u.readOnly {
+			// Written before state kept version numbers; saved along with the run's other changes,
+			// if it has any
+			old.VersionNumber = ver.VersionNumber
+			u.packState[slug] = old
+		}
+		u.report.Add(slug, old.VersionID, ver.ID, outcomeUpToDate, nil)
+		return m
+	}
+
+	if !u.readOnly {
+		u.recordPresent(m)
+	}
+	return m
+}
+
+// recordPresent records m as installed without downloading when its target file is already on disk
+// (a lost state file, or a rerun after a partial failure), leaving nothing pending
+func (u *packUpdate) recordPresent(m *modUpdate) {
+	slug, old, ver, file := m.Slug, m.old, m.ver, m.file
+	name := presentByHash(u.destDir, slug, file)
+	if name == "" {
+		return
+	}
+	if dryRun {
+		fmt.Fprintf(u.out, "  [dry-run] %s already matches %s; would record it without downloading\n", name, showVer(ver))
+	} else {
+		fmt.Fprintf(u.out, "  ✓ %s already matches %s by hash; recorded without downloading\n", name, showVer(ver))
+		if m.fileExists && old.Filename != name {
+			os.Remove(filepath.Join(u.destDir, old.Filename))
+		}
+		u.packState[slug] = ModState{VersionID: ver.ID, VersionNumber: ver.VersionNumber, Filename: name, SHA512: file.Hashes.SHA512, RequiredBy: old.RequiredBy}
+		u.changed = true
+		u.downloaded = append(u.downloaded, slug)
+	}
+	m.Action = actionNone
+	u.report.Add(slug, old.VersionID, ver.ID, outcomeUpToDate, nil)
+}
+
+// planned takes one entry of a plan saved with --plan-out in place of check, for update
+// --from-plan. Its download is only still right while the mod is on the version the plan was
+// made from, and the plan's required_by stands in for reading dependencies again.
+func (u *packUpdate) planned(e PlanEntry) *modUpdate {
+	old, inState := u.packState[e.Slug]
+	m := &modUpdate{PlanEntry: e, old: old, inState: inState}
+	if !slices.Contains(u.mods, e.Slug) {
+		u.mods = append(u.mods, e.Slug)
+	}
+	if e.Error == "" && e.Action != actionSkipEnv {
+		u.deps.read[e.Slug] = true
+	}
+	for _, dependent := range e.RequiredBy {
+		if !slices.Contains(u.deps.requiredBy[e.Slug], dependent) {
+			u.deps.requiredBy[e.Slug] = append(u.deps.requiredBy[e.Slug], dependent)
+		}
+	}
+
+	if m.pending() && e.Action != actionRedownload && inState && old.VersionID == e.VersionID {
+		m.Action = actionNone // applied already
+	} else if m.pending() && old.VersionID != e.From {
+		m.Error = fmt.Sprintf("installed version changed since the plan was made (now %s, planned from %s); resolve a new plan", ternary(inState, showVersionID(old.VersionID), "none"), ternary(e.From != "", showVersionID(e.From), "none"))
+	} else if m.pending() && (e.VersionID == "" || e.URL == "" || e.Filename == "" || e.SHA512 == "") {
+		m.Error = "the plan doesn't name this download's version, file and SHA-512"
+	}
+	switch {
+	case m.Error != "":
+		fmt.Fprintf(u.out, "  ✗ %s: %s\n", e.Slug, m.Error)
+		u.report.Add(e.Slug, old.VersionID, old.VersionID, outcomeFailed, errors.New(m.Error))
+		return m
+	case e.Action == actionFrozen:
+		u.report.Add(e.Slug, old.VersionID, old.VersionID, outcomeFrozen, nil)
+		return m
+	case e.Action == actionSkipEnv:
+		u.report.Add(e.Slug, old.VersionID, old.VersionID, outcomeSkipped, nil)
+		return m
+	case !m.pending():
+		u.report.Add(e.Slug, old.VersionID, old.VersionID, outcomeUpToDate, nil)
+		return m
+	}
+
+	m.ver = &Version{ID: e.VersionID, VersionNumber: e.VersionNumber}
+	m.file = &VersionFile{URL: e.URL, Filename: e.Filename, Size: e.Size}
+	m.file.Hashes.SHA512 = e.SHA512
+	if inState && old.Filename != "" {
+		if _, err := os.Stat(filepath.Join(u.destDir, old.Filename)); err == nil {
+			m.fileExists = true
+		}
+	}
+	u.recordPresent(m)
+	return m
+}
+
+// queue adds m's approved download to the run's jobs, under a name that doesn't collide with
+// another file
+func (u *packUpdate) queue(m *modUpdate) {
+	slug, old, ver, file := m.Slug, m.old, m.ver, m.file
+	if dryRun {
+		fmt.Fprintf(u.out, "    [dry-run] would download %s\n", file.Filename)
+		u.report.Add(slug, old.VersionID, ver.ID, outcomeDryRun, nil)
+		if m.Action == actionUpdate {
+			u.updates = append(u.updates, changelogUpdate{Slug: slug, FromID: old.VersionID, To: ver})
+		}
+		return
+	}
+
+	if verbose {
+		fmt.Printf("    Ensuring directory %s exists\n", u.destDir)
+	}
+	if err := os.MkdirAll(u.destDir, os.ModePerm); err != nil {
+		fmt.Fprintf(u.out, "    ✗ Failed to create directory: %v\n", err)
+		u.failed = true
+		u.report.Add(slug, old.VersionID, old.VersionID, outcomeFailed, err)
+		return
+	}
+
+	// Save under the API filename state records, unless another file already has that name
+	name, err := targetFilename(u.destDir, file.Filename, slug, old.Filename, file.Hashes.SHA512)
+	if other, taken := u.claimed[name]; err == nil && taken {
+		err = fmt.Errorf("%s is also the download of %s", name, other)
+	}
+	if err != nil {
+		fmt.Fprintf(u.out, "    ✗ %v\n", err)
+		u.failed = true
+		u.report.Add(slug, old.VersionID, old.VersionID, outcomeFailed, err)
+		return
+	}
+	if name != file.Filename {
+		fmt.Fprintf(u.out, "    %s is taken by another file; saving as %s\n", file.Filename, name)
+	}
+	u.claimed[name] = slug
+	oldPath := ""
+	if m.fileExists {
+		oldPath = filepath.Join(u.destDir, old.Filename)
+	}
+	u.jobs = append(u.jobs, downloadJob{Slug: slug, Ver: ver, File: file, Filename: name, Old: old, OldPath: oldPath, Action: m.Action})
+	fmt.Fprintf(u.out, "    Queued download of %s\n", name)
+}
+
+// download runs the queued jobs in parallel and records each one that succeeded in state
+func (u *packUpdate) download() {
+	if len(u.jobs) == 0 {
+		return
+	}
+	history := loadThroughput()
+	size := downloadSize(u.jobs)
+	estimate := ""
+	if eta, ok := estimateDuration(size, history.BytesPerSec); ok {
+		estimate = fmt.Sprintf(", est. %s at recent speed", roughDuration(eta))
+	}
+	fmt.Fprintf(u.out, "\nDownloading %d file(s) (%s), up to %d at a time%s...\n", len(u.jobs), humanSize(size), min(networkWorkers(), len(u.jobs)), estimate)
+	errs := runDownloads(u.out, u.destDir, u.jobs, &history)
+	for i, job := range u.jobs {
+		old := job.Old
+		if errs[i] != nil {
+			u.failed = true
+			u.report.Add(job.Slug, old.VersionID, old.VersionID, outcomeFailed, errs[i])
+			continue
+		}
+		// The old file goes only once its replacement is in place, and only if the name changed
+		if job.OldPath != "" && old.Filename != job.Filename {
+			if verbose {
+				fmt.Printf("    Removing old file: %s\n", job.OldPath)
+			}
+			if err := os.Remove(job.OldPath); err != nil {
+				fmt.Fprintf(u.out, "    ✗ Failed to remove old file: %v\n", err)
+			}
+		}
+		u.packState[job.Slug] = ModState{VersionID: job.Ver.ID, VersionNumber: job.Ver.VersionNumber, Filename: job.Filename, SHA512: job.SHA512, RequiredBy: old.RequiredBy}
+		u.changed = true
+		u.downloaded = append(u.downloaded, job.Slug)
+		u.report.Add(job.Slug, old.VersionID, job.Ver.ID, outcomeDownloaded, nil)
+		if job.Action == actionUpdate {
+			u.updates = append(u.updates, changelogUpdate{Slug: job.Slug, FromID: old.VersionID, To: job.Ver})
+		}
+	}
+	u.jobs = nil
+}
+
+// finish records which mods require each dependency and drops dependencies nothing needs any more
+func (u *packUpdate) finish() {
+	if !noDeps && !u.readOnly && u.deps.apply(u.out, u.packState, u.listed) {
+		u.changed = true
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeMod is a project the fake Modrinth of newFakeModrinth serves, with one version
+type fakeMod struct {
+	id, slug, versionID string
+	requires            []string // project IDs
+}
+
+// newFakeModrinth serves mods' projects, their version lists and their jars, and returns the
+// published SHA-512 of each mod's jar by slug
+func newFakeModrinth(t *testing.T, mods ...fakeMod) map[string]string {
+	t.Helper()
+	mux := http.NewServeMux()
+	sums := make(map[string]string)
+	var base string
+	for _, m := range mods {
+		jar := []byte("jar of " + m.slug)
+		sum := sha512.Sum512(jar)
+		sums[m.slug] = hex.EncodeToString(sum[:])
+		ver := Version{ID: m.versionID, ProjectID: m.id, VersionNumber: "1.0.0", VersionType: "release",
+			DatePublished: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), GameVersions: []string{"1.21.1"}, Loaders: []string{"fabric"}}
+		for _, dep := range m.requires {
+			ver.Dependencies = append(ver.Dependencies, Dependency{ProjectID: dep, DependencyType: "required"})
+		}
+		project := Project{ID: m.id, Slug: m.slug, ClientSide: "required", ServerSide: "required"}
+		for _, key := range []string{m.id, m.slug} {
+			mux.HandleFunc("/v2/project/"+key, func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(project)
+			})
+		}
+		mux.HandleFunc("/v2/project/"+m.slug+"/version", func(w http.ResponseWriter, r *http.Request) {
+			v := ver
+			v.Files = []VersionFile{{URL: base + "/files/" + m.slug + ".jar", Filename: m.slug + "-1.0.0.jar", Primary: true, Size: int64(len(jar))}}
+			v.Files[0].Hashes.SHA512 = sums[m.slug]
+			json.NewEncoder(w).Encode([]Version{v})
+		})
+		mux.HandleFunc("/files/"+m.slug+".jar", func(w http.ResponseWriter, r *http.Request) {
+			w.Write(jar)
+		})
+	}
+	base = newTestAPI(t, mux).URL
+	oldModsDir := modsDir
+	modsDir = t.TempDir()
+	t.Cleanup(func() { modsDir = oldModsDir })
+	return sums
+}
+
+func TestPackUpdateInstallsDependencies(t *testing.T) {
+	sums := newFakeModrinth(t,
+		fakeMod{id: "AANobbMI", slug: "sodium", versionID: "sodium-v1", requires: []string{"P7dR8mSH"}},
+		fakeMod{id: "P7dR8mSH", slug: "fabric-api", versionID: "fapi-v1"},
+	)
+	packCfg := ModpackConfig{MCVersion: "1.21.1", Loader: "fabric"}
+	packState := make(map[string]ModState)
+	u := newPackUpdate("MyPack", packCfg, "1.21.1", "fabric", packState, []string{"sodium"}, io.Discard)
+	u.prefetch()
+	for i := 0; i < len(u.mods); i++ {
+		if m := u.check(u.mods[i]); m.pending() {
+			u.queue(m)
+		}
+	}
+	u.download()
+	u.finish()
+
+	if !slices.Equal(u.mods, []string{"sodium", "fabric-api"}) {
+		t.Errorf("checked %v, want sodium and then its dependency fabric-api", u.mods)
+	}
+	for _, slug := range []string{"sodium", "fabric-api"} {
+		ms, ok := packState[slug]
+		if !ok {
+			t.Fatalf("%s not installed; report: %+v", slug, u.report.Mods)
+		}
+		if ms.SHA512 != sums[slug] {
+			t.Errorf("%s recorded SHA-512 %q, want the jar's", slug, ms.SHA512)
+		}
+		if _, err := os.Stat(filepath.Join(modsDir, "MyPack", ms.Filename)); err != nil {
+			t.Errorf("%s: %v", slug, err)
+		}
+	}
+	if got := packState["fabric-api"].RequiredBy; !slices.Equal(got, []string{"sodium"}) {
+		t.Errorf("fabric-api required_by = %v, want [sodium]", got)
+	}
+	if !u.changed || u.failed {
+		t.Errorf("changed = %v, failed = %v after a clean install", u.changed, u.failed)
+	}
+
+	// A second run finds everything current and downloads nothing
+	u = newPackUpdate("MyPack", packCfg, "1.21.1", "fabric", packState, []string{"sodium"}, io.Discard)
+	u.prefetch()
+	for i := 0; i < len(u.mods); i++ {
+		if m := u.check(u.mods[i]); m.Action != actionNone {
+			t.Errorf("second run: %s is %q, want up to date", m.Slug, m.Action)
+		}
+	}
+}
+
+func TestPackUpdateFromPlan(t *testing.T) {
+	newFakeModrinth(t,
+		fakeMod{id: "AANobbMI", slug: "sodium", versionID: "sodium-v1", requires: []string{"P7dR8mSH"}},
+		fakeMod{id: "P7dR8mSH", slug: "fabric-api", versionID: "fapi-v1"},
+	)
+	packCfg := ModpackConfig{MCVersion: "1.21.1", Loader: "fabric"}
+	listed := []string{"sodium"}
+
+	// Resolve a plan without installing anything
+	u := newPackUpdate("MyPack", packCfg, "1.21.1", "fabric", make(map[string]ModState), listed, io.Discard)
+	u.readOnly = true
+	u.prefetch()
+	plan := &UpdatePlan{Pack: "MyPack", MCVersion: "1.21.1", Loader: "fabric"}
+	for i := 0; i < len(u.mods); i++ {
+		plan.Mods = append(plan.Mods, u.check(u.mods[i]).PlanEntry)
+	}
+	for i, e := range plan.Mods {
+		plan.Mods[i].RequiredBy = sortedUnique(u.deps.requiredBy[e.Slug])
+	}
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sodium changed since the plan was made, so only fabric-api is applied
+	packState := map[string]ModState{"sodium": {VersionID: "sodium-v0", Filename: "sodium-0.9.jar"}}
+	u = newPackUpdate("MyPack", packCfg, "1.21.1", "fabric", packState, listed, io.Discard)
+	for _, e := range saved.Mods {
+		if m := u.planned(e); m.pending() {
+			u.queue(m)
+		}
+	}
+	u.download()
+	u.finish()
+
+	if got := packState["sodium"].VersionID; got != "sodium-v0" {
+		t.Errorf("sodium moved to %s despite changing since the plan", got)
+	}
+	if ms := packState["fabric-api"]; ms.VersionID != "fapi-v1" || !slices.Equal(ms.RequiredBy, []string{"sodium"}) {
+		t.Errorf("fabric-api recorded as %+v, want fapi-v1 required by sodium", ms)
+	}
+	outcomes := make(map[string]string)
+	for _, m := range u.report.Mods {
+		outcomes[m.Slug] = m.Outcome
+	}
+	if outcomes["sodium"] != outcomeFailed || outcomes["fabric-api"] != outcomeDownloaded {
+		t.Errorf("outcomes %v, want sodium failed and fabric-api downloaded", outcomes)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// packProblem is something wrong with a pack as an edit would leave it
+type packProblem struct {
+	Slug    string
+	Problem string
+}
+
+// validateAdd checks packCfg, which already includes the added slugs, for problems those additions
+// cause: a slug that is another name for a project the pack already has, a mod with no build for
+// the pack, mods whose resolved versions (pin, or latest on the mod's channel) declare each
+// other incompatible, and known conflicts. Problems between mods that were there before are left
+// to doctor.
+func validateAdd(cfg *Config, state State, packName string, packCfg ModpackConfig, added []string) []packProblem {
+	var problems []packProblem
+	isAdded := func(slug string) bool { return slices.Contains(added, slug) }
+	mods := cfg.EffectiveMods(packCfg)
+
+	slugByID := make(map[string]string)
+	for _, slug := range mods {
+		proj, err := FetchProject(slug)
+		if err != nil {
+			if isAdded(slug) {
+				problems = append(problems, packProblem{slug, fmt.Sprintf("project lookup failed: %v", err)})
+			}
+			continue
+		}
+		if other, dup := slugByID[proj.ID]; dup {
+			if isAdded(slug) || isAdded(other) {
+				problems = append(problems, packProblem{slug, fmt.Sprintf("is the same project as %q (%s)", other, proj.ID)})
+			}
+			continue
+		}
+		slugByID[proj.ID] = slug
+	}
+
+	versions := make(map[string]*Version)
+	for _, slug := range mods {
+		ver, err := resolveTarget(slug, packCfg)
+		if err != nil {
+			if isAdded(slug) {
+				problems = append(problems, packProblem{slug, fmt.Sprintf("no build to install: %v", err)})
+			}
+			continue
+		}
+		versions[slug] = ver
+	}
+
+	for _, slug := range mods {
+		ver, ok := versions[slug]
+		if !ok {
+			continue
+		}
+		for _, dep := range ver.Dependencies {
+			if dep.DependencyType != "incompatible" {
+				continue
+			}
+			projectID := dep.ProjectID
+			if projectID == "" && dep.VersionID != "" {
+				if v, err := FetchVersion(dep.VersionID); err == nil {
+					projectID = v.ProjectID
+				}
+			}
+			other, ok := slugByID[projectID]
+			if !ok || other == slug || !(isAdded(slug) || isAdded(other)) {
+				continue
+			}
+			// A version-specific conflict only matters if that's the version the pack would get
+			if dep.VersionID != "" && versions[other] != nil && versions[other].ID != dep.VersionID {
+				continue
+			}
+			problems = append(problems, packProblem{slug, fmt.Sprintf("%s is marked incompatible with %q", showVer(ver), other)})
+		}
+	}
+
+	// Known conflicts the pack only has with the additions
+	before := make(map[string]bool)
+	for _, issue := range conflictIssues(onlyPack(cfg, packName, cfg.Modpacks[packName]), state) {
+		before[issue.Problem] = true
+	}
+	for _, issue := range conflictIssues(onlyPack(cfg, packName, packCfg), state) {
+		if issue.Pack == "" { // the lists couldn't be loaded
+			problems = append(problems, packProblem{packName, fmt.Sprintf("%s: %s", issue.Problem, issue.Remedy)})
+		} else if !before[issue.Problem] {
+			problems = append(problems, packProblem{packName, issue.Problem})
+		}
+	}
+	return problems
+}
+
+// onlyPack returns a copy of cfg whose only modpack is packCfg, named packName
+func onlyPack(cfg *Config, packName string, packCfg ModpackConfig) *Config {
+	c := *cfg
+	c.Modpacks = map[string]ModpackConfig{packName: packCfg}
+	return &c
+}
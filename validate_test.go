@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAddKnownConflicts(t *testing.T) {
+	newFakeModrinth(t,
+		fakeMod{id: "AANobbMI", slug: "sodium", versionID: "sodium-v1"},
+		fakeMod{id: "YL57xq9U", slug: "iris", versionID: "iris-v1"},
+		fakeMod{id: "NRjRiSSD", slug: "rubidium", versionID: "rubidium-v1"},
+	)
+	cfg := &Config{
+		Conflicts: []KnownConflict{
+			{Mods: []string{"sodium", "rubidium"}, Reason: "both replace the renderer"},
+			{Mods: []string{"sodium", "iris"}, Reason: "an old conflict the pack already has"},
+		},
+		Modpacks: map[string]ModpackConfig{"MyPack": {MCVersion: "1.21.1", Loader: "fabric",
+			Mods: []ModEntry{{Slug: "sodium"}, {Slug: "iris"}}}},
+	}
+	packCfg := cfg.Modpacks["MyPack"]
+	packCfg.Mods = append(packCfg.Mods, ModEntry{Slug: "rubidium"})
+
+	problems := validateAdd(cfg, State{}, "MyPack", packCfg, []string{"rubidium"})
+	if len(problems) != 1 || !strings.Contains(problems[0].Problem, "both replace the renderer") {
+		t.Errorf("validateAdd = %+v, want only the conflict rubidium brings in", problems)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestBuildInfoString(t *testing.T) {
+	tests := []struct {
+		info BuildInfo
+		want string
+	}{
+		{BuildInfo{Version: "dev", GoVersion: "go1.23.4"}, "modpilot dev go1.23.4"},
+		{BuildInfo{Version: "1.2.0", Commit: "abc123", BuildDate: "2024-05-01", CommitTime: "2024-04-30T10:00:00Z", GoVersion: "go1.23.4"}, "modpilot 1.2.0 (abc123, built 2024-05-01) go1.23.4"},
+		{BuildInfo{Version: "dev", Commit: "abc123", CommitTime: "2024-04-30T10:00:00Z", GoVersion: "go1.23.4"}, "modpilot dev (abc123, commit time 2024-04-30T10:00:00Z) go1.23.4"},
+	}
+	for _, tt := range tests {
+		if got := tt.info.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
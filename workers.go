@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// maxNetworkWorkers is how many Modrinth lookups or downloads --concurrency auto runs at once.
+// Requests are throttled by --qps anyway, so more workers would only queue behind the rate limiter.
+const maxNetworkWorkers = 8
+
+// parseConcurrency validates --concurrency, returning 0 for auto
+func parseConcurrency(s string) (int, error) {
+	if s == "auto" || s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid --concurrency %q (want auto or a positive number)", s)
+	}
+	return n, nil
+}
+
+// networkWorkers is the pool size for work that waits on Modrinth
+func networkWorkers() int {
+	if workerLimit > 0 {
+		return workerLimit
+	}
+	return maxNetworkWorkers
+}
+
+// cpuWorkers is the pool size for work bound by local CPU, such as hashing jars
+func cpuWorkers() int {
+	if workerLimit > 0 {
+		return workerLimit
+	}
+	return runtime.NumCPU()
+}
+
+// hashFiles computes the SHA-512 of each path on up to cpuWorkers goroutines. Files that
+// can't be read are left out of the result.
+func hashFiles(paths []string) map[string]string {
+	sums := make(map[string]string, len(paths))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cpuWorkers())
+	for _, p := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if sum, err := fileSHA512(p); err == nil {
+				mu.Lock()
+				sums[p] = sum
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return sums
+}